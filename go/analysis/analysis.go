@@ -74,6 +74,19 @@ type Analyzer struct {
 	// FactTypes establishes a "vertical" dependency between
 	// analysis passes (same analyzer, different packages).
 	FactTypes []Fact
+
+	// Finish, if non-nil, makes this a whole-program analyzer.
+	// Once the Run function has been applied to every package of
+	// the program, the driver calls Finish with the results of all
+	// of them, so that it can report findings, such as an exported
+	// function that is never used, that no single package
+	// establishes. Finish is called only for the analyzers
+	// requested by the user, not for those that merely satisfy the
+	// Requires of another.
+	//
+	// Drivers that analyze one package at a time, such as
+	// unitchecker, reject analyzers that have a Finish function.
+	Finish func(*FinishPass) error
 }
 
 func (a *Analyzer) String() string { return a.Name }
@@ -99,6 +112,7 @@ type Pass struct {
 	TypesInfo    *types.Info    // type information about the syntax trees
 	TypesSizes   types.Sizes    // function for computing sizes of types
 	TypeErrors   []types.Error  // type errors (only if Analyzer.RunDespiteErrors)
+	Module       *Module        // the package's enclosing module (nil if unknown)
 
 	// Report reports a Diagnostic, a finding about a specific location
 	// in the analyzed source code such as a potential mistake.
@@ -160,9 +174,110 @@ type Pass struct {
 	// facts of the analysis's FactTypes in unspecified order.
 	AllObjectFacts func() []ObjectFact
 
+	// ContributeObjectFact associates a contributed fact of type *T
+	// with obj, which may belong to any package, typically one of
+	// the dependencies of the current package. Unlike exported
+	// facts, contributed facts from several packages about the same
+	// object are all kept; a later contribution of the same type by
+	// the same package about the same object replaces an earlier one.
+	// See ContributedFact.
+	//
+	// ContributeObjectFact panics if it is called after the pass is
+	// complete. ContributeObjectFact is not concurrency-safe.
+	ContributeObjectFact func(obj types.Object, fact Fact)
+
+	// AllContributedFacts returns a new slice containing all the
+	// contributed facts of the analysis's FactTypes made by the
+	// current package and its dependencies, in unspecified order.
+	AllContributedFacts func() []ContributedFact
+
 	/* Further fields may be added in future. */
 }
 
+// A FinishPass provides information to the Finish function of a
+// whole-program analyzer, once its Run function has been applied to
+// every package of the program.
+//
+// The Finish function should not call any of the FinishPass
+// functions concurrently.
+type FinishPass struct {
+	Analyzer *Analyzer      // the identity of the current analyzer
+	Fset     *token.FileSet // file position information for all packages
+
+	// Packages holds the packages of the program to which the
+	// analyzer was successfully applied, in an unspecified order.
+	// These are the packages requested by the user, such as those
+	// that match the patterns of a command line, not their
+	// dependencies.
+	Packages []*PackageResult
+
+	// Report reports a Diagnostic about a file of one of the Packages.
+	Report func(Diagnostic)
+
+	// ReadFile returns the contents of the named file of one of the
+	// Packages. See Pass.ReadFile for the valid file names.
+	ReadFile func(filename string) ([]byte, error)
+
+	// AllPackageFacts and AllObjectFacts return new slices
+	// containing all the package and object facts of the analyzer's
+	// FactTypes exported during the analysis of the Packages and
+	// their dependencies, in unspecified order.
+	AllPackageFacts func() []PackageFact
+	AllObjectFacts  func() []ObjectFact
+
+	// AllContributedFacts returns a new slice containing all the
+	// contributed facts of the analyzer's FactTypes made during the
+	// analysis of the Packages and their dependencies, in
+	// unspecified order.
+	AllContributedFacts func() []ContributedFact
+}
+
+// A PackageResult holds the outcome of the Run function of an
+// analyzer on one package of a program.
+type PackageResult struct {
+	Pkg       *types.Package // type information about the package
+	Files     []*ast.File    // the abstract syntax tree of each file
+	TypesInfo *types.Info    // type information about the syntax trees
+	Result    interface{}    // result of Run, of the analyzer's ResultType
+}
+
+// Reportf is a helper function that reports a Diagnostic using the
+// specified position and formatted error message.
+func (pass *FinishPass) Reportf(pos token.Pos, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	pass.Report(Diagnostic{Pos: pos, Message: msg})
+}
+
+// A Module describes the module to which a package belongs.
+type Module struct {
+	Path      string // module path
+	Version   string // module version ("" for a main module, such as a module of the workspace)
+	GoVersion string // go version declared by the module (e.g. "go1.22.0"), or "" if unknown
+
+	// Requires and Replaces hold the require and replace
+	// directives of the module's go.mod file. For a main module in
+	// a workspace, Replaces also holds those of the go.work file,
+	// after those of go.mod. Both are nil if the driver could not
+	// read the go.mod file.
+	Requires []ModuleRequirement
+	Replaces []ModuleReplacement
+}
+
+// A ModuleRequirement is a require directive of a go.mod file.
+type ModuleRequirement struct {
+	Path     string
+	Version  string
+	Indirect bool // marked "// indirect"
+}
+
+// A ModuleReplacement is a replace directive of a go.mod or go.work
+// file. OldVersion is empty if all versions are replaced, and
+// NewVersion is empty if NewPath is a directory.
+type ModuleReplacement struct {
+	OldPath, OldVersion string
+	NewPath, NewVersion string
+}
+
 // PackageFact is a package together with an associated fact.
 type PackageFact struct {
 	Package *types.Package
@@ -175,6 +290,24 @@ type ObjectFact struct {
 	Fact   Fact
 }
 
+// A ContributedFact is a fact about an object contributed by a
+// package, which need not be the package that declares the object.
+// It records, for example, that a package calls a function of one of
+// its dependencies, which the dependency itself cannot know.
+//
+// Contributed facts flow, like other facts, from a package to the
+// packages that import it, directly or indirectly, and to the Finish
+// function of a whole-program analyzer. Drivers that serialize facts
+// discard contributions about objects that cannot be named from the
+// API of their package, such as local variables, and contributions
+// about objects of packages that a dependent does not (even
+// indirectly) see in its type information.
+type ContributedFact struct {
+	Object types.Object
+	From   string // path of the contributing package
+	Fact   Fact
+}
+
 // Reportf is a helper function that reports a Diagnostic using the
 // specified position and formatted error message.
 func (pass *Pass) Reportf(pos token.Pos, format string, args ...interface{}) {
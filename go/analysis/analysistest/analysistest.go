@@ -337,6 +337,13 @@ func applyDiffsAndCompare(src, golden []byte, edits []diff.Edit, fileName string
 //
 //	// want "diag" "diag2" x:"fact1" x:"fact2" y:"fact3"
 //
+// The related information of a diagnostic is checked only if its
+// expectation is followed by '-> "pattern"' for each item of related
+// information, in order. The patterns must match the messages of the
+// items, of which there must be exactly as many. For example:
+//
+//	x := *p // want "nil dereference" -> "p is assigned nil" -> "p is dereferenced"
+//
 // Unexpected diagnostics and facts, and unmatched expectations, are
 // reported as errors to the Testing.
 //
@@ -527,14 +534,23 @@ func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis
 		}
 	}
 
-	checkMessage := func(posn token.Position, kind, name, message string) {
+	// related holds the messages of a diagnostic's related
+	// information; it is nil for facts.
+	checkMessage := func(posn token.Position, kind, name, message string, related []string) {
 		posn.Filename = sanitize(gopath, posn.Filename)
 		k := key{posn.Filename, posn.Line}
 		expects := want[k]
 		var unmatched []string
+		badRelated := -1 // index of an expectation that matches all but the related information
 		for i, exp := range expects {
 			if exp.kind == kind && exp.name == name {
 				if exp.rx.MatchString(message) {
+					if !exp.matchRelated(related) {
+						if badRelated < 0 {
+							badRelated = i
+						}
+						continue
+					}
 					// matched: remove the expectation.
 					expects[i] = expects[len(expects)-1]
 					expects = expects[:len(expects)-1]
@@ -544,6 +560,19 @@ func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis
 				unmatched = append(unmatched, fmt.Sprintf("%#q", exp.rx))
 			}
 		}
+		if badRelated >= 0 {
+			exp := expects[badRelated]
+			var patterns []string
+			for _, rx := range exp.related {
+				patterns = append(patterns, fmt.Sprintf("%#q", rx))
+			}
+			t.Errorf("%v: %s %q has related information %q, want %s",
+				posn, kind, message, related, strings.Join(patterns, " -> "))
+			// Remove the expectation, which is reported above.
+			expects[badRelated] = expects[len(expects)-1]
+			want[k] = expects[:len(expects)-1]
+			return
+		}
 		if unmatched == nil {
 			t.Errorf("%v: unexpected %s: %v", posn, kind, message)
 		} else {
@@ -556,7 +585,11 @@ func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis
 	for _, f := range diagnostics {
 		// TODO(matloob): Support ranges in analysistest.
 		posn := pass.Fset.Position(f.Pos)
-		checkMessage(posn, "diagnostic", "", f.Message)
+		related := make([]string, len(f.Related))
+		for i, r := range f.Related {
+			related[i] = r.Message
+		}
+		checkMessage(posn, "diagnostic", "", f.Message, related)
 	}
 
 	// Check the facts match expectations.
@@ -591,7 +624,7 @@ func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis
 		}
 
 		for _, fact := range facts[obj] {
-			checkMessage(posn, "fact", name, fmt.Sprint(fact))
+			checkMessage(posn, "fact", name, fmt.Sprint(fact), nil)
 		}
 	}
 
@@ -616,9 +649,27 @@ func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis
 }
 
 type expectation struct {
-	kind string // either "fact" or "diagnostic"
-	name string // name of object to which fact belongs, or "package" ("fact" only)
-	rx   *regexp.Regexp
+	kind    string // either "fact" or "diagnostic"
+	name    string // name of object to which fact belongs, or "package" ("fact" only)
+	rx      *regexp.Regexp
+	related []*regexp.Regexp // patterns of related information, if checked ("diagnostic" only)
+}
+
+// matchRelated reports whether the messages of the related information
+// of a diagnostic match the expected patterns, one for one.
+func (ex expectation) matchRelated(related []string) bool {
+	if ex.related == nil {
+		return true // unchecked
+	}
+	if len(related) != len(ex.related) {
+		return false
+	}
+	for i, rx := range ex.related {
+		if !rx.MatchString(related[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 func (ex expectation) String() string {
@@ -626,8 +677,9 @@ func (ex expectation) String() string {
 }
 
 // parseExpectations parses the content of a "// want ..." comment
-// and returns the expectations, a mixture of diagnostics ("rx") and
-// facts (name:"rx").
+// and returns the expectations, a mixture of diagnostics ("rx"),
+// possibly with related information ("rx" -> "rx2"), and facts
+// (name:"rx").
 func parseExpectations(text string) (lineDelta int, expects []expectation, err error) {
 	var scanErr string
 	sc := new(scanner.Scanner).Init(strings.NewReader(text))
@@ -659,7 +711,21 @@ func parseExpectations(text string) (lineDelta int, expects []expectation, err e
 			if err != nil {
 				return 0, nil, err
 			}
-			expects = append(expects, expectation{"diagnostic", "", rx})
+			expects = append(expects, expectation{"diagnostic", "", rx, nil})
+
+		case '-':
+			if tok = sc.Scan(); tok != '>' {
+				return 0, nil, fmt.Errorf("got -%s, want ->", scanner.TokenString(tok))
+			}
+			if len(expects) == 0 || expects[len(expects)-1].kind != "diagnostic" {
+				return 0, nil, fmt.Errorf("-> does not follow a diagnostic")
+			}
+			rx, err := scanRegexp(sc.Scan())
+			if err != nil {
+				return 0, nil, err
+			}
+			exp := &expects[len(expects)-1]
+			exp.related = append(exp.related, rx)
 
 		case scanner.Ident:
 			name := sc.TokenText()
@@ -673,7 +739,7 @@ func parseExpectations(text string) (lineDelta int, expects []expectation, err e
 			if err != nil {
 				return 0, nil, err
 			}
-			expects = append(expects, expectation{"fact", name, rx})
+			expects = append(expects, expectation{"fact", name, rx, nil})
 
 		case scanner.EOF:
 			if scanErr != "" {
@@ -5,10 +5,14 @@
 package analysistest_test
 
 import (
+	"flag"
 	"fmt"
+	"go/ast"
 	"go/token"
+	"go/types"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -17,6 +21,7 @@ import (
 	"github.com/TBD54566975/golang-tools/go/analysis/analysistest"
 	"github.com/TBD54566975/golang-tools/go/analysis/passes/findcall"
 	"github.com/TBD54566975/golang-tools/internal/testenv"
+	"github.com/TBD54566975/golang-tools/txtar"
 )
 
 func init() {
@@ -202,8 +207,338 @@ func F() {} // want "say hello"`,
 	analysistest.RunWithSuggestedFixes(t, dir, noend, "a")
 }
 
+// TestRelated tests expectations of related information.
+func TestRelated(t *testing.T) {
+	// steps reports each function, with two items of related information.
+	steps := &analysis.Analyzer{
+		Name: "steps",
+		Doc:  "reports each function",
+		Run: func(pass *analysis.Pass) (any, error) {
+			for _, decl := range pass.Files[0].Decls {
+				name := decl.(*ast.FuncDecl).Name
+				pass.Report(analysis.Diagnostic{
+					Pos:     decl.Pos(),
+					Message: "func " + name.Name,
+					Related: []analysis.RelatedInformation{
+						{Pos: name.Pos(), Message: "first " + name.Name},
+						{Pos: name.Pos(), Message: "second " + name.Name},
+					},
+				})
+			}
+			return nil, nil
+		},
+	}
+
+	filemap := map[string]string{
+		"a/a.go": `package a
+
+func F() {} // want "func F" -> "first F" -> "second F"
+
+func G() {} // want "func G"
+
+func H() {} // want "func H" -> "first H"
+
+func I() {} // want "func I" -> "first I" -> "third I"
+
+func J() {} // want -> "first J"
+`,
+	}
+	dir, cleanup, err := analysistest.WriteFiles(filemap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	var got []string
+	t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+	analysistest.Run(t2, dir, steps, "a")
+
+	want := []string{
+		`a/a.go:11: in 'want' comment: -> does not follow a diagnostic`,
+		"a/a.go:7:1: diagnostic \"func H\" has related information [\"first H\" \"second H\"], want `first H`",
+		"a/a.go:9:1: diagnostic \"func I\" has related information [\"first I\" \"second I\"], want `first I` -> `third I`",
+		`a/a.go:11:1: unexpected diagnostic: func J`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s",
+			strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+}
+
 type errorfunc func(string)
 
 func (f errorfunc) Errorf(format string, args ...interface{}) {
 	f(fmt.Sprintf(format, args...))
 }
+
+// update is looked up by RunTxtar and CheckResultJSON.
+var update = flag.Bool("update", false, "update golden sections of RunTxtar archives")
+
+// TestRunTxtar tests golden-file expectations, including the -update mode.
+func TestRunTxtar(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	findcall.Analyzer.Flags.Set("name", "println")
+
+	const src = `
+-- go.mod --
+module example.com
+
+go 1.19
+
+-- a/a.go --
+package a
+
+func F() {
+	println("a")
+}
+
+-- b/b.go --
+package b
+
+func G() {}
+`
+	filename := filepath.Join(t.TempDir(), "test.txtar")
+	if err := os.WriteFile(filename, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the golden output.
+	*update = true
+	analysistest.RunTxtar(t, filename, findcall.Analyzer)
+	*update = false
+
+	ar, err := txtar.ParseFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	sections := make(map[string]string)
+	for _, f := range ar.Files {
+		names = append(names, f.Name)
+		sections[f.Name] = string(f.Data)
+	}
+	if want := []string{"go.mod", "a/a.go", "b/b.go", "golden/diagnostics", "golden/fixed/a/a.go"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("updated archive has sections %q, want %q", names, want)
+	}
+	if got, want := sections["golden/diagnostics"], "a/a.go:4:9: call of println(...)\n"; got != want {
+		t.Errorf("golden/diagnostics = %q, want %q", got, want)
+	}
+	if got := sections["golden/fixed/a/a.go"]; !strings.Contains(got, `println_TEST_("a")`) {
+		t.Errorf("golden/fixed/a/a.go does not contain fixed call:\n%s", got)
+	}
+
+	// The recorded output now matches.
+	analysistest.RunTxtar(t, filename, findcall.Analyzer)
+
+	// A mismatch is reported.
+	for i, f := range ar.Files {
+		if f.Name == "golden/diagnostics" {
+			ar.Files[i].Data = []byte("a/a.go:1:1: wrong\n")
+		}
+	}
+	if err := os.WriteFile(filename, txtar.Format(ar), 0666); err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{TB: t}
+	analysistest.RunTxtar(rec, filename, findcall.Analyzer)
+	if len(rec.errs) != 1 || !strings.Contains(rec.errs[0], "output does not match golden/diagnostics section") {
+		t.Errorf("got errors %q, want one mismatch of golden/diagnostics", rec.errs)
+	}
+}
+
+// TestRunTxtarAlternativeFixes tests that only the first of the
+// alternative fixes of a diagnostic is applied.
+func TestRunTxtarAlternativeFixes(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	// alternatives suggests two ways to rename each function.
+	alternatives := &analysis.Analyzer{
+		Name: "alternatives",
+		Doc:  "suggests alternative names",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			for _, f := range pass.Files {
+				for _, decl := range f.Decls {
+					if decl, ok := decl.(*ast.FuncDecl); ok {
+						id := decl.Name
+						rename := func(name string) analysis.SuggestedFix {
+							return analysis.SuggestedFix{
+								Message:   "rename to " + name,
+								TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte(name)}},
+							}
+						}
+						pass.Report(analysis.Diagnostic{
+							Pos:            id.Pos(),
+							Message:        "bad name",
+							SuggestedFixes: []analysis.SuggestedFix{rename("First"), rename("Second")},
+						})
+					}
+				}
+			}
+			return nil, nil
+		},
+	}
+
+	const src = `
+-- go.mod --
+module example.com
+
+go 1.19
+
+-- a/a.go --
+package a
+
+func F() {}
+`
+	filename := filepath.Join(t.TempDir(), "test.txtar")
+	if err := os.WriteFile(filename, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	*update = true
+	analysistest.RunTxtar(t, filename, alternatives)
+	*update = false
+
+	ar, err := txtar.ParseFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range ar.Files {
+		if f.Name == "golden/fixed/a/a.go" {
+			if got, want := string(f.Data), "package a\n\nfunc First() {}\n"; got != want {
+				t.Errorf("golden/fixed/a/a.go = %q, want %q", got, want)
+			}
+			return
+		}
+	}
+	t.Errorf("updated archive has no golden/fixed/a/a.go section")
+}
+
+// A recorder is a testing.TB that records calls to Errorf.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+type declFact struct{ Kind string }
+
+func (*declFact) AFact()           {}
+func (f *declFact) String() string { return "decl(" + f.Kind + ")" }
+
+type countFact struct{ N int }
+
+func (*countFact) AFact() {}
+
+// declsResult is the result of the decls analyzer.
+type declsResult struct {
+	Funcs   []string
+	Methods []string
+}
+
+// decls exports a fact for each function and method declaration and a
+// package fact counting them, and returns their names.
+var decls = &analysis.Analyzer{
+	Name:       "decls",
+	Doc:        "records function declarations",
+	FactTypes:  []analysis.Fact{new(declFact), new(countFact)},
+	ResultType: reflect.TypeOf(new(declsResult)),
+	Run: func(pass *analysis.Pass) (any, error) {
+		res := new(declsResult)
+		for _, f := range pass.Files {
+			for _, decl := range f.Decls {
+				if decl, ok := decl.(*ast.FuncDecl); ok {
+					kind := "func"
+					if decl.Recv != nil {
+						kind = "method"
+						res.Methods = append(res.Methods, decl.Name.Name)
+					} else {
+						res.Funcs = append(res.Funcs, decl.Name.Name)
+					}
+					pass.ExportObjectFact(pass.TypesInfo.Defs[decl.Name], &declFact{kind})
+				}
+			}
+		}
+		pass.ExportPackageFact(&countFact{len(res.Funcs) + len(res.Methods)})
+		return res, nil
+	},
+}
+
+// TestResultsAndFacts tests the structured checks of results and facts.
+func TestResultsAndFacts(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"a/a.go": `package a
+
+func F() {}
+
+type T int
+
+func (T) M() {}
+`,
+		"a/result.json": `{
+	"Funcs": ["F"],
+	"Methods": ["M"]
+}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	results := analysistest.Analyze(t, dir, decls, "a")
+	analysistest.CheckFacts(t, results, map[string]map[string][]analysis.Fact{
+		"a": {
+			"":    {&countFact{2}},
+			"F":   {&declFact{"func"}},
+			"T.M": {&declFact{"method"}},
+		},
+	})
+	analysistest.CheckResultJSON(t, results[0], filepath.Join(dir, "src/a/result.json"))
+
+	// Mismatches are reported.
+	var got []string
+	rec := errorfunc(func(s string) { got = append(got, s) })
+	analysistest.CheckFacts(rec, results, map[string]map[string][]analysis.Fact{
+		"a": {
+			"":  {&countFact{2}},
+			"F": {&declFact{"method"}},
+		},
+	})
+	want := []string{
+		"a: facts about object F: got [decl(func)], want [decl(method)]",
+		"a: facts about object T.M: got [decl(method)], want []",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors %q, want %q", got, want)
+	}
+}
+
+// TestFactsVariants tests that Facts merges the facts of
+// several variants of a package, such as p and its test variant.
+func TestFactsVariants(t *testing.T) {
+	// variant returns the result of analyzing a variant of package
+	// p that declares the named functions.
+	variant := func(funcs ...string) *analysistest.Result {
+		pkg := types.NewPackage("p", "p")
+		facts := map[types.Object][]analysis.Fact{nil: {&countFact{len(funcs)}}}
+		for _, name := range funcs {
+			fn := types.NewFunc(token.NoPos, pkg, name, types.NewSignatureType(nil, nil, nil, nil, nil, false))
+			pkg.Scope().Insert(fn)
+			facts[fn] = []analysis.Fact{&declFact{"func"}}
+		}
+		return &analysistest.Result{Pass: &analysis.Pass{Pkg: pkg}, Facts: facts}
+	}
+	results := []*analysistest.Result{variant("F"), variant("F", "TestF")}
+	analysistest.CheckFacts(t, results, map[string]map[string][]analysis.Fact{
+		"p": {
+			"":      {&countFact{1}, &countFact{2}},
+			"F":     {&declFact{"func"}},
+			"TestF": {&declFact{"func"}},
+		},
+	})
+}
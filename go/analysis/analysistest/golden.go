@@ -0,0 +1,246 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysistest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/internal/checker"
+	"github.com/TBD54566975/golang-tools/internal/diff"
+	"github.com/TBD54566975/golang-tools/internal/testenv"
+	"github.com/TBD54566975/golang-tools/internal/testfiles"
+	"github.com/TBD54566975/golang-tools/txtar"
+)
+
+// updateGolden reports whether the test binary defines a boolean
+// -update flag, and it is set.
+//
+// The flag is looked up rather than defined here, as test packages
+// that use analysistest often define their own.
+func updateGolden() bool {
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	update, _ := getter.Get().(bool)
+	return update
+}
+
+// Names of the golden sections of an archive used by RunTxtar.
+const (
+	goldenPrefix      = "golden/"
+	goldenDiagnostics = goldenPrefix + "diagnostics"
+	goldenFixed       = goldenPrefix + "fixed/"
+)
+
+// RunTxtar applies an analysis to the packages denoted by the "go list"
+// patterns (by default, "./...") in a module defined by a txtar archive,
+// and compares its diagnostics and suggested fixes with the expected
+// output recorded in golden sections of the same archive.
+//
+// The archive's files, other than its golden sections, are extracted
+// to a temporary directory, which must contain a go.mod file and may
+// contain a go.work file. The golden sections are:
+//
+//   - "golden/diagnostics", which lists each diagnostic, one per line,
+//     as "file:line:column: message", with file names relative to the
+//     root of the archive. Diagnostics reported in more than one
+//     package, such as p and p.test, are listed only once.
+//
+//   - "golden/fixed/FILE", which holds the formatted content of the
+//     archive's FILE after the first suggested fix of each diagnostic
+//     has been applied to it. (The fixes of a diagnostic are
+//     alternatives, so only the first is applied, as by the -fix flag
+//     of the checker commands.) Every file to which fixes apply must
+//     have such a section, and no other.
+//
+// For example:
+//
+//	-- go.mod --
+//	module example.com
+//
+//	-- p/p.go --
+//	package p
+//
+//	var x = !!true
+//
+//	-- golden/diagnostics --
+//	p/p.go:3:9: negating a boolean twice
+//
+//	-- golden/fixed/p/p.go --
+//	package p
+//
+//	var x = true
+//
+// Unlike Run, RunTxtar ignores "// want" comments. If the test binary
+// defines a boolean -update flag and it is set, RunTxtar rewrites the
+// golden sections of the archive to match the actual output instead
+// of comparing them.
+//
+// RunTxtar returns a Result for each package for which analysis was
+// attempted, even if unsuccessful.
+func RunTxtar(t testing.TB, filename string, a *analysis.Analyzer, patterns ...string) []*Result {
+	t.Helper()
+	testenv.NeedsGoPackages(t)
+
+	ar, err := txtar.ParseFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &txtar.Archive{Comment: ar.Comment}
+	golden := make(map[string][]byte)
+	for _, f := range ar.Files {
+		if strings.HasPrefix(f.Name, goldenPrefix) {
+			golden[f.Name] = f.Data
+		} else {
+			src.Files = append(src.Files, f)
+		}
+	}
+
+	dir := testfiles.ExtractTxtarToTmp(t, src)
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real // so that the file names reported by go list are beneath dir
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	if err := analysis.Validate([]*analysis.Analyzer{a}); err != nil {
+		t.Errorf("Validate: %v", err)
+		return nil
+	}
+	pkgs, err := loadPackages(a, dir, patterns...)
+	if err != nil {
+		t.Errorf("loading %s: %v", patterns, err)
+		return nil
+	}
+	results := checker.TestAnalyzer(a, pkgs)
+
+	// Compute the actual golden sections.
+	actual := make(map[string][]byte)
+	var diags []string
+	seen := make(map[string]bool)
+	fileEdits := make(map[string][]diff.Edit) // keyed by relative file name
+	seenEdit := make(map[string]map[diff.Edit]bool)
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("error analyzing %s: %v", result.Pass, result.Err)
+			continue
+		}
+		fset := result.Pass.Fset
+		for _, diag := range result.Diagnostics {
+			posn := fset.Position(diag.Pos)
+			line := fmt.Sprintf("%s:%d:%d: %s", relPath(dir, posn.Filename), posn.Line, posn.Column, diag.Message)
+			if !seen[line] {
+				seen[line] = true
+				diags = append(diags, line)
+			}
+
+			if len(diag.SuggestedFixes) > 0 {
+				for _, edit := range diag.SuggestedFixes[0].TextEdits {
+					end := edit.End
+					if !end.IsValid() {
+						end = edit.Pos
+					}
+					file := fset.File(edit.Pos)
+					if file == nil || fset.File(end) != file || edit.Pos > end {
+						t.Errorf("diagnostic for analysis %v contains suggested fix with malformed edit", a.Name)
+						continue
+					}
+					name := relPath(dir, file.Name())
+					e := diff.Edit{Start: file.Offset(edit.Pos), End: file.Offset(end), New: string(edit.NewText)}
+					// Skip edits repeated by another package, such as p.test.
+					if seenEdit[name] == nil {
+						seenEdit[name] = make(map[diff.Edit]bool)
+					}
+					if !seenEdit[name][e] {
+						seenEdit[name][e] = true
+						fileEdits[name] = append(fileEdits[name], e)
+					}
+				}
+			}
+		}
+	}
+	if len(diags) > 0 {
+		sort.Strings(diags)
+		actual[goldenDiagnostics] = []byte(strings.Join(diags, "\n") + "\n")
+	}
+	for name, edits := range fileEdits {
+		orig, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("error reading %s: %v", name, err)
+			continue
+		}
+		out, err := diff.ApplyBytes(orig, edits)
+		if err != nil {
+			t.Errorf("%s: error applying fixes: %v", name, err)
+			continue
+		}
+		if formatted, err := format.Source(out); err == nil {
+			out = formatted
+		}
+		actual[goldenFixed+name] = out
+	}
+
+	if updateGolden() {
+		names := make([]string, 0, len(actual))
+		for name := range actual {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			src.Files = append(src.Files, txtar.File{Name: name, Data: actual[name]})
+		}
+		if err := os.WriteFile(filename, txtar.Format(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	// Compare the actual golden sections with the expected ones.
+	for name, got := range actual {
+		want, ok := golden[name]
+		if !ok {
+			t.Errorf("%s: no %s section for output:\n%s", filename, name, got)
+			continue
+		}
+		if strings.HasPrefix(name, goldenFixed) {
+			if formatted, err := format.Source(want); err == nil {
+				want = formatted
+			}
+		}
+		if !bytes.Equal(bytes.TrimRight(got, "\n"), bytes.TrimRight(want, "\n")) {
+			unified := diff.Unified(name, "actual", string(want), string(got))
+			t.Errorf("%s: output does not match %s section:\n%s", filename, name, unified)
+		}
+	}
+	for name, want := range golden {
+		if _, ok := actual[name]; !ok && len(bytes.TrimSpace(want)) > 0 {
+			t.Errorf("%s: section %s has no corresponding output", filename, name)
+		}
+	}
+	return results
+}
+
+// relPath returns the slash-separated name of the file relative to dir.
+func relPath(dir, filename string) string {
+	if rel, err := filepath.Rel(dir, filename); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filename)
+}
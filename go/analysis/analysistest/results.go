@@ -0,0 +1,214 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysistest
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/internal/checker"
+	"github.com/TBD54566975/golang-tools/go/types/objectpath"
+	"github.com/TBD54566975/golang-tools/internal/diff"
+	"github.com/TBD54566975/golang-tools/internal/testenv"
+)
+
+// Analyze is like Run, but it does not check the diagnostics and facts
+// against "// want" comments. It is intended for tests that instead
+// check the results, for example using CheckFacts and CheckResultJSON.
+// It reports an error to the Testing if loading or analysis failed.
+func Analyze(t Testing, dir string, a *analysis.Analyzer, patterns ...string) []*Result {
+	if t, ok := t.(testing.TB); ok {
+		testenv.NeedsGoPackages(t)
+	}
+
+	pkgs, err := loadPackages(a, dir, patterns...)
+	if err != nil {
+		t.Errorf("loading %s: %v", patterns, err)
+		return nil
+	}
+
+	if err := analysis.Validate([]*analysis.Analyzer{a}); err != nil {
+		t.Errorf("Validate: %v", err)
+		return nil
+	}
+
+	results := checker.TestAnalyzer(a, pkgs)
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("error analyzing %s: %v", result.Pass, result.Err)
+		}
+	}
+	return results
+}
+
+// Facts returns the facts exported by the analysis of each package in
+// results, keyed by package path and then by object name. The facts
+// about a package itself have the name "". Package-level objects are
+// named by their identifier, and methods by "T.M", where T is the name
+// of the receiver type; other objects are named by their
+// [objectpath.Path]. The facts about each object are ordered by type,
+// then by their formatting.
+//
+// A package analyzed more than once, such as p both alone and as part
+// of its test variant, contributes the union of its facts: each
+// distinct (not deeply equal) fact about an object in any variant
+// appears once, even if the variants disagree.
+func Facts(results []*Result) map[string]map[string][]analysis.Fact {
+	facts := make(map[string]map[string][]analysis.Fact)
+	for _, r := range results {
+		if r.Pass == nil || r.Pass.Pkg == nil {
+			continue
+		}
+		path := r.Pass.Pkg.Path()
+		byName := facts[path]
+		if byName == nil {
+			byName = make(map[string][]analysis.Fact)
+			facts[path] = byName
+		}
+		for obj, objFacts := range r.Facts {
+			name := objectName(obj)
+			for _, fact := range objFacts {
+				seen := false // in another variant
+				for _, prev := range byName[name] {
+					if reflect.DeepEqual(prev, fact) {
+						seen = true
+						break
+					}
+				}
+				if !seen {
+					byName[name] = append(byName[name], fact)
+				}
+			}
+		}
+	}
+	for _, byName := range facts {
+		for _, objFacts := range byName {
+			sort.Slice(objFacts, func(i, j int) bool {
+				x, y := fmt.Sprintf("%T", objFacts[i]), fmt.Sprintf("%T", objFacts[j])
+				if x != y {
+					return x < y
+				}
+				return fmt.Sprint(objFacts[i]) < fmt.Sprint(objFacts[j])
+			})
+		}
+	}
+	return facts
+}
+
+// objectName returns the name of obj used by Facts.
+func objectName(obj types.Object) string {
+	switch {
+	case obj == nil:
+		return ""
+	case obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope():
+		return obj.Name()
+	}
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			t := recv.Type()
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			if named, ok := t.(*types.Named); ok {
+				return named.Obj().Name() + "." + fn.Name()
+			}
+		}
+	}
+	if path, err := objectpath.For(obj); err == nil {
+		return string(path)
+	}
+	return obj.Name()
+}
+
+// CheckFacts reports an error to the Testing for each object whose
+// facts, as computed by Facts(results), are not deeply equal to those
+// in want, which has the same structure. Only the packages present in
+// want are checked; within them, an object absent from want must have
+// no facts.
+func CheckFacts(t Testing, results []*Result, want map[string]map[string][]analysis.Fact) {
+	got := Facts(results)
+	for path, wantFacts := range want {
+		gotFacts, ok := got[path]
+		if !ok {
+			t.Errorf("no facts for package %s: it was not analyzed", path)
+			continue
+		}
+		names := make(map[string]bool)
+		for name := range gotFacts {
+			names[name] = true
+		}
+		for name := range wantFacts {
+			names[name] = true
+		}
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			g, w := gotFacts[name], wantFacts[name]
+			if len(g) == 0 && len(w) == 0 {
+				continue
+			}
+			if !reflect.DeepEqual(g, w) {
+				what := "object " + name
+				if name == "" {
+					what = "the package"
+				}
+				t.Errorf("%s: facts about %s: got %v, want %v", path, what, g, w)
+			}
+		}
+	}
+}
+
+// CheckResultJSON compares the JSON encoding of the result of an
+// analysis with the contents of the named golden file, and reports an
+// error to the Testing if they differ. If the -update flag is set, it
+// instead writes the encoding to the file (see RunTxtar).
+//
+// Only the exported fields of the result are compared, and the golden
+// file may be formatted in any way.
+func CheckResultJSON(t Testing, r *Result, filename string) {
+	data, err := json.MarshalIndent(r.Result, "", "\t")
+	if err != nil {
+		t.Errorf("encoding result of %s: %v", r.Pass, err)
+		return
+	}
+	data = append(data, '\n')
+
+	if updateGolden() {
+		if err := os.WriteFile(filename, data, 0666); err != nil {
+			t.Errorf("writing golden result: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(filename)
+	if err != nil {
+		t.Errorf("reading golden result: %v", err)
+		return
+	}
+	// Compare the decoded values, so that the golden
+	// file need not be formatted like the encoding.
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Errorf("decoding golden result %s: %v", filename, err)
+		return
+	}
+	json.Unmarshal(data, &gotValue) // can't fail
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		if indented, err := json.MarshalIndent(wantValue, "", "\t"); err == nil {
+			want = append(indented, '\n')
+		}
+		unified := diff.Unified(filename, "actual", string(want), string(data))
+		t.Errorf("result of %s does not match %s:\n%s", r.Pass, filename, unified)
+	}
+}
@@ -13,6 +13,20 @@ type SimpleDiagnostic struct {
 	End      SimplePosition // optional
 	Category string         // optional
 	Message  string
+
+	// URL is the optional location of a web page that provides
+	// additional documentation for this diagnostic.
+	// Drivers resolve it before the diagnostic is simplified,
+	// so it is never relative.
+	URL string
+
+	// SuggestedFixes is an optional list of alternative fixes
+	// for the problem; see Diagnostic.SuggestedFixes.
+	SuggestedFixes []SimpleSuggestedFix
+
+	// Related contains optional secondary positions and messages
+	// related to the primary diagnostic.
+	Related []SimpleRelatedInformation
 }
 
 // A SimplePosition is a simplified representation of token.Position for use in APIs that don't have access to
@@ -24,6 +38,30 @@ type SimplePosition struct {
 	Offset   int
 }
 
+// A SimpleRelatedInformation is a simplified representation of RelatedInformation.
+type SimpleRelatedInformation struct {
+	Pos     SimplePosition
+	End     SimplePosition // optional
+	Message string
+}
+
+// A SimpleSuggestedFix is a simplified representation of SuggestedFix.
+type SimpleSuggestedFix struct {
+	Message   string
+	TextEdits []SimpleTextEdit
+}
+
+// A SimpleTextEdit is a simplified representation of TextEdit.
+// It replaces the bytes [Start, End) of the named file with NewText.
+// Filename is the name of the file itself, unaffected by //line
+// directives.
+type SimpleTextEdit struct {
+	Filename string
+	Start    int // byte offset
+	End      int // byte offset
+	NewText  []byte
+}
+
 // A Diagnostic is a message associated with a source location or range.
 //
 // An Analyzer may return a variety of diagnostics; the optional Category,
@@ -69,24 +107,52 @@ type Diagnostic struct {
 	Related []RelatedInformation
 }
 
+// ToSimple returns the SimpleDiagnostic equivalent of d,
+// resolving all positions (including those of related information
+// and suggested fixes) using fset.
 func (d Diagnostic) ToSimple(fset *token.FileSet) SimpleDiagnostic {
-	pos := fset.Position(d.Pos)
-	end := fset.Position(d.End)
-	return SimpleDiagnostic{
-		Pos: SimplePosition{
-			Filename: pos.Filename,
-			Line:     pos.Line,
-			Column:   pos.Column,
-			Offset:   pos.Offset,
-		},
-		End: SimplePosition{
-			Filename: end.Filename,
-			Line:     end.Line,
-			Column:   end.Column,
-			Offset:   end.Offset,
-		},
+	sd := SimpleDiagnostic{
+		Pos:      simplePosition(fset, d.Pos),
+		End:      simplePosition(fset, d.End),
 		Category: d.Category,
 		Message:  d.Message,
+		URL:      d.URL,
+	}
+	for _, r := range d.Related {
+		sd.Related = append(sd.Related, SimpleRelatedInformation{
+			Pos:     simplePosition(fset, r.Pos),
+			End:     simplePosition(fset, r.End),
+			Message: r.Message,
+		})
+	}
+	for _, sf := range d.SuggestedFixes {
+		fix := SimpleSuggestedFix{Message: sf.Message}
+		for _, edit := range sf.TextEdits {
+			end := edit.End
+			if !end.IsValid() {
+				end = edit.Pos // pure insertion
+			}
+			// Edits apply to the file itself, regardless of //line directives.
+			start, stop := fset.PositionFor(edit.Pos, false), fset.PositionFor(end, false)
+			fix.TextEdits = append(fix.TextEdits, SimpleTextEdit{
+				Filename: start.Filename,
+				Start:    start.Offset,
+				End:      stop.Offset,
+				NewText:  edit.NewText,
+			})
+		}
+		sd.SuggestedFixes = append(sd.SuggestedFixes, fix)
+	}
+	return sd
+}
+
+func simplePosition(fset *token.FileSet, pos token.Pos) SimplePosition {
+	posn := fset.Position(pos)
+	return SimplePosition{
+		Filename: posn.Filename,
+		Line:     posn.Line,
+		Column:   posn.Column,
+		Offset:   posn.Offset,
 	}
 }
 
@@ -0,0 +1,40 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestToSimpleLineDirective(t *testing.T) {
+	const src = "package p\n\n//line gen.y:10\nvar x int\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := f.Scope.Lookup("x").Pos()
+	d := Diagnostic{
+		Pos:     pos,
+		Message: "x",
+		SuggestedFixes: []SuggestedFix{{
+			TextEdits: []TextEdit{{Pos: pos, End: pos + 1, NewText: []byte("y")}},
+		}},
+	}
+	sd := d.ToSimple(fset)
+
+	// The diagnostic is reported at the position given by the
+	// directive, but the edit applies to the file itself.
+	if sd.Pos.Filename != "gen.y" || sd.Pos.Line != 10 {
+		t.Errorf("Pos = %+v, want gen.y:10", sd.Pos)
+	}
+	edit := sd.SuggestedFixes[0].TextEdits[0]
+	offset := fset.File(pos).Offset(pos)
+	if edit.Filename != "p.go" || edit.Start != offset || edit.End != offset+1 {
+		t.Errorf("TextEdit = %+v, want p.go [%d, %d)", edit, offset, offset+1)
+	}
+}
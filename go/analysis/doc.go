@@ -269,6 +269,24 @@ pointed to by fact. This scheme assumes that the concrete type of fact
 is a pointer; this assumption is checked by the Validate function.
 See the "printf" analyzer for an example of object facts in action.
 
+A package may also record facts about the objects of its dependencies,
+such as "F is called from outside its package", which the analysis of
+the dependency cannot discover. Such facts are contributed rather than
+exported:
+
+	type Pass struct {
+		...
+		ContributeObjectFact func(types.Object, Fact)
+		AllContributedFacts  func() []ContributedFact
+	}
+
+Several packages may contribute facts of the same type about the same
+object, and each [ContributedFact] records the package that made it.
+The driver propagates contributed facts to the packages that import
+the contributor, directly or indirectly, and to the Finish function of
+a whole-program analyzer (see below), which sees the contributions of
+the entire program.
+
 Some driver implementations (such as those based on Bazel and Blaze) do
 not currently apply analyzers to packages of the standard library.
 Therefore, for best results, analyzer authors should not rely on
@@ -279,6 +297,22 @@ this fact is built in to the analyzer so that it correctly checks
 calls to log.Printf even when run in a driver that does not apply
 it to standard packages. We would like to remove this limitation in future.
 
+# Whole-program analysis
+
+Some checks, such as finding exported functions that no package uses,
+need a view of the entire program that no single pass has. An Analyzer
+with a Finish function is a whole-program analyzer: once its Run
+function has been applied to every requested package, the driver calls
+Finish once with a FinishPass, which holds the Result of each of those
+passes along with their syntax and type information, and provides
+access to all the facts the analyzer exported. Finish may report
+diagnostics about any of the packages.
+
+Drivers that see the whole program, such as those of the checker and
+programmaticchecker packages, and gopls, support whole-program
+analyzers. Drivers that analyze one package at a time, such as
+unitchecker (and thus "go vet"), reject them.
+
 # Testing an Analyzer
 
 The analysistest subpackage provides utilities for testing an Analyzer.
@@ -25,9 +25,15 @@ import (
 // flags common to all {single,multi,unit}checkers.
 var (
 	JSON    = false // -json
+	SARIF   = false // -sarif
 	Context = -1    // -c=N: if N>0, display offending line plus N lines of context
 )
 
+// KnownAnalyzers holds the names of all analyzers passed to Parse and
+// their prerequisites, including those disabled by flags, so that
+// drivers can tell a disabled analyzer from a nonexistent one.
+var KnownAnalyzers = make(map[string]bool)
+
 // Parse creates a flag for each of the analyzer's flags,
 // including (in multi mode) a flag named after the analyzer,
 // parses the flags, then filters and returns the list of
@@ -43,6 +49,7 @@ var (
 func Parse(analyzers []*analysis.Analyzer, multi bool) []*analysis.Analyzer {
 	// Connect each analysis flag to the command line as -analysis.flag.
 	enabled := make(map[*analysis.Analyzer]*triState)
+	analyzerFlags := make(map[string]*flag.Flag) // by command-line name
 	for _, a := range analyzers {
 		var prefix string
 
@@ -64,15 +71,18 @@ func Parse(analyzers []*analysis.Analyzer, multi bool) []*analysis.Analyzer {
 
 			name := prefix + f.Name
 			flag.Var(f.Value, name, f.Usage)
+			analyzerFlags[name] = f
 		})
 	}
 
-	// standard flags: -flags, -V.
+	// standard flags: -flags, -V, -mergesarif.
 	printflags := flag.Bool("flags", false, "print analyzer flags in JSON")
 	addVersionFlag()
+	mergesarif := flag.Bool("mergesarif", false, "merge the SARIF logs on standard input, such as the output of 'go vet -sarif', into one log")
 
 	// flags common to all checkers
 	flag.BoolVar(&JSON, "json", JSON, "emit JSON output")
+	flag.BoolVar(&SARIF, "sarif", SARIF, "emit SARIF 2.1.0 output")
 	flag.IntVar(&Context, "c", Context, `display offending line with this many lines of context`)
 
 	// Add shims for legacy vet flags to enable existing
@@ -90,13 +100,38 @@ func Parse(analyzers []*analysis.Analyzer, multi bool) []*analysis.Analyzer {
 
 	flag.Parse() // (ExitOnError)
 
+	// Analyzer flags set on the command line take
+	// precedence over configuration files.
+	flag.Visit(func(f *flag.Flag) {
+		if af, ok := analyzerFlags[f.Name]; ok {
+			explicitFlags[af] = true
+		}
+	})
+
 	// -flags: print flags so that go vet knows which ones are legitimate.
 	if *printflags {
 		printFlags()
 		os.Exit(0)
 	}
 
+	// -mergesarif: combine the per-package logs of go vet.
+	if *mergesarif {
+		merged, err := MergeSARIF(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		merged.Print()
+		os.Exit(0)
+	}
+
+	if JSON && SARIF {
+		log.Fatal("-json and -sarif may not be used together")
+	}
+
 	everything := expand(analyzers)
+	for a := range everything {
+		KnownAnalyzers[a.Name] = true
+	}
 
 	// If any -NAME flag is true,  run only those analyzers. Otherwise,
 	// if any -NAME flag is false, run all but those analyzers.
@@ -167,10 +202,10 @@ func printFlags() {
 	var flags []jsonFlag = nil
 	flag.VisitAll(func(f *flag.Flag) {
 		// Don't report {single,multi}checker debugging
-		// flags or fix as these have no effect on unitchecker
-		// (as invoked by 'go vet').
+		// flags, fix, baseline or mergesarif as these have no effect
+		// on unitchecker (as invoked by 'go vet').
 		switch f.Name {
-		case "debug", "cpuprofile", "memprofile", "trace", "fix":
+		case "debug", "cpuprofile", "memprofile", "trace", "fix", "diff", "baseline", "writebaseline", "mergesarif":
 			return
 		}
 
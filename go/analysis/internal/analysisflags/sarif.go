@@ -0,0 +1,394 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+// This file defines the -sarif output format, a subset of the
+// Static Analysis Results Interchange Format (SARIF) version 2.1.0:
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// A SARIFLog is the root object of a SARIF log file.
+//
+// Each log produced by a driver holds a single run. Logs from separate
+// invocations of the same tool, such as one per package under "go vet",
+// may be combined by MergeSARIF, which drivers expose as the
+// -mergesarif flag.
+type SARIFLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+// A SARIFRun describes a single invocation of an analysis tool.
+type SARIFRun struct {
+	Tool        SARIFTool         `json:"tool"`
+	Invocations []SARIFInvocation `json:"invocations"`
+	Results     []SARIFResult     `json:"results"`
+
+	ruleIndex map[string]int  // maps rule ID to index in Tool.Driver.Rules
+	seen      map[string]bool // JSON encodings of Results
+}
+
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+type SARIFDriver struct {
+	Name  string      `json:"name"`
+	Rules []SARIFRule `json:"rules,omitempty"`
+}
+
+// A SARIFRule describes a kind of diagnostic. There is one rule for
+// each analyzer and category of diagnostic that it reports, whose ID
+// is the analyzer name followed by "/" and the category, if any.
+type SARIFRule struct {
+	ID               string        `json:"id"`
+	Name             string        `json:"name"`
+	ShortDescription *SARIFMessage `json:"shortDescription,omitempty"`
+	FullDescription  *SARIFMessage `json:"fullDescription,omitempty"`
+	HelpURI          string        `json:"helpUri,omitempty"`
+}
+
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// A SARIFInvocation records whether the analysis succeeded,
+// and any errors that prevented it from doing so.
+type SARIFInvocation struct {
+	ExecutionSuccessful bool                `json:"executionSuccessful"`
+	Notifications       []SARIFNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type SARIFNotification struct {
+	Level   string       `json:"level"`
+	Message SARIFMessage `json:"message"`
+}
+
+type SARIFResult struct {
+	RuleID           string          `json:"ruleId"`
+	RuleIndex        int             `json:"ruleIndex"`
+	Level            string          `json:"level"`
+	Message          SARIFMessage    `json:"message"`
+	Locations        []SARIFLocation `json:"locations,omitempty"`
+	RelatedLocations []SARIFLocation `json:"relatedLocations,omitempty"`
+	Fixes            []SARIFFix      `json:"fixes,omitempty"`
+}
+
+type SARIFLocation struct {
+	ID               int                    `json:"id,omitempty"`
+	PhysicalLocation *SARIFPhysicalLocation `json:"physicalLocation,omitempty"`
+	Message          *SARIFMessage          `json:"message,omitempty"`
+}
+
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           *SARIFRegion          `json:"region,omitempty"`
+}
+
+type SARIFArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// A SARIFRegion is a portion of a file. Lines are 1-based.
+// Columns are 1-based byte offsets within the line, which agree with
+// the SARIF default of UTF-16 code units only for ASCII text;
+// ByteOffset and ByteLength are exact.
+type SARIFRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+	ByteOffset  int `json:"byteOffset"`
+	ByteLength  int `json:"byteLength"`
+}
+
+type SARIFFix struct {
+	Description     SARIFMessage          `json:"description"`
+	ArtifactChanges []SARIFArtifactChange `json:"artifactChanges"`
+}
+
+type SARIFArtifactChange struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Replacements     []SARIFReplacement    `json:"replacements"`
+}
+
+type SARIFReplacement struct {
+	DeletedRegion   SARIFRegion   `json:"deletedRegion"`
+	InsertedContent *SARIFMessage `json:"insertedContent,omitempty"`
+}
+
+// NewSARIFLog returns a log with a single successful run of the named tool.
+func NewSARIFLog(tool string) *SARIFLog {
+	return &SARIFLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []SARIFRun{{
+			Tool:        SARIFTool{Driver: SARIFDriver{Name: tool}},
+			Invocations: []SARIFInvocation{{ExecutionSuccessful: true}},
+			Results:     []SARIFResult{},
+		}},
+	}
+}
+
+// Add adds the outcome of applying analyzer a to package id to the
+// log's run. The outcome is either a list of diagnostics or an error.
+func (sl *SARIFLog) Add(fset *token.FileSet, id string, a *analysis.Analyzer, diags []analysis.Diagnostic, err error) {
+	run := &sl.Runs[0]
+	if err != nil {
+		inv := &run.Invocations[0]
+		inv.ExecutionSuccessful = false
+		inv.Notifications = append(inv.Notifications, SARIFNotification{
+			Level:   "error",
+			Message: SARIFMessage{Text: fmt.Sprintf("%s: %s: %v", id, a.Name, err)},
+		})
+		return
+	}
+	for _, diag := range diags {
+		index := run.rule(a, diag)
+		result := SARIFResult{
+			RuleID:    run.Tool.Driver.Rules[index].ID,
+			RuleIndex: index,
+			Level:     "warning",
+			Message:   SARIFMessage{Text: diag.Message},
+			Locations: []SARIFLocation{{PhysicalLocation: sarifPhysicalLocation(fset, diag.Pos, diag.End)}},
+		}
+		for i, r := range diag.Related {
+			result.RelatedLocations = append(result.RelatedLocations, SARIFLocation{
+				ID:               i + 1,
+				PhysicalLocation: sarifPhysicalLocation(fset, r.Pos, r.End),
+				Message:          &SARIFMessage{Text: r.Message},
+			})
+		}
+		for _, fix := range diag.SuggestedFixes {
+			result.Fixes = append(result.Fixes, sarifFix(fset, fix))
+		}
+		run.addResult(result)
+	}
+}
+
+// addResult adds a result to the run, unless it duplicates one
+// already present, as happens for files that belong to several
+// packages, such as p and p.test.
+func (run *SARIFRun) addResult(result SARIFResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Panicf("internal error: encoding SARIF result: %v", err)
+	}
+	if run.seen == nil {
+		run.seen = make(map[string]bool)
+		for _, r := range run.Results {
+			data, _ := json.Marshal(r)
+			run.seen[string(data)] = true
+		}
+	}
+	if !run.seen[string(data)] {
+		run.seen[string(data)] = true
+		run.Results = append(run.Results, result)
+	}
+}
+
+// rule returns the index of the rule for a diagnostic, adding it if necessary.
+func (run *SARIFRun) rule(a *analysis.Analyzer, diag analysis.Diagnostic) int {
+	id := a.Name
+	if diag.Category != "" {
+		id += "/" + diag.Category
+	}
+	if run.ruleIndex == nil {
+		run.ruleIndex = make(map[string]int)
+		for i, rule := range run.Tool.Driver.Rules {
+			run.ruleIndex[rule.ID] = i
+		}
+	}
+	if i, ok := run.ruleIndex[id]; ok {
+		return i
+	}
+	rule := SARIFRule{ID: id, Name: a.Name}
+	if a.Doc != "" {
+		short, _, _ := strings.Cut(a.Doc, "\n\n")
+		rule.ShortDescription = &SARIFMessage{Text: strings.Join(strings.Fields(short), " ")}
+		rule.FullDescription = &SARIFMessage{Text: a.Doc}
+	}
+	// The help of a category rule is the URL of the category,
+	// which is independent of any particular diagnostic.
+	if u, err := ResolveURL(a, analysis.Diagnostic{Category: diag.Category}); err == nil {
+		rule.HelpURI = u
+	}
+	i := len(run.Tool.Driver.Rules)
+	run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	run.ruleIndex[id] = i
+	return i
+}
+
+func sarifPhysicalLocation(fset *token.FileSet, pos, end token.Pos) *SARIFPhysicalLocation {
+	if !pos.IsValid() {
+		return nil
+	}
+	posn := fset.Position(pos)
+	region := &SARIFRegion{
+		StartLine:   posn.Line,
+		StartColumn: posn.Column,
+		ByteOffset:  posn.Offset,
+	}
+	if end.IsValid() && end >= pos {
+		endPosn := fset.Position(end)
+		region.EndLine = endPosn.Line
+		region.EndColumn = endPosn.Column
+		region.ByteLength = endPosn.Offset - posn.Offset
+	}
+	return &SARIFPhysicalLocation{
+		ArtifactLocation: SARIFArtifactLocation{URI: sarifURI(posn.Filename)},
+		Region:           region,
+	}
+}
+
+func sarifFix(fset *token.FileSet, fix analysis.SuggestedFix) SARIFFix {
+	var changes []SARIFArtifactChange
+	byFile := make(map[string]int) // index in changes
+	for _, edit := range fix.TextEdits {
+		// Edits apply to the file itself, regardless of //line directives.
+		start, end := fset.PositionFor(edit.Pos, false), fset.PositionFor(edit.Pos, false)
+		if edit.End.IsValid() {
+			end = fset.PositionFor(edit.End, false)
+		}
+		i, ok := byFile[start.Filename]
+		if !ok {
+			i = len(changes)
+			byFile[start.Filename] = i
+			changes = append(changes, SARIFArtifactChange{
+				ArtifactLocation: SARIFArtifactLocation{URI: sarifURI(start.Filename)},
+			})
+		}
+		repl := SARIFReplacement{
+			DeletedRegion: SARIFRegion{ByteOffset: start.Offset, ByteLength: end.Offset - start.Offset},
+		}
+		if len(edit.NewText) > 0 {
+			repl.InsertedContent = &SARIFMessage{Text: string(edit.NewText)}
+		}
+		changes[i].Replacements = append(changes[i].Replacements, repl)
+	}
+	return SARIFFix{
+		Description:     SARIFMessage{Text: fix.Message},
+		ArtifactChanges: changes,
+	}
+}
+
+// sarifURI returns the file URI for the named file.
+func sarifURI(filename string) string {
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	path := filepath.ToSlash(filename)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows drive letter
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// Print prints the log to standard output.
+func (sl *SARIFLog) Print() {
+	if err := sl.Write(os.Stdout); err != nil {
+		log.Panicf("internal error: SARIF output failed: %v", err)
+	}
+}
+
+// Write writes the log to w in JSON form.
+func (sl *SARIFLog) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(sl, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// MergeSARIF reads a sequence of SARIF logs, such as the output of
+// "go vet -sarif", and combines them into a single log. Runs of the
+// same tool are merged into one run, and duplicate results, such as
+// those for the files of both p and p.test, are dropped. Lines
+// beginning with "#", which "go vet" uses to introduce the output for
+// each package, are ignored.
+func MergeSARIF(r io.Reader) (*SARIFLog, error) {
+	// Discard comment lines.
+	var buf bytes.Buffer
+	scan := bufio.NewScanner(r)
+	scan.Buffer(nil, 1<<30)
+	for scan.Scan() {
+		if !strings.HasPrefix(scan.Text(), "#") {
+			buf.Write(scan.Bytes())
+			buf.WriteByte('\n')
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+
+	merged := &SARIFLog{Schema: sarifSchema, Version: sarifVersion, Runs: []SARIFRun{}}
+	runs := make(map[string]*SARIFRun) // by tool name
+	var order []string
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var in SARIFLog
+		if err := dec.Decode(&in); err != nil {
+			return nil, fmt.Errorf("decoding SARIF log: %v", err)
+		}
+		for _, run := range in.Runs {
+			name := run.Tool.Driver.Name
+			out, ok := runs[name]
+			if !ok {
+				out = &SARIFRun{
+					Tool:        SARIFTool{Driver: SARIFDriver{Name: name}},
+					Invocations: []SARIFInvocation{{ExecutionSuccessful: true}},
+					Results:     []SARIFResult{},
+					ruleIndex:   make(map[string]int),
+				}
+				runs[name] = out
+				order = append(order, name)
+			}
+			for _, inv := range run.Invocations {
+				out.Invocations[0].ExecutionSuccessful = out.Invocations[0].ExecutionSuccessful && inv.ExecutionSuccessful
+				out.Invocations[0].Notifications = append(out.Invocations[0].Notifications, inv.Notifications...)
+			}
+			for _, result := range run.Results {
+				if result.RuleIndex < 0 || result.RuleIndex >= len(run.Tool.Driver.Rules) {
+					return nil, fmt.Errorf("SARIF result has invalid rule index %d", result.RuleIndex)
+				}
+				rule := run.Tool.Driver.Rules[result.RuleIndex]
+				i, ok := out.ruleIndex[rule.ID]
+				if !ok {
+					i = len(out.Tool.Driver.Rules)
+					out.Tool.Driver.Rules = append(out.Tool.Driver.Rules, rule)
+					out.ruleIndex[rule.ID] = i
+				}
+				result.RuleIndex = i
+				out.addResult(result)
+			}
+		}
+	}
+	for _, name := range order {
+		merged.Runs = append(merged.Runs, *runs[name])
+	}
+	return merged, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags_test
+
+import (
+	"bytes"
+	"errors"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/internal/analysisflags"
+)
+
+func TestSARIF(t *testing.T) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("/tmp/a.go", -1, 100)
+	file.SetLines([]int{0, 10, 20, 30})
+	pos := func(offset int) token.Pos { return file.Pos(offset) }
+
+	a := &analysis.Analyzer{
+		Name: "a",
+		Doc:  "a checks\nthings.\n\nMore detail.",
+		URL:  "https://a.example",
+	}
+	diags := []analysis.Diagnostic{
+		{
+			Pos:      pos(12),
+			End:      pos(15),
+			Category: "cat",
+			Message:  "first",
+			Related:  []analysis.RelatedInformation{{Pos: pos(22), End: pos(23), Message: "here"}},
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   "fix it",
+				TextEdits: []analysis.TextEdit{{Pos: pos(12), End: pos(15), NewText: []byte("xyz")}},
+			}},
+		},
+		{Pos: pos(1), Message: "second"},
+		{Pos: pos(2), Category: "cat", Message: "third"},
+	}
+
+	log := analysisflags.NewSARIFLog("vet")
+	log.Add(fset, "p", a, diags, nil)
+	run := log.Runs[0]
+
+	if got, want := len(run.Tool.Driver.Rules), 2; got != want {
+		t.Fatalf("got %d rules, want %d", got, want)
+	}
+	rule := run.Tool.Driver.Rules[0]
+	if rule.ID != "a/cat" || rule.HelpURI != "https://a.example#cat" || rule.ShortDescription.Text != "a checks things." {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+	if got := run.Results[2].RuleIndex; got != 0 {
+		t.Errorf("third result has rule index %d, want 0", got)
+	}
+
+	res := run.Results[0]
+	region := res.Locations[0].PhysicalLocation.Region
+	if region.StartLine != 2 || region.StartColumn != 3 || region.ByteOffset != 12 || region.ByteLength != 3 {
+		t.Errorf("unexpected region: %+v", region)
+	}
+	if uri := res.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "file:///tmp/a.go" {
+		t.Errorf("got URI %q, want file:///tmp/a.go", uri)
+	}
+	if len(res.RelatedLocations) != 1 || res.RelatedLocations[0].Message.Text != "here" {
+		t.Errorf("unexpected related locations: %+v", res.RelatedLocations)
+	}
+	repl := res.Fixes[0].ArtifactChanges[0].Replacements[0]
+	if repl.DeletedRegion.ByteOffset != 12 || repl.DeletedRegion.ByteLength != 3 || repl.InsertedContent.Text != "xyz" {
+		t.Errorf("unexpected replacement: %+v", repl)
+	}
+
+	// Simulate "go vet -sarif" output for two packages,
+	// one of which failed, and which share a file, as do p and
+	// p.test, so that one diagnostic is reported by both.
+	var buf bytes.Buffer
+	buf.WriteString("# p\n")
+	if err := log.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	log2 := analysisflags.NewSARIFLog("vet")
+	log2.Add(fset, "q", a, []analysis.Diagnostic{diags[1], {Pos: pos(3), Message: "fourth"}}, nil)
+	log2.Add(fset, "q", &analysis.Analyzer{Name: "b"}, nil, errors.New("oops"))
+	buf.WriteString("# q\n")
+	if err := log2.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := analysisflags.MergeSARIF(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(merged.Runs))
+	}
+	mrun := merged.Runs[0]
+	if len(mrun.Results) != 4 || len(mrun.Tool.Driver.Rules) != 2 {
+		t.Fatalf("got %d results and %d rules, want 4 and 2", len(mrun.Results), len(mrun.Tool.Driver.Rules))
+	}
+	if got := mrun.Tool.Driver.Rules[mrun.Results[3].RuleIndex].ID; got != "a" || mrun.Results[3].Message.Text != "fourth" {
+		t.Errorf("last merged result is %q with rule %q, want fourth with rule a", mrun.Results[3].Message.Text, got)
+	}
+	inv := mrun.Invocations[0]
+	if inv.ExecutionSuccessful || len(inv.Notifications) != 1 || !strings.Contains(inv.Notifications[0].Message.Text, "q: b: oops") {
+		t.Errorf("unexpected invocation: %+v", inv)
+	}
+}
+
+func TestSARIFLineDirective(t *testing.T) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("/tmp/a.go", -1, 100)
+	file.SetLines([]int{0, 10, 20, 30})
+	file.AddLineColumnInfo(10, "/tmp/gen.y", 100, 1)
+	pos := file.Pos(12)
+
+	a := &analysis.Analyzer{Name: "a", Doc: "a checks things."}
+	log := analysisflags.NewSARIFLog("vet")
+	log.Add(fset, "p", a, []analysis.Diagnostic{{
+		Pos:     pos,
+		Message: "generated",
+		SuggestedFixes: []analysis.SuggestedFix{{
+			TextEdits: []analysis.TextEdit{{Pos: pos, End: pos + 3, NewText: []byte("xyz")}},
+		}},
+	}}, nil)
+	res := log.Runs[0].Results[0]
+
+	// The diagnostic is reported at the position given by the
+	// directive, but the fix applies to the file itself.
+	if uri := res.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "file:///tmp/gen.y" {
+		t.Errorf("got location URI %q, want file:///tmp/gen.y", uri)
+	}
+	change := res.Fixes[0].ArtifactChanges[0]
+	if uri := change.ArtifactLocation.URI; uri != "file:///tmp/a.go" {
+		t.Errorf("got fix URI %q, want file:///tmp/a.go", uri)
+	}
+	if region := change.Replacements[0].DeletedRegion; region.ByteOffset != 12 || region.ByteLength != 3 {
+		t.Errorf("unexpected deleted region: %+v", region)
+	}
+}
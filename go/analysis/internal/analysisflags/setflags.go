@@ -0,0 +1,50 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+// This file sets analyzer flags from the settings of analysis
+// configuration files (see analysisinternal.Config).
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+)
+
+// explicitFlags records the analyzer flags that were set on the
+// command line, which take precedence over configuration settings.
+var explicitFlags = make(map[*flag.Flag]bool)
+
+// SetFlags sets the flags of analyzer a to the specified values, by
+// flag name, except for flags set on the command line. It returns a
+// function that restores the previous values. Since the flags of an
+// analyzer are shared by all of its passes, callers must ensure that
+// no pass of a is running concurrently.
+func SetFlags(a *analysis.Analyzer, values map[string]string) (restore func(), err error) {
+	var undo []func()
+	restore = func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+	for name, value := range values {
+		f := a.Flags.Lookup(name)
+		if f == nil {
+			restore()
+			return nil, fmt.Errorf("analyzer %s has no flag %q", a.Name, name)
+		}
+		if explicitFlags[f] {
+			continue
+		}
+		old := f.Value.String()
+		if err := a.Flags.Set(name, value); err != nil {
+			restore()
+			return nil, fmt.Errorf("invalid value %q for flag %s.%s: %v", value, a.Name, name, err)
+		}
+		undo = append(undo, func() { a.Flags.Set(name, old) })
+	}
+	return restore, nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags_test
+
+import (
+	"testing"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/internal/analysisflags"
+)
+
+func TestSetFlags(t *testing.T) {
+	a := &analysis.Analyzer{Name: "a"}
+	n := a.Flags.Int("n", 1, "a number")
+
+	restore, err := analysisflags.SetFlags(a, map[string]string{"n": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *n != 2 {
+		t.Errorf("after SetFlags, n = %d, want 2", *n)
+	}
+	restore()
+	if *n != 1 {
+		t.Errorf("after restore, n = %d, want 1", *n)
+	}
+
+	if _, err := analysisflags.SetFlags(a, map[string]string{"n": "x"}); err == nil {
+		t.Error("SetFlags with an invalid value succeeded")
+	}
+	if _, err := analysisflags.SetFlags(a, map[string]string{"m": "1"}); err == nil {
+		t.Error("SetFlags with an unknown flag succeeded")
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file defines baselines (see WithBaseline), which allow an
+// analyzer to be enabled on a code base with many existing findings
+// by suppressing those findings while still reporting new ones.
+//
+// A baseline records each diagnostic by its analyzer, category, file,
+// enclosing function and message, but not its line and column, so
+// that its entries survive unrelated edits to the file. Because the
+// same key may legitimately occur several times (for example, two
+// identical mistakes in one function), each entry also has a count,
+// and a run suppresses at most that many matching diagnostics.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+)
+
+// baselineVersion must be incremented whenever the file format changes.
+const baselineVersion = 1
+
+// A BaselineEntry identifies a diagnostic recorded in a baseline file.
+type BaselineEntry struct {
+	Analyzer string `json:"analyzer"`
+	Category string `json:"category,omitempty"`
+	File     string `json:"file"`               // slash-separated, relative to the baseline file
+	Function string `json:"function,omitempty"` // e.g. "F" or "T.M"; empty outside functions
+	Message  string `json:"message"`
+	Count    int    `json:"count"`
+}
+
+func (e BaselineEntry) String() string {
+	s := e.File + ": " + e.Analyzer
+	if e.Category != "" {
+		s += "/" + e.Category
+	}
+	if e.Function != "" {
+		s += " in " + e.Function
+	}
+	return s + ": " + e.Message
+}
+
+// A baselineFile is the JSON encoding of a baseline.
+type baselineFile struct {
+	Version int             `json:"version"`
+	Entries []BaselineEntry `json:"entries"`
+}
+
+// readBaseline reads the named baseline file and returns the count of
+// each of its entries, keyed by the entry with a zero count.
+func readBaseline(filename string) (map[BaselineEntry]int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline: %w", err)
+	}
+	var bf baselineFile
+	if err := json.Unmarshal(data, &bf); err != nil {
+		return nil, fmt.Errorf("decoding baseline %s: %v", filename, err)
+	}
+	if bf.Version != baselineVersion {
+		return nil, fmt.Errorf("baseline %s has version %d, want %d", filename, bf.Version, baselineVersion)
+	}
+	counts := make(map[BaselineEntry]int)
+	for _, e := range bf.Entries {
+		n := e.Count
+		e.Count = 0
+		counts[e] += n
+	}
+	return counts, nil
+}
+
+// writeBaseline writes a baseline file containing the given entries.
+func writeBaseline(filename string, counts map[BaselineEntry]int) error {
+	bf := baselineFile{Version: baselineVersion, Entries: sortedEntries(counts)}
+	data, err := json.MarshalIndent(bf, "", "\t")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(filename, data, 0666); err != nil {
+		return fmt.Errorf("writing baseline: %v", err)
+	}
+	return nil
+}
+
+// sortedEntries returns the entries with positive counts in a
+// deterministic order, so that baseline files diff well.
+func sortedEntries(counts map[BaselineEntry]int) []BaselineEntry {
+	entries := []BaselineEntry{}
+	for e, n := range counts {
+		if n > 0 {
+			e.Count = n
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		x, y := entries[i], entries[j]
+		if x.File != y.File {
+			return x.File < y.File
+		}
+		if x.Function != y.Function {
+			return x.Function < y.Function
+		}
+		if x.Analyzer != y.Analyzer {
+			return x.Analyzer < y.Analyzer
+		}
+		if x.Category != y.Category {
+			return x.Category < y.Category
+		}
+		return x.Message < y.Message
+	})
+	return entries
+}
+
+// applyBaseline removes from the root actions the diagnostics that are
+// recorded in the baseline file, if any, or in update mode records all
+// of them in the baseline file.
+//
+// It returns the stale baseline entries: those that match no current
+// diagnostic, although the run successfully analyzed their file with
+// their analyzer. Entries for other files and analyzers, or for
+// actions that failed, are not considered stale, so that a baseline
+// may be used by runs over a subset of the packages for which it was
+// written. For the same reason, in update mode such entries are kept
+// in the rewritten file (which need not exist beforehand).
+func applyBaseline(roots []*action, opts *checkerOptions) ([]BaselineEntry, error) {
+	filename, err := filepath.Abs(opts.baseline)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(filename)
+
+	// analyzed records the (analyzer, file) pairs of the
+	// successful actions of the run, whose existing entries
+	// are either matched or stale.
+	type pair struct{ analyzer, file string }
+	analyzed := make(map[pair]bool)
+	for _, act := range roots {
+		if act.err == nil {
+			for _, f := range act.pkg.CompiledGoFiles {
+				analyzed[pair{act.a.Name, baselinePath(dir, f)}] = true
+			}
+		}
+	}
+
+	counts, err := readBaseline(filename)
+	if opts.updateBaseline {
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		// Replace the entries for the pairs analyzed by the run.
+		if counts == nil {
+			counts = make(map[BaselineEntry]int)
+		}
+		for e := range counts {
+			if analyzed[pair{e.Analyzer, e.File}] {
+				delete(counts, e)
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// A file that belongs to several packages, such as foo and
+	// foo.test, yields the same diagnostic for each of them. Count
+	// it once, and treat all of its copies alike.
+	type key struct {
+		pos, end token.Position
+		*analysis.Analyzer
+		message string
+	}
+	suppressed := make(map[key]bool)
+
+	for _, act := range roots {
+		if act.err != nil {
+			continue
+		}
+
+		var kept []analysis.Diagnostic
+		for _, diag := range act.diagnostics {
+			posn := act.pkg.Fset.Position(diag.Pos)
+			k := key{posn, act.pkg.Fset.Position(diag.End), act.a, diag.Message}
+			drop, seen := suppressed[k]
+			if !seen {
+				// Ignore //line directives, so that the entry
+				// names the same file as the analyzed pairs.
+				file := act.pkg.Fset.PositionFor(diag.Pos, false).Filename
+				e := BaselineEntry{
+					Analyzer: act.a.Name,
+					Category: diag.Category,
+					File:     baselinePath(dir, file),
+					Function: enclosingFunc(act, diag.Pos),
+					Message:  diag.Message,
+				}
+				if opts.updateBaseline {
+					counts[e]++
+					drop = true
+				} else if counts[e] > 0 {
+					counts[e]--
+					drop = true
+				}
+				suppressed[k] = drop
+			}
+			if !drop {
+				kept = append(kept, diag)
+			}
+		}
+		act.diagnostics = kept
+	}
+
+	if opts.updateBaseline {
+		return nil, writeBaseline(filename, counts)
+	}
+
+	for e := range counts {
+		if !analyzed[pair{e.Analyzer, e.File}] {
+			delete(counts, e)
+		}
+	}
+	return sortedEntries(counts), nil
+}
+
+// baselinePath returns the slash-separated path of the named file
+// relative to dir, or its absolute path if it is not beneath dir.
+func baselinePath(dir, filename string) string {
+	if rel, err := filepath.Rel(dir, filename); err == nil && filepath.IsLocal(rel) {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filename)
+}
+
+// enclosingFunc returns the name of the function or method declaration
+// of act's package that encloses pos, or "" if there is none.
+func enclosingFunc(act *action, pos token.Pos) string {
+	tf := act.pkg.Fset.File(pos)
+	if tf == nil {
+		return ""
+	}
+	for _, f := range act.pkg.Syntax {
+		if act.pkg.Fset.File(f.Pos()) != tf {
+			continue
+		}
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || pos < decl.Pos() || pos >= decl.End() {
+				continue
+			}
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				return recvTypeName(decl.Recv.List[0].Type) + "." + decl.Name.Name
+			}
+			return decl.Name.Name
+		}
+	}
+	return ""
+}
+
+// recvTypeName returns the name of the named type of a method receiver.
+func recvTypeName(t ast.Expr) string {
+	for {
+		switch e := t.(type) {
+		case *ast.ParenExpr:
+			t = e.X
+		case *ast.StarExpr:
+			t = e.X
+		case *ast.IndexExpr:
+			t = e.X
+		case *ast.IndexListExpr:
+			t = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,631 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file defines the persistent cache of analysis summaries used
+// by incremental runs (see WithCacheDir).
+//
+// Each action (the application of one analyzer to one package) is
+// keyed by a hash of the analyzer's identity (see analyzerIDs) and
+// flags, the contents of the package's files, the go.mod information
+// of its module, the keys of the packages it imports, and the keys of
+// the actions it depends on. The key thus changes whenever any
+// input to the action changes, including the facts of its
+// dependencies, in the manner of a Merkle tree. (This is the same
+// scheme used by gopls' analysis cache.)
+//
+// A summary records the action's diagnostics, the facts it exported
+// about its own package, and its result. An action whose summary is
+// found in the cache does not run, nor do its same-package
+// prerequisites; only its dependencies on other packages are
+// executed, so that it can inherit their facts.
+//
+// Summaries are written only for successful actions on well-typed
+// packages whose diagnostics, facts and result can all be faithfully
+// gob-encoded. Other actions always run.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/packages"
+	"github.com/TBD54566975/golang-tools/go/types/objectpath"
+)
+
+// cacheVersion must be incremented whenever the summary encoding changes.
+const cacheVersion = 2
+
+// A cacheKey identifies the inputs to an action.
+type cacheKey [sha256.Size]byte
+
+// computeKeys computes the cache key of every action in the graph
+// rooted at roots. It returns an error if some input could not be
+// read, in which case the cache cannot be used.
+func computeKeys(roots []*action, opts *checkerOptions) error {
+	exe, err := executableHash()
+	if err != nil {
+		return err
+	}
+
+	pkgKeys := make(map[*packages.Package]cacheKey)
+	var pkgKey func(pkg *packages.Package) (cacheKey, error)
+	pkgKey = func(pkg *packages.Package) (cacheKey, error) {
+		if key, ok := pkgKeys[pkg]; ok {
+			return key, nil
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "id %q path %q sizes %v\n", pkg.ID, pkg.PkgPath, pkg.TypesSizes)
+		for _, list := range [][]string{pkg.CompiledGoFiles, pkg.OtherFiles, pkg.IgnoredFiles, pkg.EmbedFiles} {
+			fmt.Fprintf(h, "files %d\n", len(list))
+			for _, filename := range list {
+				content, err := opts.readFile(filename)
+				if err != nil {
+					return cacheKey{}, err
+				}
+				fmt.Fprintf(h, "file %q %x\n", filename, sha256.Sum256(content))
+			}
+		}
+		paths := make([]string, 0, len(pkg.Imports))
+		for path := range pkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths) // for determinism
+		for _, path := range paths {
+			key, err := pkgKey(pkg.Imports[path])
+			if err != nil {
+				return cacheKey{}, err
+			}
+			fmt.Fprintf(h, "import %q %x\n", path, key)
+		}
+		var key cacheKey
+		h.Sum(key[:0])
+		pkgKeys[pkg] = key
+		return key, nil
+	}
+
+	ids := analyzerIDs(roots)
+	done := make(map[*action]bool)
+	var visit func(act *action) error
+	visit = func(act *action) error {
+		if done[act] {
+			return nil
+		}
+		done[act] = true
+		h := sha256.New()
+		fmt.Fprintf(h, "version %d exe %x\n", cacheVersion, exe)
+		fmt.Fprintf(h, "analyzer %q\n", ids[act.a])
+		act.a.Flags.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(h, "flag %q %q\n", f.Name, f.Value.String())
+		})
+		if s := opts.settings[act.pkg]; s != nil {
+			values := s.Flags[act.a.Name]
+			names := make([]string, 0, len(values))
+			for name := range values {
+				names = append(names, name)
+			}
+			sort.Strings(names) // for determinism
+			for _, name := range names {
+				fmt.Fprintf(h, "config flag %q %q\n", name, values[name])
+			}
+		}
+		key, err := pkgKey(act.pkg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "package %x\n", key)
+		if act.module != nil {
+			fmt.Fprintf(h, "module %+v\n", *act.module)
+		}
+		for _, dep := range act.deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "dep %x\n", dep.key)
+		}
+		h.Sum(act.key[:0])
+
+		// Summaries hold facts in gob form.
+		for _, f := range act.a.FactTypes {
+			gob.Register(f)
+		}
+		return nil
+	}
+	for _, root := range roots {
+		if err := visit(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// analyzerIDs returns a string for each analyzer in the graph rooted
+// at roots that distinguishes it from other analyzers: a description
+// of its name, documentation, Run function, fact and result types,
+// and requirements. Analyzers that this does not distinguish, such as
+// ones created by the same function with different captured state,
+// are numbered in the order in which the graph first reaches them.
+func analyzerIDs(roots []*action) map[*analysis.Analyzer]string {
+	descs := make(map[*analysis.Analyzer]string)
+	var describe func(a *analysis.Analyzer) string
+	describe = func(a *analysis.Analyzer) string {
+		if desc, ok := descs[a]; ok {
+			return desc
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "name %q doc %q url %q\n", a.Name, a.Doc, a.URL)
+		if a.Run != nil {
+			fmt.Fprintf(h, "run %s\n", runtime.FuncForPC(reflect.ValueOf(a.Run).Pointer()).Name())
+		}
+		fmt.Fprintf(h, "despite errors %t result %v\n", a.RunDespiteErrors, a.ResultType)
+		for _, f := range a.FactTypes {
+			fmt.Fprintf(h, "fact %v\n", reflect.TypeOf(f))
+		}
+		for _, req := range a.Requires {
+			fmt.Fprintf(h, "requires %s\n", describe(req))
+		}
+		desc := fmt.Sprintf("%s %x", a.Name, h.Sum(nil))
+		descs[a] = desc
+		return desc
+	}
+
+	ids := make(map[*analysis.Analyzer]string)
+	count := make(map[string]int) // number of analyzers with each description
+	seen := make(map[*action]bool)
+	var visit func(act *action)
+	visit = func(act *action) {
+		if seen[act] {
+			return
+		}
+		seen[act] = true
+		if _, ok := ids[act.a]; !ok {
+			desc := describe(act.a)
+			ids[act.a] = fmt.Sprintf("%s #%d", desc, count[desc])
+			count[desc]++
+		}
+		for _, dep := range act.deps {
+			visit(dep)
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	return ids
+}
+
+var (
+	executableHashOnce sync.Once
+	executableHashKey  cacheKey
+	executableHashErr  error
+)
+
+// executableHash returns the hash of the running executable, which
+// stands for the identity of the code of all analyzers.
+func executableHash() (cacheKey, error) {
+	executableHashOnce.Do(func() {
+		executableHashKey, executableHashErr = func() (hash cacheKey, err error) {
+			exe, err := os.Executable()
+			if err != nil {
+				return hash, err
+			}
+			f, err := os.Open(exe)
+			if err != nil {
+				return hash, err
+			}
+			defer f.Close()
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err != nil {
+				return hash, fmt.Errorf("can't read executable: %w", err)
+			}
+			h.Sum(hash[:0])
+			return hash, nil
+		}()
+	})
+	return executableHashKey, executableHashErr
+}
+
+// A summary is the cached outcome of a successful action.
+type summary struct {
+	Diagnostics      []gobDiagnostic
+	ObjectFacts      []gobObjectFact
+	PackageFacts     []analysis.Fact
+	ContributedFacts []gobContributedFact // contributed by this package
+	Result           []byte               // gob encoding of the result, if non-nil
+}
+
+type gobObjectFact struct {
+	Object objectpath.Path
+	Fact   analysis.Fact
+}
+
+type gobContributedFact struct {
+	PkgPath string // path of the package declaring Object
+	Object  objectpath.Path
+	Fact    analysis.Fact
+}
+
+// A gobPos is a position within one of the package's files.
+type gobPos struct {
+	File   string // empty for token.NoPos
+	Offset int
+}
+
+type gobDiagnostic struct {
+	Pos, End       gobPos
+	Category       string
+	Message        string
+	URL            string
+	SuggestedFixes []gobSuggestedFix
+	Related        []gobRelated
+}
+
+type gobSuggestedFix struct {
+	Message   string
+	TextEdits []gobTextEdit
+}
+
+type gobTextEdit struct {
+	Pos, End gobPos
+	NewText  []byte
+}
+
+type gobRelated struct {
+	Pos, End gobPos
+	Message  string
+}
+
+// filename returns the name of the file holding the summary for key.
+func (co *checkerOptions) filename(key cacheKey) string {
+	base := fmt.Sprintf("%x-analysis", key)
+	return filepath.Join(co.cacheDir, base[:2], base)
+}
+
+// restore attempts to populate act from its cached summary,
+// and reports whether it succeeded.
+func (act *action) restore() bool {
+	data, err := os.ReadFile(act.opts.filename(act.key))
+	if err != nil {
+		return false // cache miss
+	}
+	t0 := time.Now()
+	var sum summary
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&sum); err != nil {
+		if act.opts.dbg('v') {
+			act.opts.logger.Printf("%s: discarding corrupt summary: %v", act, err)
+		}
+		return false
+	}
+
+	files := make(map[string]*token.File)
+	for _, f := range act.pkg.Syntax {
+		if tf := act.pkg.Fset.File(f.Pos()); tf != nil {
+			files[tf.Name()] = tf
+		}
+	}
+	ok := true
+	pos := func(p gobPos) token.Pos {
+		if p.File == "" {
+			return token.NoPos
+		}
+		tf := files[p.File]
+		if tf == nil || p.Offset > tf.Size() {
+			ok = false
+			return token.NoPos
+		}
+		return tf.Pos(p.Offset)
+	}
+	var diags []analysis.Diagnostic
+	for _, d := range sum.Diagnostics {
+		diag := analysis.Diagnostic{
+			Pos:      pos(d.Pos),
+			End:      pos(d.End),
+			Category: d.Category,
+			Message:  d.Message,
+			URL:      d.URL,
+		}
+		for _, sf := range d.SuggestedFixes {
+			fix := analysis.SuggestedFix{Message: sf.Message}
+			for _, edit := range sf.TextEdits {
+				fix.TextEdits = append(fix.TextEdits, analysis.TextEdit{
+					Pos:     pos(edit.Pos),
+					End:     pos(edit.End),
+					NewText: edit.NewText,
+				})
+			}
+			diag.SuggestedFixes = append(diag.SuggestedFixes, fix)
+		}
+		for _, r := range d.Related {
+			diag.Related = append(diag.Related, analysis.RelatedInformation{
+				Pos:     pos(r.Pos),
+				End:     pos(r.End),
+				Message: r.Message,
+			})
+		}
+		diags = append(diags, diag)
+	}
+
+	var result interface{}
+	if sum.Result != nil {
+		ptr := reflect.New(act.a.ResultType)
+		if err := gob.NewDecoder(bytes.NewReader(sum.Result)).DecodeValue(ptr); err != nil {
+			return false
+		}
+		result = ptr.Elem().Interface()
+	}
+
+	objectFacts := make(map[types.Object]analysis.Fact)
+	for _, f := range sum.ObjectFacts {
+		obj, err := objectpath.Object(act.pkg.Types, f.Object)
+		if err != nil {
+			return false
+		}
+		objectFacts[obj] = f.Fact
+	}
+	var contributed map[types.Object][]analysis.Fact
+	if len(sum.ContributedFacts) > 0 {
+		visible := make(map[string]*types.Package)
+		var addPackage func(pkg *types.Package)
+		addPackage = func(pkg *types.Package) {
+			if visible[pkg.Path()] == nil {
+				visible[pkg.Path()] = pkg
+				for _, imp := range pkg.Imports() {
+					addPackage(imp)
+				}
+			}
+		}
+		addPackage(act.pkg.Types)
+		contributed = make(map[types.Object][]analysis.Fact)
+		for _, f := range sum.ContributedFacts {
+			pkg := visible[f.PkgPath]
+			if pkg == nil {
+				return false
+			}
+			obj, err := objectpath.Object(pkg, f.Object)
+			if err != nil {
+				return false
+			}
+			contributed[obj] = append(contributed[obj], f.Fact)
+		}
+	}
+	if !ok {
+		return false
+	}
+
+	act.factTime += time.Since(t0)
+
+	// The summary is valid. Inherit the facts of
+	// dependencies on other packages, then add our own.
+	var vertical []*action
+	for _, dep := range act.deps {
+		if dep.pkg != act.pkg {
+			vertical = append(vertical, dep)
+		}
+	}
+	execAll(vertical, act.opts)
+	act.objectFacts = make(map[objectFactKey]analysis.Fact)
+	act.packageFacts = make(map[packageFactKey]analysis.Fact)
+	act.contributed = make(map[contributedFactKey]analysis.Fact)
+	for _, dep := range vertical {
+		if dep.err != nil {
+			return false
+		}
+		inheritFacts(act, dep)
+	}
+	for obj, fact := range objectFacts {
+		act.objectFacts[objectFactKey{obj, factType(fact)}] = fact
+	}
+	for _, fact := range sum.PackageFacts {
+		act.packageFacts[packageFactKey{act.pkg.Types, factType(fact)}] = fact
+	}
+	for obj, facts := range contributed {
+		for _, fact := range facts {
+			act.contributed[contributedFactKey{act.pkg.Types, obj, factType(fact)}] = fact
+		}
+	}
+	act.diagnostics = diags
+	act.result = result
+	act.cached = true
+	return true
+}
+
+// save writes the summary of a successful action to the cache,
+// unless some part of it cannot be faithfully encoded.
+func (act *action) save() {
+	if act.err != nil || act.pkg.IllTyped {
+		return
+	}
+	t0 := time.Now()
+	data, err := act.encodeSummary()
+	act.factTime += time.Since(t0)
+	if err != nil {
+		if act.opts.dbg('v') {
+			act.opts.logger.Printf("%s: not caching summary: %v", act, err)
+		}
+		return
+	}
+	if err := writeFileAtomic(act.opts.filename(act.key), data); err != nil && act.opts.dbg('v') {
+		act.opts.logger.Printf("%s: writing summary: %v", act, err)
+	}
+}
+
+func (act *action) encodeSummary() ([]byte, error) {
+	var sum summary
+
+	fset := act.pkg.Fset
+	var posErr error
+	pos := func(p token.Pos) gobPos {
+		if !p.IsValid() {
+			return gobPos{}
+		}
+		tf := fset.File(p)
+		if tf == nil {
+			posErr = fmt.Errorf("no file for position %d", p)
+			return gobPos{}
+		}
+		return gobPos{File: tf.Name(), Offset: tf.Offset(p)}
+	}
+	for _, d := range act.diagnostics {
+		diag := gobDiagnostic{
+			Pos:      pos(d.Pos),
+			End:      pos(d.End),
+			Category: d.Category,
+			Message:  d.Message,
+			URL:      d.URL,
+		}
+		for _, sf := range d.SuggestedFixes {
+			fix := gobSuggestedFix{Message: sf.Message}
+			for _, edit := range sf.TextEdits {
+				fix.TextEdits = append(fix.TextEdits, gobTextEdit{
+					Pos:     pos(edit.Pos),
+					End:     pos(edit.End),
+					NewText: edit.NewText,
+				})
+			}
+			diag.SuggestedFixes = append(diag.SuggestedFixes, fix)
+		}
+		for _, r := range d.Related {
+			diag.Related = append(diag.Related, gobRelated{
+				Pos:     pos(r.Pos),
+				End:     pos(r.End),
+				Message: r.Message,
+			})
+		}
+		sum.Diagnostics = append(sum.Diagnostics, diag)
+	}
+	if posErr != nil {
+		return nil, posErr
+	}
+
+	// Only facts about our own package can be restored;
+	// the rest are inherited from dependencies.
+	inherited := make(map[objectFactKey]bool)
+	for _, dep := range act.deps {
+		if dep.pkg != act.pkg {
+			for key := range dep.objectFacts {
+				inherited[key] = true
+			}
+		}
+	}
+	enc := new(objectpath.Encoder)
+	for key, fact := range act.objectFacts {
+		if key.obj.Pkg() != act.pkg.Types {
+			if inherited[key] {
+				continue
+			}
+			return nil, fmt.Errorf("fact %s about object %s of another package", fact, key.obj)
+		}
+		path, err := enc.For(key.obj)
+		if err != nil {
+			return nil, fmt.Errorf("fact %s about object %s: %v", fact, key.obj, err)
+		}
+		sum.ObjectFacts = append(sum.ObjectFacts, gobObjectFact{path, fact})
+	}
+	sort.Slice(sum.ObjectFacts, func(i, j int) bool { // for determinism
+		x, y := sum.ObjectFacts[i], sum.ObjectFacts[j]
+		if x.Object != y.Object {
+			return x.Object < y.Object
+		}
+		return reflect.TypeOf(x.Fact).String() < reflect.TypeOf(y.Fact).String()
+	})
+	for key, fact := range act.packageFacts {
+		if key.pkg == act.pkg.Types {
+			sum.PackageFacts = append(sum.PackageFacts, fact)
+		}
+	}
+	sort.Slice(sum.PackageFacts, func(i, j int) bool {
+		return reflect.TypeOf(sum.PackageFacts[i]).String() < reflect.TypeOf(sum.PackageFacts[j]).String()
+	})
+	for key, fact := range act.contributed {
+		if key.from != act.pkg.Types {
+			continue // inherited
+		}
+		path, err := enc.For(key.obj)
+		if err != nil {
+			return nil, fmt.Errorf("contributed fact %s about object %s: %v", fact, key.obj, err)
+		}
+		sum.ContributedFacts = append(sum.ContributedFacts, gobContributedFact{key.obj.Pkg().Path(), path, fact})
+	}
+	sort.Slice(sum.ContributedFacts, func(i, j int) bool { // for determinism
+		x, y := sum.ContributedFacts[i], sum.ContributedFacts[j]
+		if x.PkgPath != y.PkgPath {
+			return x.PkgPath < y.PkgPath
+		}
+		if x.Object != y.Object {
+			return x.Object < y.Object
+		}
+		return reflect.TypeOf(x.Fact).String() < reflect.TypeOf(y.Fact).String()
+	})
+
+	if act.result != nil {
+		data, err := codeResult(act.result, act.a.ResultType)
+		if err != nil {
+			return nil, err
+		}
+		sum.Result = data
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&sum); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// codeResult returns the gob encoding of an analyzer's result.
+// It fails unless decoding the encoding yields an equal value, as gob
+// silently discards unexported fields.
+func codeResult(result interface{}, typ reflect.Type) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(result); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	ptr := reflect.New(typ)
+	if err := gob.NewDecoder(bytes.NewReader(data)).DecodeValue(ptr); err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(ptr.Elem().Interface(), result) {
+		return nil, fmt.Errorf("result of type %v does not survive gob encoding", typ)
+	}
+	return data, nil
+}
+
+// writeFileAtomic writes data to filename, creating its directory if
+// necessary, such that concurrent readers see either the old or new
+// contents but never a partial write.
+func writeFileAtomic(filename string, data []byte) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name()) // ignore error
+	}
+	return err
+}
@@ -10,6 +10,7 @@ package checker
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"flag"
 	"fmt"
@@ -18,12 +19,14 @@ import (
 	"go/types"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"runtime/pprof"
 	"runtime/trace"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -38,6 +41,9 @@ import (
 	"github.com/TBD54566975/golang-tools/internal/robustio"
 )
 
+// These variables are set by command-line flags and are read only by
+// Run. RunWithResult is configured solely by its Options, so that
+// concurrent calls with different settings do not interfere.
 var (
 	// Debug is a set of single-letter flags:
 	//
@@ -57,6 +63,18 @@ var (
 
 	// Fix determines whether to apply all suggested fixes.
 	Fix bool
+
+	// Diff causes the suggested fixes to be printed
+	// as unified diffs instead of being applied.
+	Diff bool
+
+	// Baseline is the name of a baseline file whose diagnostics
+	// are suppressed; see WithBaseline.
+	Baseline string
+
+	// WriteBaseline causes the Baseline file to be rewritten
+	// to record all current diagnostics.
+	WriteBaseline bool
 )
 
 // RegisterFlags registers command-line flags used by the analysis driver.
@@ -72,15 +90,98 @@ func RegisterFlags() {
 	flag.BoolVar(&IncludeTests, "test", IncludeTests, "indicates whether test files should be analyzed, too")
 
 	flag.BoolVar(&Fix, "fix", false, "apply all suggested fixes")
+	flag.BoolVar(&Diff, "diff", false, "print suggested fixes as unified diffs instead of applying them")
+
+	flag.StringVar(&Baseline, "baseline", "", "suppress diagnostics recorded in this baseline file")
+	flag.BoolVar(&WriteBaseline, "writebaseline", false, "record all diagnostics in the -baseline file instead of reporting them")
 }
 
 type checkerOptions struct {
+	ctx                         context.Context
 	loadConfig                  *packages.Config
 	reverseImportExecutionOrder bool
+	stopOnLoadErrors            bool
+	includeTests                bool // ignored if loadConfig is set
+	fix                         bool
+	diff                        bool
+	debug                       string
+	cpuProfile, memProfile      string
+	trace                       string
+	cacheDir                    string
+	packageFilters              map[*analysis.Analyzer]func(*packages.Package) bool
+	baseline                    string
+	updateBaseline              bool
+	knownAnalyzers              map[string]bool // names of analyzers disabled by flags, etc
+	useConfigFiles              bool
+
+	onActionComplete func(*ActionEvent)
+
+	logger *log.Logger // for debugging output (see WithDebug); set by runInternal
+
+	mu        sync.Mutex // guards completed and serializes calls to onActionComplete
+	completed []*ActionProfile
+
+	settings  map[*packages.Package]*analysisinternal.ConfigSettings // set by loadSettings
+	flagLocks map[*analysis.Analyzer]*sync.Mutex                     // set by loadSettings
+
+	staleBaseline []BaselineEntry              // set by runInternal
+	skippedFixes  []SkippedFix                 // set by applyFixes
+	fixDiffs      map[string]string            // set by applyFixes in diff mode
+	fixedOverlays map[string][]byte            // set by applyFixes
+	finishErrors  map[*analysis.Analyzer]error // set by finish
 }
 
 type Option func(option *checkerOptions)
 
+// newOptions returns the options resulting from applying opts to the defaults.
+func newOptions(opts ...Option) *checkerOptions {
+	co := &checkerOptions{ctx: context.Background(), includeTests: true, logger: log.Default()}
+	for _, opt := range opts {
+		opt(co)
+	}
+	return co
+}
+
+// flagOptions returns the options specified by the command-line flags
+// registered by RegisterFlags.
+func flagOptions() []Option {
+	return []Option{
+		WithIncludeTests(IncludeTests),
+		WithFix(Fix),
+		WithDiff(Diff),
+		WithDebug(Debug),
+		WithBaseline(Baseline, WriteBaseline),
+		WithConfigFiles(true),
+		func(co *checkerOptions) {
+			co.cpuProfile, co.memProfile, co.trace = CPUProfile, MemProfile, Trace
+			co.knownAnalyzers = analysisflags.KnownAnalyzers
+		},
+	}
+}
+
+// readFile returns the contents of the named file as seen by the
+// package loader: from the overlay of the load config, if present,
+// otherwise from the file system. All reads of source, go.mod, go.work
+// and configuration files by the checker go through readFile.
+func (co *checkerOptions) readFile(filename string) ([]byte, error) {
+	if content, ok := co.overlay(filename); ok {
+		return content, nil
+	}
+	return os.ReadFile(filename)
+}
+
+// overlay returns the overlaid contents of the named file, if any.
+func (co *checkerOptions) overlay(filename string) ([]byte, bool) {
+	if co.loadConfig == nil {
+		return nil, false
+	}
+	content, ok := co.loadConfig.Overlay[filename]
+	return content, ok
+}
+
+// dbg reports whether the debug flag b is set; see Debug.
+func (co *checkerOptions) dbg(b byte) bool { return strings.IndexByte(co.debug, b) >= 0 }
+
 func WithLoadConfig(config packages.Config) Option {
 	return func(co *checkerOptions) {
 		co.loadConfig = &config
@@ -95,15 +196,147 @@ func WithReverseImportExecutionOrder(reverse bool) Option {
 	}
 }
 
+// WithContext sets the context of the run. Once it is cancelled, no
+// further packages are loaded and no further actions are started, and
+// RunWithResult returns an error naming the interrupted phase.
+// It overrides the Context of any config specified by WithLoadConfig.
+func WithContext(ctx context.Context) Option {
+	return func(co *checkerOptions) {
+		co.ctx = ctx
+	}
+}
+
+// WithPackageFilter restricts the initial packages to which analyzer a
+// is applied to those for which filter returns true. The analyzer still
+// runs on other packages as needed to compute the facts of its
+// dependencies, but its results and diagnostics are reported only for
+// the selected packages. Other analyzers are unaffected.
+func WithPackageFilter(a *analysis.Analyzer, filter func(*packages.Package) bool) Option {
+	return func(co *checkerOptions) {
+		if co.packageFilters == nil {
+			co.packageFilters = make(map[*analysis.Analyzer]func(*packages.Package) bool)
+		}
+		co.packageFilters[a] = filter
+	}
+}
+
+// WithOnActionComplete sets a function to be called as each action
+// (the application of one analyzer to one package) completes, whether
+// successfully or not, while analysis of the remaining actions
+// continues. Calls are serialized, but their order is unspecified
+// beyond the fact that an action completes after its prerequisites.
+// The requested actions of a whole-program analyzer (see
+// analysis.Analyzer.Finish) complete when its Finish function returns,
+// and their diagnostics include those that it reported.
+func WithOnActionComplete(f func(*ActionEvent)) Option {
+	return func(co *checkerOptions) {
+		co.onActionComplete = f
+	}
+}
+
+// If true, test files are analyzed too. The default is true.
+// It has no effect if a load config is specified by WithLoadConfig.
+func WithIncludeTests(include bool) Option {
+	return func(co *checkerOptions) {
+		co.includeTests = include
+	}
+}
+
+// If true, all suggested fixes are applied to the files on disk.
+// Fixes to files overlaid by the load config are instead
+// recorded in Result.Fixed.
+func WithFix(fix bool) Option {
+	return func(co *checkerOptions) {
+		co.fix = fix
+	}
+}
+
+// WithDiff enables diff mode, in which the suggested fixes that
+// WithFix would apply are instead recorded as unified diffs in
+// Result.Diffs (and printed by Run), and no files are modified.
+// It implies WithFix.
+func WithDiff(diff bool) Option {
+	return func(co *checkerOptions) {
+		co.diff = diff
+	}
+}
+
+// WithDebug sets the debug flags, a subset of those described at Debug.
+func WithDebug(debug string) Option {
+	return func(co *checkerOptions) {
+		co.debug = debug
+	}
+}
+
+// WithCacheDir enables incremental analysis using a persistent cache of
+// analysis summaries in the specified directory, which is created if
+// necessary. An action (the application of one analyzer to one package)
+// is skipped if its inputs are unchanged since a previous run that used
+// the same directory; its diagnostics, facts and result are instead
+// restored from the cache. Results are cached only if they can be
+// faithfully gob-encoded. Nothing is ever deleted from the directory.
+func WithCacheDir(dir string) Option {
+	return func(co *checkerOptions) {
+		co.cacheDir = dir
+	}
+}
+
+// WithBaseline enables a baseline file, which records diagnostics by
+// analyzer, category, file, enclosing function and message, but not
+// by line, so that existing findings can be suppressed while new ones
+// are reported. Diagnostics of the requested analyzers that match an
+// entry of the file are removed from the results; entries that match
+// no diagnostic in the files analyzed are reported as stale.
+//
+// If update is true, the file is instead (re)written to record all
+// current diagnostics, which are then all suppressed. Entries for the
+// files and analyzers that the run did not analyze are kept. Update
+// mode requires a file name.
+//
+// Diagnostics reported to the function specified by
+// WithOnActionComplete are not filtered.
+func WithBaseline(filename string, update bool) Option {
+	return func(co *checkerOptions) {
+		co.baseline = filename
+		co.updateBaseline = update
+	}
+}
+
+// WithConfigFiles enables analysis configuration files. If true, each
+// package is analyzed according to the file named analysis.json in its
+// directory or the nearest of its parent directories, if any, which
+// may disable analyzers and set their flags; see analysisinternal.Config.
+// Flags set on the command line take precedence over the file.
+//
+// A disabled analyzer still runs on a package as needed to compute
+// the facts of its dependents, but its results and diagnostics are
+// not reported, as with WithPackageFilter.
+func WithConfigFiles(use bool) Option {
+	return func(co *checkerOptions) {
+		co.useConfigFiles = use
+	}
+}
+
+// If true, no analysis is performed if any loaded package has errors.
+// Otherwise, only the analyzers marked with RunDespiteErrors (and whose
+// transitive requirements are also marked) are applied to packages with errors.
+func WithStopOnLoadErrors(stop bool) Option {
+	return func(co *checkerOptions) {
+		co.stopOnLoadErrors = stop
+	}
+}
+
 // Run loads the packages specified by args using go/packages,
 // then applies the specified analyzers to them.
 // Analysis flags must already have been set.
 // Analyzers must be valid according to [analysis.Validate].
 // It provides most of the logic for the main functions of both the
 // singlechecker and the multi-analysis commands.
+// It is configured by the package-level flag variables.
 // It returns the appropriate exit code.
 func Run(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
-	roots, pkgs, err := runInternal(args, analyzers)
+	opts := newOptions(flagOptions()...)
+	roots, pkgs, err := runInternal(args, analyzers, opts, nil)
 	if err != nil {
 		log.Print(err)
 		return 1
@@ -116,47 +349,245 @@ func Run(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
 		pkgsExitCode = 1
 	}
 
+	// Report baseline entries that no longer match anything,
+	// so that they can be removed by -writebaseline.
+	for _, e := range opts.staleBaseline {
+		fmt.Fprintf(os.Stderr, "stale baseline entry: %s\n", e)
+	}
+
 	// Print the results. If !RunDespiteErrors and there
 	// are errors in the packages, this will have 0 exit
 	// code. Otherwise, we prefer to return exit code
 	// indicating diagnostics.
-	if diagExitCode := printDiagnostics(roots); diagExitCode != 0 {
+	diagExitCode := printDiagnostics(roots, opts)
+
+	// Report the failures of whole-program analyses.
+	for _, a := range analyzers {
+		if err := opts.finishErrors[a]; err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", a.Name, err)
+			diagExitCode = 1 // analysis failed, at least partially
+		}
+	}
+
+	// Report the fixes that were not applied,
+	// and in diff mode print the others.
+	for _, s := range opts.skippedFixes {
+		fmt.Fprintln(os.Stderr, s)
+	}
+	paths := maps.Keys(opts.fixDiffs)
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Print(opts.fixDiffs[path])
+	}
+
+	if diagExitCode != 0 {
 		return diagExitCode // there were diagnostics
 	}
 	return pkgsExitCode // package errors but no diagnostics
 }
 
-// Run loads the packages specified by args using go/packages,
+// RunWithResult loads the packages specified by args using go/packages,
 // then applies the specified analyzers to them.
-// Analysis flags must already have been set.
 // Analyzers must be valid according to [analysis.Validate].
+// Unlike Run, it does not consult the package-level flag variables,
+// so it is safe to call concurrently with different options.
 //
-// Returns results and diagnostics produced by the analyzers.
-func RunWithResult(args []string, analyzers []*analysis.Analyzer, opts ...Option) (analyzerResults map[*analysis.Analyzer][]interface{}, diagnostics []analysis.SimpleDiagnostic, err error) {
-	roots, _, err := runInternal(args, analyzers, opts...)
+// It returns the results, diagnostics and facts produced by the
+// analyzers, grouped by the package to which they were applied,
+// along with any errors encountered while loading the packages.
+func RunWithResult(args []string, analyzers []*analysis.Analyzer, opts ...Option) (*Result, error) {
+	prof := new(Profile)
+	cfg := newOptions(opts...)
+	roots, initial, err := runInternal(args, analyzers, cfg, prof)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	diags := []analysis.SimpleDiagnostic{}
-	results := make(map[*analysis.Analyzer][]interface{})
+	res := &Result{
+		Packages:      make(map[string]*PackageResult),
+		Errors:        loadErrors(initial),
+		StaleBaseline: cfg.staleBaseline,
+		SkippedFixes:  cfg.skippedFixes,
+		Diffs:         cfg.fixDiffs,
+		Fixed:         cfg.fixedOverlays,
+		FinishErrors:  cfg.finishErrors,
+		Profile:       prof,
+	}
+	for _, pkg := range initial {
+		res.Packages[pkg.ID] = &PackageResult{
+			Package:   pkg,
+			Analyzers: make(map[*analysis.Analyzer]*AnalyzerResult),
+		}
+	}
 	for _, root := range roots {
-		results[root.a] = append(results[root.a], root.result)
+		diags := make([]analysis.SimpleDiagnostic, 0, len(root.diagnostics))
 		for _, d := range root.diagnostics {
-			diags = append(diags, d.ToSimple(root.pass.Fset))
+			diags = append(diags, d.ToSimple(root.pkg.Fset))
+		}
+		res.Packages[root.pkg.ID].Analyzers[root.a] = &AnalyzerResult{
+			Result:      root.result,
+			Diagnostics: diags,
+			Facts:       root.ownFacts(),
+			Err:         root.err,
+			Cached:      root.cached,
 		}
 	}
-	return results, diags, nil
+	return res, nil
 }
 
-func runInternal(args []string, analyzers []*analysis.Analyzer, opts ...Option) ([]*action, []*packages.Package, error) {
-	cfg := &checkerOptions{}
-	for _, opt := range opts {
-		opt(cfg)
+// A Result holds the outcome of a call to [RunWithResult].
+type Result struct {
+	// Packages maps the ID of each initial package
+	// (see [packages.Package.ID]) to its results.
+	Packages map[string]*PackageResult
+
+	// Errors holds the errors encountered while loading the
+	// initial packages and their dependencies, such as parse and
+	// type errors. Their Category is either [ListErrorCategory] or
+	// [CompilerErrorCategory].
+	Errors []analysis.SimpleDiagnostic
+
+	// StaleBaseline holds the entries of the baseline file (see
+	// WithBaseline) that matched no diagnostic.
+	StaleBaseline []BaselineEntry
+
+	// SkippedFixes holds the suggested fixes that were not applied
+	// (see WithFix) because they were invalid or conflicted with
+	// other fixes.
+	SkippedFixes []SkippedFix
+
+	// Diffs maps the name of each file to which fixes apply to a
+	// unified diff of the fixes, in diff mode (see WithDiff).
+	Diffs map[string]string
+
+	// Fixed maps the name of each overlaid file (see
+	// packages.Config.Overlay) to which fixes apply to its fixed
+	// contents. Fixes to overlaid files are never written to disk.
+	Fixed map[string][]byte
+
+	// FinishErrors holds the errors returned by the Finish
+	// functions of whole-program analyzers (see analysis.Analyzer.Finish),
+	// whose diagnostics are reported with those of the packages.
+	FinishErrors map[*analysis.Analyzer]error
+
+	// Profile holds statistics about the run.
+	Profile *Profile
+}
+
+// A Profile holds timing statistics about a run. (It records no
+// memory statistics, as the runtime's are process-wide, and so would
+// include the allocations of any concurrent runs.)
+//
+// Durations of actions are measured while other actions run in
+// parallel, and so are inflated by GC and scheduler contention.
+// Use the "p" debug flag (see WithDebug) for more accurate figures.
+type Profile struct {
+	LoadTime     time.Duration // time to load and type-check the packages
+	AnalysisTime time.Duration // wall time of the analysis phase
+
+	// Actions holds one entry for each action that was executed,
+	// including prerequisites, in the order they completed.
+	Actions []*ActionProfile
+}
+
+// An ActionProfile holds statistics about one action: the
+// application of one analyzer to one package.
+type ActionProfile struct {
+	Analyzer      *analysis.Analyzer
+	Package       *packages.Package
+	Duration      time.Duration // time spent in the action, excluding prerequisites
+	FactTime      time.Duration // time spent inheriting, encoding and decoding facts
+	FactsExported int           // number of facts about the package and its objects
+	Cached        bool          // action was restored from the cache
+}
+
+// ByAnalyzer returns the total duration of the actions of each analyzer.
+func (p *Profile) ByAnalyzer() map[*analysis.Analyzer]time.Duration {
+	totals := make(map[*analysis.Analyzer]time.Duration)
+	for _, ap := range p.Actions {
+		totals[ap.Analyzer] += ap.Duration
+	}
+	return totals
+}
+
+// Categories of the diagnostics in [Result.Errors].
+const (
+	ListErrorCategory     = "list"     // errors reported by the build system
+	CompilerErrorCategory = "compiler" // parse and type errors
+)
+
+// A PackageResult holds the results of applying the root
+// analyzers to a single package.
+type PackageResult struct {
+	Package   *packages.Package
+	Analyzers map[*analysis.Analyzer]*AnalyzerResult
+}
+
+// An AnalyzerResult holds the result of applying one analyzer to one package.
+//
+// Facts exported for the package are keyed by object; package facts
+// have a nil key.
+type AnalyzerResult struct {
+	Result      interface{}
+	Diagnostics []analysis.SimpleDiagnostic
+	Facts       map[types.Object][]analysis.Fact
+	Err         error
+	Cached      bool // result was restored from the cache (see WithCacheDir)
+}
+
+// An ActionEvent describes the completion of one action:
+// the application of one analyzer to one package.
+type ActionEvent struct {
+	Analyzer *analysis.Analyzer
+	Package  *packages.Package
+
+	// IsRoot reports whether the action was requested, as opposed to
+	// being a prerequisite of a requested action. The results of
+	// non-root actions are not otherwise reported.
+	IsRoot bool
+
+	// Duration is the time spent in the action, excluding its
+	// prerequisites. It is zero for cached actions.
+	Duration time.Duration
+
+	AnalyzerResult
+}
+
+// Diagnostics returns the load errors followed by the diagnostics
+// of all packages and analyzers, ordered by package ID and then by
+// analyzer name.
+func (r *Result) Diagnostics() []analysis.SimpleDiagnostic {
+	diags := append([]analysis.SimpleDiagnostic(nil), r.Errors...)
+
+	ids := make([]string, 0, len(r.Packages))
+	for id := range r.Packages {
+		ids = append(ids, id)
 	}
+	sort.Strings(ids)
 
-	if CPUProfile != "" {
-		f, err := os.Create(CPUProfile)
+	for _, id := range ids {
+		pr := r.Packages[id]
+		analyzers := make([]*analysis.Analyzer, 0, len(pr.Analyzers))
+		for a := range pr.Analyzers {
+			analyzers = append(analyzers, a)
+		}
+		sort.Slice(analyzers, func(i, j int) bool { return analyzers[i].Name < analyzers[j].Name })
+		for _, a := range analyzers {
+			diags = append(diags, pr.Analyzers[a].Diagnostics...)
+		}
+	}
+	return diags
+}
+
+// runInternal loads the packages and analyzes them. If prof is non-nil,
+// it is populated with statistics about the run.
+func runInternal(args []string, analyzers []*analysis.Analyzer, cfg *checkerOptions, prof *Profile) ([]*action, []*packages.Package, error) {
+	if cfg.updateBaseline && cfg.baseline == "" {
+		return nil, nil, fmt.Errorf("no baseline file to write (-writebaseline requires -baseline)")
+	}
+
+	if cfg.cpuProfile != "" {
+		f, err := os.Create(cfg.cpuProfile)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -167,8 +598,8 @@ func runInternal(args []string, analyzers []*analysis.Analyzer, opts ...Option)
 		defer pprof.StopCPUProfile()
 	}
 
-	if Trace != "" {
-		f, err := os.Create(Trace)
+	if cfg.trace != "" {
+		f, err := os.Create(cfg.trace)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -178,12 +609,12 @@ func runInternal(args []string, analyzers []*analysis.Analyzer, opts ...Option)
 		// NB: trace log won't be written in case of error.
 		defer func() {
 			trace.Stop()
-			log.Printf("To view the trace, run:\n$ go tool trace view %s", Trace)
+			log.Printf("To view the trace, run:\n$ go tool trace view %s", cfg.trace)
 		}()
 	}
 
-	if MemProfile != "" {
-		f, err := os.Create(MemProfile)
+	if cfg.memProfile != "" {
+		f, err := os.Create(cfg.memProfile)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -198,29 +629,69 @@ func runInternal(args []string, analyzers []*analysis.Analyzer, opts ...Option)
 	}
 
 	// Load the packages.
-	if dbg('v') {
-		log.SetPrefix("")
-		log.SetFlags(log.Lmicroseconds) // display timing
-		log.Printf("load %s", args)
+	if cfg.dbg('v') {
+		// Display timing, without changing the log
+		// output of the rest of the process.
+		cfg.logger = log.New(log.Writer(), "", log.Lmicroseconds)
+		cfg.logger.Printf("load %s", args)
 	}
 
 	// Optimization: if the selected analyzers don't produce/consume
 	// facts, we need source only for the initial packages.
 	allSyntax := needFacts(analyzers)
+	t0 := time.Now()
 	initial, err := load(args, allSyntax, cfg)
+	if prof != nil {
+		prof.LoadTime = time.Since(t0)
+	}
+	if err := interrupted(cfg.ctx, "loading packages"); err != nil {
+		return nil, nil, err
+	}
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if cfg.stopOnLoadErrors && hasErrors(initial) {
+		return nil, initial, nil
+	}
+
+	if cfg.useConfigFiles {
+		if err := loadSettings(initial, analyzers, cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// Run the analyzers. On each package with (transitive)
 	// errors, we run only the subset of analyzers that are
 	// marked (and whose transitive requirements are also
 	// marked) with RunDespiteErrors.
+	t0 = time.Now()
+	unlock := lockFlags(analyzers, cfg)
 	roots := analyze(initial, analyzers, cfg)
+	unlock()
+	if prof != nil {
+		prof.AnalysisTime = time.Since(t0)
+		prof.Actions = cfg.completed
+	}
+	if err := interrupted(cfg.ctx, "analysis"); err != nil {
+		return nil, nil, err
+	}
+
+	roots = applyIgnoreDirectives(roots, analyzers, cfg)
+
+	// Suppress the diagnostics recorded in the baseline,
+	// so that only new ones are printed or fixed.
+	if cfg.baseline != "" {
+		stale, err := applyBaseline(roots, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.staleBaseline = stale
+	}
 
 	// Apply fixes.
-	if Fix {
-		if err := applyFixes(roots); err != nil {
+	if cfg.fix || cfg.diff {
+		if err := applyFixes(roots, cfg); err != nil {
 			// Fail when applying fixes failed.
 			return nil, nil, err
 		}
@@ -229,6 +700,70 @@ func runInternal(args []string, analyzers []*analysis.Analyzer, opts ...Option)
 	return roots, initial, nil
 }
 
+// applyIgnoreDirectives removes from the root actions the diagnostics
+// suppressed by //analysis:ignore directives in their packages. For
+// each package whose directives have problems, such as naming unknown
+// analyzers or suppressing nothing, it adds a root action of
+// analysisinternal.IgnoreAnalyzer whose diagnostics describe them.
+func applyIgnoreDirectives(roots []*action, analyzers []*analysis.Analyzer, opts *checkerOptions) []*action {
+	known := make(map[string]bool)
+	for name := range opts.knownAnalyzers {
+		known[name] = true
+	}
+	var addKnown func([]*analysis.Analyzer)
+	addKnown = func(analyzers []*analysis.Analyzer) {
+		for _, a := range analyzers {
+			if !known[a.Name] {
+				known[a.Name] = true
+				addKnown(a.Requires)
+			}
+		}
+	}
+	addKnown(analyzers)
+
+	// Group the root actions by package, preserving order.
+	var pkgs []*packages.Package
+	byPkg := make(map[*packages.Package][]*action)
+	for _, act := range roots {
+		if byPkg[act.pkg] == nil {
+			pkgs = append(pkgs, act.pkg)
+		}
+		byPkg[act.pkg] = append(byPkg[act.pkg], act)
+	}
+
+	for _, pkg := range pkgs {
+		dirs := analysisinternal.ParseIgnoreDirectives(pkg.Fset, pkg.Syntax)
+		ran := make(map[string]bool)
+		for _, act := range byPkg[pkg] {
+			if act.err == nil {
+				ran[act.a.Name] = true
+				act.diagnostics = dirs.Filter(act.a.Name, act.diagnostics)
+			}
+		}
+		if problems := dirs.Problems(
+			func(name string) bool { return known[name] },
+			func(name string) bool { return ran[name] },
+		); len(problems) > 0 {
+			roots = append(roots, &action{
+				a:           analysisinternal.IgnoreAnalyzer,
+				pkg:         pkg,
+				opts:        opts,
+				isroot:      true,
+				diagnostics: problems,
+			})
+		}
+	}
+	return roots
+}
+
+// interrupted returns an error naming the phase if ctx is done.
+func interrupted(ctx context.Context, phase string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s interrupted: %w", phase, err)
+	}
+	return nil
+}
+
 // load loads the initial packages. Returns only top-level loading
 // errors. Does not consider errors in packages.
 func load(patterns []string, allSyntax bool, opts *checkerOptions) ([]*packages.Package, error) {
@@ -243,9 +778,10 @@ func load(patterns []string, allSyntax bool, opts *checkerOptions) ([]*packages.
 		mode |= packages.NeedModule
 		conf = packages.Config{
 			Mode:  mode,
-			Tests: IncludeTests,
+			Tests: opts.includeTests,
 		}
 	}
+	conf.Context = opts.ctx
 	initial, err := packages.Load(&conf, patterns...)
 	if err == nil && len(initial) == 0 {
 		err = fmt.Errorf("%s matched no packages", strings.Join(patterns, " "))
@@ -253,6 +789,90 @@ func load(patterns []string, allSyntax bool, opts *checkerOptions) ([]*packages.
 	return initial, err
 }
 
+// hasErrors reports whether any of pkgs or their dependencies has errors.
+func hasErrors(pkgs []*packages.Package) bool {
+	found := false
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if len(pkg.Errors) > 0 {
+			found = true
+		}
+	})
+	return found
+}
+
+// loadErrors converts the errors of pkgs and their dependencies
+// into diagnostics.
+func loadErrors(pkgs []*packages.Package) []analysis.SimpleDiagnostic {
+	var diags []analysis.SimpleDiagnostic
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		// Type errors carry exact positions; prefer them
+		// to their string form in pkg.Errors.
+		for _, err := range pkg.TypeErrors {
+			posn := err.Fset.Position(err.Pos)
+			diags = append(diags, analysis.SimpleDiagnostic{
+				Pos: analysis.SimplePosition{
+					Filename: posn.Filename,
+					Line:     posn.Line,
+					Column:   posn.Column,
+					Offset:   posn.Offset,
+				},
+				Category: CompilerErrorCategory,
+				Message:  err.Msg,
+			})
+		}
+		for _, err := range pkg.Errors {
+			category := ListErrorCategory
+			switch err.Kind {
+			case packages.TypeError:
+				if len(pkg.TypeErrors) > 0 {
+					continue // already reported
+				}
+				category = CompilerErrorCategory
+			case packages.ParseError:
+				category = CompilerErrorCategory
+			}
+			diags = append(diags, analysis.SimpleDiagnostic{
+				Pos:      parsePosition(err.Pos),
+				Category: category,
+				Message:  err.Msg,
+			})
+		}
+	})
+	return diags
+}
+
+// parsePosition parses the position of a [packages.Error],
+// which has the form "file:line:col", "file:line", "file", "" or "-".
+// The offset is not known.
+func parsePosition(pos string) analysis.SimplePosition {
+	var posn analysis.SimplePosition
+	if pos == "" || pos == "-" {
+		return posn
+	}
+	// Parse from the right, as the file name may contain colons.
+	var nums []int
+	for len(nums) < 2 {
+		i := strings.LastIndexByte(pos, ':')
+		if i < 0 {
+			break
+		}
+		n, err := strconv.Atoi(pos[i+1:])
+		if err != nil {
+			break
+		}
+		nums = append(nums, n)
+		pos = pos[:i]
+	}
+	posn.Filename = pos
+	switch len(nums) {
+	case 1:
+		posn.Line = nums[0]
+	case 2:
+		posn.Line, posn.Column = nums[1], nums[0]
+	}
+	return posn
+}
+
 // TestAnalyzer applies an analyzer to a set of packages (and their
 // dependencies if necessary) and returns the results.
 // The analyzer must be valid according to [analysis.Validate].
@@ -263,20 +883,14 @@ func load(patterns []string, allSyntax bool, opts *checkerOptions) ([]*packages.
 // This entry point is used only by analysistest.
 func TestAnalyzer(a *analysis.Analyzer, pkgs []*packages.Package) []*TestAnalyzerResult {
 	var results []*TestAnalyzerResult
-	for _, act := range analyze(pkgs, []*analysis.Analyzer{a}, &checkerOptions{}) {
-		facts := make(map[types.Object][]analysis.Fact)
-		for key, fact := range act.objectFacts {
-			if key.obj.Pkg() == act.pass.Pkg {
-				facts[key.obj] = append(facts[key.obj], fact)
-			}
-		}
-		for key, fact := range act.packageFacts {
-			if key.pkg == act.pass.Pkg {
-				facts[nil] = append(facts[nil], fact)
-			}
-		}
-
-		results = append(results, &TestAnalyzerResult{act.pass, act.diagnostics, facts, act.result, act.err})
+	opts := newOptions()
+	for _, act := range analyze(pkgs, []*analysis.Analyzer{a}, opts) {
+		results = append(results, &TestAnalyzerResult{act.pass, act.diagnostics, act.ownFacts(), act.result, act.err})
+	}
+	// Attribute the failure of a's Finish function, if any,
+	// to the first package.
+	if err := opts.finishErrors[a]; err != nil && len(results) > 0 && results[0].Err == nil {
+		results[0].Err = err
 	}
 	return results
 }
@@ -291,8 +905,8 @@ type TestAnalyzerResult struct {
 
 func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer, opts *checkerOptions) []*action {
 	// Construct the action graph.
-	if dbg('v') {
-		log.Printf("building graph of analysis passes")
+	if opts.dbg('v') {
+		opts.logger.Printf("building graph of analysis passes")
 	}
 
 	// Each graph node (action) is one unit of analysis.
@@ -303,13 +917,14 @@ func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer, opts *che
 		*packages.Package
 	}
 	actions := make(map[key]*action)
+	modules := newModuleCache(opts)
 
 	var mkAction func(a *analysis.Analyzer, pkg *packages.Package) *action
 	mkAction = func(a *analysis.Analyzer, pkg *packages.Package) *action {
 		k := key{a, pkg}
 		act, ok := actions[k]
 		if !ok {
-			act = &action{a: a, pkg: pkg}
+			act = &action{a: a, pkg: pkg, opts: opts, module: modules.get(pkg.Module)}
 
 			// Add a dependency on each required analyzers.
 			for _, req := range a.Requires {
@@ -350,25 +965,202 @@ func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer, opts *che
 	// Build nodes for initial packages.
 	var roots []*action
 	for _, a := range analyzers {
+		filter := opts.packageFilters[a]
 		for _, pkg := range pkgs {
+			if filter != nil && !filter(pkg) || !opts.enabled(a, pkg) {
+				continue
+			}
 			root := mkAction(a, pkg)
 			root.isroot = true
 			roots = append(roots, root)
 		}
 	}
 
+	if opts.cacheDir != "" {
+		if err := computeKeys(roots, opts); err != nil {
+			// Proceed without the cache. (opts is private to this run.)
+			if opts.dbg('v') {
+				opts.logger.Printf("disabling analysis cache: %v", err)
+			}
+			opts.cacheDir = ""
+		}
+	}
+
 	// Execute the graph in parallel.
-	execAll(roots)
+	execAll(roots, opts)
+
+	// Complete the whole-program analyses.
+	finish(roots, analyzers, opts)
 
 	return roots
 }
 
-func applyFixes(roots []*action) error {
-	// visit all of the actions and accumulate the suggested edits.
-	paths := make(map[robustio.FileID]string)
-	editsByAction := make(map[robustio.FileID]map[*action][]diff.Edit)
+// A moduleCache holds the analysis.Module of each module,
+// so that it is shared by all the actions on its packages.
+type moduleCache struct {
+	opts    *checkerOptions
+	gowork  string // value of GOWORK in the environment of the loader
+	modules map[packages.Module]*analysis.Module
+}
+
+func newModuleCache(opts *checkerOptions) *moduleCache {
+	env := os.Environ()
+	if opts.loadConfig != nil && opts.loadConfig.Env != nil {
+		env = opts.loadConfig.Env
+	}
+	c := &moduleCache{opts: opts, modules: make(map[packages.Module]*analysis.Module)}
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "GOWORK="); ok {
+			c.gowork = v // the last value prevails
+		}
+	}
+	return c
+}
+
+// get returns the analysis.Module for mod, which may be nil.
+func (c *moduleCache) get(mod *packages.Module) *analysis.Module {
+	if mod == nil {
+		return nil
+	}
+	key := *mod
+	if m, ok := c.modules[key]; ok {
+		return m
+	}
+	m := &analysis.Module{
+		Path:      mod.Path,
+		Version:   mod.Version,
+		GoVersion: analysisinternal.GoVersion(mod.GoVersion),
+	}
+	if mod.GoMod != "" {
+		var gowork string
+		if mod.Main && mod.Dir != "" {
+			gowork = analysisinternal.GoWorkFile(mod.Dir, c.gowork, c.opts.readFile)
+		}
+		// An unreadable go.mod file leaves Requires and Replaces nil.
+		_ = analysisinternal.ReadModFiles(m, mod.GoMod, gowork, c.opts.readFile)
+	}
+	c.modules[key] = m
+	return m
+}
+
+// A SkippedFix describes a suggested fix that was not applied (see
+// WithFix) because it was invalid or conflicted with another fix.
+type SkippedFix struct {
+	Analyzer *analysis.Analyzer
+	Posn     token.Position // position of the diagnostic
+	Message  string         // message of the diagnostic
+	Reason   string         // e.g. "conflicts with fix of rename at a.go:3:2"
+}
+
+func (s SkippedFix) String() string {
+	return fmt.Sprintf("%s: %s: fix for %q not applied: %s", s.Posn, s.Analyzer.Name, s.Message, s.Reason)
+}
+
+// applyFixes applies the suggested fixes of the actions in the graph
+// rooted at roots to the contents of the files as seen by the loader
+// (see checkerOptions.readFile), and writes the results to disk, or
+// in diff mode records them as unified diffs in opts.fixDiffs.
+// The results for overlaid files are recorded in opts.fixedOverlays
+// instead of being written.
+//
+// Only the first fix of each diagnostic is applied, since the fixes of
+// a diagnostic are alternatives. The fixes are merged one at a time,
+// in order of position and then of analyzer, into the edits accepted
+// so far, as if by a three-way merge (see diff.Merge): a fix whose
+// edits duplicate accepted ones merges cleanly, but one that conflicts
+// with them is skipped in its entirety. Skipped fixes, including
+// invalid ones, are recorded in opts.skippedFixes.
+func applyFixes(roots []*action, opts *checkerOptions) error {
+	// A fileKey identifies a file. Files on disk are identified by
+	// robustio.FileID, so that different names for the same file are
+	// recognized; overlaid files are identified by name.
+	type fileKey struct {
+		id      robustio.FileID
+		overlay string
+	}
+
+	// A fix holds the edits of the first suggested fix of a diagnostic.
+	type fix struct {
+		act   *action
+		diag  *analysis.Diagnostic
+		posn  token.Position          // position of diag
+		edits map[fileKey][]diff.Edit // sorted and free of overlaps
+	}
+
+	// skip records that fix f is not applied. A fix reported in
+	// several packages, such as p and p.test, is recorded once.
+	skipped := make(map[string]bool)
+	skip := func(f *fix, format string, args ...interface{}) {
+		s := SkippedFix{
+			Analyzer: f.act.a,
+			Posn:     f.posn,
+			Message:  f.diag.Message,
+			Reason:   fmt.Sprintf(format, args...),
+		}
+		if !skipped[s.String()] {
+			skipped[s.String()] = true
+			opts.skippedFixes = append(opts.skippedFixes, s)
+		}
+	}
+
+	// visit all of the actions and accumulate the suggested fixes.
+	paths := make(map[fileKey]string)
+	var fixes []*fix
+	addFix := func(act *action, diag *analysis.Diagnostic) error {
+		fset := act.pkg.Fset
+		f := &fix{act: act, diag: diag, posn: fset.Position(diag.Pos), edits: make(map[fileKey][]diff.Edit)}
+		for _, edit := range diag.SuggestedFixes[0].TextEdits {
+			// Validate the edit.
+			// Any error here indicates a bug in the analyzer.
+			start, end := edit.Pos, edit.End
+			file := fset.File(start)
+			if file == nil {
+				skip(f, "invalid fix: missing file info for pos (%v)", start)
+				return nil
+			}
+			if !end.IsValid() {
+				end = start
+			}
+			if start > end {
+				skip(f, "invalid fix: pos (%v) > end (%v)", start, end)
+				return nil
+			}
+			if eof := token.Pos(file.Base() + file.Size()); end > eof {
+				skip(f, "invalid fix: end (%v) past end of file (%v)", end, eof)
+				return nil
+			}
+
+			var id fileKey
+			if _, ok := opts.overlay(file.Name()); ok {
+				id.overlay = file.Name()
+			} else {
+				fid, _, err := robustio.GetFileID(file.Name())
+				if err != nil {
+					return err
+				}
+				id.id = fid
+			}
+			if _, hasId := paths[id]; !hasId {
+				paths[id] = file.Name()
+			}
+			f.edits[id] = append(f.edits[id], diff.Edit{
+				Start: file.Offset(start),
+				End:   file.Offset(end),
+				New:   string(edit.NewText),
+			})
+		}
+		for id, edits := range f.edits {
+			edits, invalid := validateEdits(edits)
+			if invalid > 0 {
+				skip(f, "invalid fix: overlapping edits to %s", paths[id])
+				return nil
+			}
+			f.edits[id] = edits
+		}
+		fixes = append(fixes, f)
+		return nil
+	}
 	visited := make(map[*action]bool)
-	var apply func(*action) error
 	var visitAll func(actions []*action) error
 	visitAll = func(actions []*action) error {
 		for _, act := range actions {
@@ -377,114 +1169,73 @@ func applyFixes(roots []*action) error {
 				if err := visitAll(act.deps); err != nil {
 					return err
 				}
-				if err := apply(act); err != nil {
-					return err
-				}
-			}
-		}
-		return nil
-	}
-
-	apply = func(act *action) error {
-		editsForTokenFile := make(map[*token.File][]diff.Edit)
-		for _, diag := range act.diagnostics {
-			for _, sf := range diag.SuggestedFixes {
-				for _, edit := range sf.TextEdits {
-					// Validate the edit.
-					// Any error here indicates a bug in the analyzer.
-					start, end := edit.Pos, edit.End
-					file := act.pkg.Fset.File(start)
-					if file == nil {
-						return fmt.Errorf("analysis %q suggests invalid fix: missing file info for pos (%v)",
-							act.a.Name, start)
-					}
-					if !end.IsValid() {
-						end = start
-					}
-					if start > end {
-						return fmt.Errorf("analysis %q suggests invalid fix: pos (%v) > end (%v)",
-							act.a.Name, start, end)
-					}
-					if eof := token.Pos(file.Base() + file.Size()); end > eof {
-						return fmt.Errorf("analysis %q suggests invalid fix: end (%v) past end of file (%v)",
-							act.a.Name, end, eof)
-					}
-					edit := diff.Edit{
-						Start: file.Offset(start),
-						End:   file.Offset(end),
-						New:   string(edit.NewText),
+				for i := range act.diagnostics {
+					if diag := &act.diagnostics[i]; len(diag.SuggestedFixes) > 0 {
+						if err := addFix(act, diag); err != nil {
+							return err
+						}
 					}
-					editsForTokenFile[file] = append(editsForTokenFile[file], edit)
 				}
 			}
 		}
-
-		for f, edits := range editsForTokenFile {
-			id, _, err := robustio.GetFileID(f.Name())
-			if err != nil {
-				return err
-			}
-			if _, hasId := paths[id]; !hasId {
-				paths[id] = f.Name()
-				editsByAction[id] = make(map[*action][]diff.Edit)
-			}
-			editsByAction[id][act] = edits
-		}
 		return nil
 	}
-
 	if err := visitAll(roots); err != nil {
 		return err
 	}
 
-	// Validate and group the edits to each actual file.
-	editsByPath := make(map[string][]diff.Edit)
-	for id, actToEdits := range editsByAction {
-		path := paths[id]
-		actions := make([]*action, 0, len(actToEdits))
-		for act := range actToEdits {
-			actions = append(actions, act)
+	// Merge the fixes in a deterministic order.
+	sort.SliceStable(fixes, func(i, j int) bool {
+		x, y := fixes[i], fixes[j]
+		if x.posn.Filename != y.posn.Filename {
+			return x.posn.Filename < y.posn.Filename
 		}
-
-		// Does any action create conflicting edits?
-		for _, act := range actions {
-			edits := actToEdits[act]
-			if _, invalid := validateEdits(edits); invalid > 0 {
-				name, x, y := act.a.Name, edits[invalid-1], edits[invalid]
-				return diff3Conflict(path, name, name, []diff.Edit{x}, []diff.Edit{y})
-			}
+		if x.posn.Offset != y.posn.Offset {
+			return x.posn.Offset < y.posn.Offset
 		}
-
-		// Does any pair of different actions create edits that conflict?
-		for j := range actions {
-			for k := range actions[:j] {
-				x, y := actions[j], actions[k]
-				if x.a.Name > y.a.Name {
-					x, y = y, x
-				}
-				xedits, yedits := actToEdits[x], actToEdits[y]
-				combined := append(xedits, yedits...)
-				if _, invalid := validateEdits(combined); invalid > 0 {
-					// TODO: consider applying each action's consistent list of edits entirely,
-					// and then using a three-way merge (such as GNU diff3) on the resulting
-					// files to report more precisely the parts that actually conflict.
-					return diff3Conflict(path, x.a.Name, y.a.Name, xedits, yedits)
+		return x.act.a.Name < y.act.a.Name
+	})
+	merged := make(map[fileKey][]diff.Edit)
+	accepted := make(map[fileKey][]*fix) // for reporting conflicts
+nextFix:
+	for _, f := range fixes {
+		result := make(map[fileKey][]diff.Edit, len(f.edits))
+		for id, edits := range f.edits {
+			m, ok := diff.Merge(merged[id], edits)
+			if !ok {
+				// Name an accepted fix with which f conflicts.
+				reason := "conflicts with another fix"
+				for _, g := range accepted[id] {
+					if _, ok := diff.Merge(g.edits[id], edits); !ok {
+						reason = fmt.Sprintf("conflicts with fix of %s at %s", g.act.a.Name, g.posn)
+						break
+					}
 				}
+				skip(f, "%s", reason)
+				continue nextFix
 			}
+			result[id] = m
 		}
-
-		var edits []diff.Edit
-		for act := range actToEdits {
-			edits = append(edits, actToEdits[act]...)
+		for id, m := range result {
+			merged[id] = m
+			accepted[id] = append(accepted[id], f)
 		}
-		editsByPath[path], _ = validateEdits(edits) // remove duplicates. already validated.
 	}
+	sort.SliceStable(opts.skippedFixes, func(i, j int) bool {
+		x, y := opts.skippedFixes[i].Posn, opts.skippedFixes[j].Posn
+		if x.Filename != y.Filename {
+			return x.Filename < y.Filename
+		}
+		return x.Offset < y.Offset
+	})
 
 	// Now we've got a set of valid edits for each file. Apply them.
-	for path, edits := range editsByPath {
+	for id, edits := range merged {
+		path := paths[id]
+
 		// TODO(adonovan): this should really work on the same
 		// gulp from the file system that fed the analyzer (see #62292).
-		contents, err := os.ReadFile(path)
+		contents, err := opts.readFile(path)
 		if err != nil {
 			return err
 		}
@@ -499,6 +1250,20 @@ func applyFixes(roots []*action) error {
 			out = formatted
 		}
 
+		if opts.diff {
+			if opts.fixDiffs == nil {
+				opts.fixDiffs = make(map[string]string)
+			}
+			opts.fixDiffs[path] = diff.Unified(path, path, string(contents), string(out))
+			continue
+		}
+		if id.overlay != "" {
+			if opts.fixedOverlays == nil {
+				opts.fixedOverlays = make(map[string][]byte)
+			}
+			opts.fixedOverlays[path] = out
+			continue
+		}
 		if err := os.WriteFile(path, out, 0644); err != nil {
 			return err
 		}
@@ -536,37 +1301,15 @@ func validateEdits(edits []diff.Edit) ([]diff.Edit, int) {
 	return unique, invalid
 }
 
-// diff3Conflict returns an error describing two conflicting sets of
-// edits on a file at path.
-func diff3Conflict(path string, xlabel, ylabel string, xedits, yedits []diff.Edit) error {
-	contents, err := os.ReadFile(path)
-	if err != nil {
-		return err
-	}
-	oldlabel, old := "base", string(contents)
-
-	xdiff, err := diff.ToUnified(oldlabel, xlabel, old, xedits, diff.DefaultContextLines)
-	if err != nil {
-		return err
-	}
-	ydiff, err := diff.ToUnified(oldlabel, ylabel, old, yedits, diff.DefaultContextLines)
-	if err != nil {
-		return err
-	}
-
-	return fmt.Errorf("conflicting edits from %s and %s on %s\nfirst edits:\n%s\nsecond edits:\n%s",
-		xlabel, ylabel, path, xdiff, ydiff)
-}
-
-// printDiagnostics prints the diagnostics for the root packages in either
-// plain text or JSON format. JSON format also includes errors for any
-// dependencies.
+// printDiagnostics prints the diagnostics for the root packages in
+// plain text, JSON or SARIF format. JSON and SARIF formats also include
+// errors for any dependencies.
 //
 // It returns the exitcode: in plain mode, 0 for success, 1 for analysis
 // errors, and 3 for diagnostics. We avoid 2 since the flag package uses
-// it. JSON mode always succeeds at printing errors and diagnostics in a
-// structured form to stdout.
-func printDiagnostics(roots []*action) (exitcode int) {
+// it. JSON and SARIF modes always succeed at printing errors and
+// diagnostics in a structured form to stdout.
+func printDiagnostics(roots []*action, opts *checkerOptions) (exitcode int) {
 	// Print the output.
 	//
 	// Print diagnostics only for root packages,
@@ -584,7 +1327,19 @@ func printDiagnostics(roots []*action) (exitcode int) {
 		}
 	}
 
-	if analysisflags.JSON {
+	if analysisflags.SARIF {
+		// SARIF output
+		sarif := analysisflags.NewSARIFLog(filepath.Base(os.Args[0]))
+		print = func(act *action) {
+			var diags []analysis.Diagnostic
+			if act.isroot {
+				diags = act.diagnostics
+			}
+			sarif.Add(act.pkg.Fset, act.pkg.ID, act.a, diags, act.err)
+		}
+		visitAll(roots)
+		sarif.Print()
+	} else if analysisflags.JSON {
 		// JSON output
 		tree := make(analysisflags.JSONTree)
 		print = func(act *action) {
@@ -641,9 +1396,9 @@ func printDiagnostics(roots []*action) (exitcode int) {
 	}
 
 	// Print timing info.
-	if dbg('t') {
-		if !dbg('p') {
-			log.Println("Warning: times are mostly GC/scheduler noise; use -debug=tp to disable parallelism")
+	if opts.dbg('t') {
+		if !opts.dbg('p') {
+			opts.logger.Println("Warning: times are mostly GC/scheduler noise; use -debug=tp to disable parallelism")
 		}
 		var all []*action
 		var total time.Duration
@@ -697,15 +1452,21 @@ type action struct {
 	once         sync.Once
 	a            *analysis.Analyzer
 	pkg          *packages.Package
+	module       *analysis.Module
+	opts         *checkerOptions
 	pass         *analysis.Pass
 	isroot       bool
 	deps         []*action
 	objectFacts  map[objectFactKey]analysis.Fact
 	packageFacts map[packageFactKey]analysis.Fact
+	contributed  map[contributedFactKey]analysis.Fact
 	result       interface{}
 	diagnostics  []analysis.Diagnostic
 	err          error
 	duration     time.Duration
+	factTime     time.Duration // time spent inheriting, encoding and decoding facts
+	key          cacheKey      // inputs to the action; set only if caching
+	cached       bool          // outputs were restored from the cache
 }
 
 type objectFactKey struct {
@@ -718,12 +1479,36 @@ type packageFactKey struct {
 	typ reflect.Type
 }
 
+type contributedFactKey struct {
+	from *types.Package // contributing package
+	obj  types.Object
+	typ  reflect.Type
+}
+
+// ownFacts returns the facts exported by act about its own package
+// and the objects it declares, keyed by object; package facts have a
+// nil key.
+func (act *action) ownFacts() map[types.Object][]analysis.Fact {
+	facts := make(map[types.Object][]analysis.Fact)
+	for key, fact := range act.objectFacts {
+		if key.obj.Pkg() == act.pkg.Types {
+			facts[key.obj] = append(facts[key.obj], fact)
+		}
+	}
+	for key, fact := range act.packageFacts {
+		if key.pkg == act.pkg.Types {
+			facts[nil] = append(facts[nil], fact)
+		}
+	}
+	return facts
+}
+
 func (act *action) String() string {
 	return fmt.Sprintf("%s@%s", act.a, act.pkg)
 }
 
-func execAll(actions []*action) {
-	sequential := dbg('p')
+func execAll(actions []*action, opts *checkerOptions) {
+	sequential := opts.dbg('p')
 	var wg sync.WaitGroup
 	for _, act := range actions {
 		wg.Add(1)
@@ -740,11 +1525,77 @@ func execAll(actions []*action) {
 	wg.Wait()
 }
 
-func (act *action) exec() { act.once.Do(act.execOnce) }
+func (act *action) exec() {
+	act.once.Do(func() {
+		act.execOnce()
+		act.complete()
+	})
+}
+
+// complete records the completion of act,
+// and reports it to the onActionComplete callback, if any.
+func (act *action) complete() {
+	facts := 0
+	for _, list := range act.ownFacts() {
+		facts += len(list)
+	}
+	act.opts.mu.Lock()
+	act.opts.completed = append(act.opts.completed, &ActionProfile{
+		Analyzer:      act.a,
+		Package:       act.pkg,
+		Duration:      act.duration,
+		FactTime:      act.factTime,
+		FactsExported: facts,
+		Cached:        act.cached,
+	})
+	act.opts.mu.Unlock()
+
+	// The root actions of whole-program analyzers
+	// are reported once their Finish function is done.
+	if f := act.opts.onActionComplete; f != nil && !(act.isroot && act.a.Finish != nil) {
+		act.notify(f)
+	}
+}
+
+// notify calls f to report the completion of act.
+func (act *action) notify(f func(*ActionEvent)) {
+	event := &ActionEvent{
+		Analyzer: act.a,
+		Package:  act.pkg,
+		IsRoot:   act.isroot,
+		Duration: act.duration,
+		AnalyzerResult: AnalyzerResult{
+			Result: act.result,
+			Facts:  act.ownFacts(),
+			Err:    act.err,
+			Cached: act.cached,
+		},
+	}
+	for _, d := range act.diagnostics {
+		event.Diagnostics = append(event.Diagnostics, d.ToSimple(act.pkg.Fset))
+	}
+	act.opts.mu.Lock()
+	defer act.opts.mu.Unlock()
+	f(event)
+}
 
 func (act *action) execOnce() {
+	// Don't start new work after cancellation.
+	if err := interrupted(act.opts.ctx, "analysis"); err != nil {
+		act.err = err
+		return
+	}
+
+	if act.opts.cacheDir != "" && act.restore() {
+		return
+	}
+
 	// Analyze dependencies.
-	execAll(act.deps)
+	execAll(act.deps, act.opts)
+	if err := interrupted(act.opts.ctx, "analysis"); err != nil {
+		act.err = err
+		return
+	}
 
 	// TODO(adonovan): uncomment this during profiling.
 	// It won't build pre-go1.11 but conditional compilation
@@ -759,10 +1610,8 @@ func (act *action) execOnce() {
 	// time is 5x higher than in sequential mode, even with a
 	// semaphore limiting the number of threads here.
 	// So use -debug=tp.
-	if dbg('t') {
-		t0 := time.Now()
-		defer func() { act.duration = time.Since(t0) }()
-	}
+	t0 := time.Now()
+	defer func() { act.duration = time.Since(t0) }()
 
 	// Report an error if any dependency failed.
 	var failed []string
@@ -782,6 +1631,7 @@ func (act *action) execOnce() {
 	inputs := make(map[*analysis.Analyzer]interface{})
 	act.objectFacts = make(map[objectFactKey]analysis.Fact)
 	act.packageFacts = make(map[packageFactKey]analysis.Fact)
+	act.contributed = make(map[contributedFactKey]analysis.Fact)
 	for _, dep := range act.deps {
 		if dep.pkg == act.pkg {
 			// Same package, different analysis (horizontal edge):
@@ -808,24 +1658,35 @@ func (act *action) execOnce() {
 		TypesInfo:    act.pkg.TypesInfo,
 		TypesSizes:   act.pkg.TypesSizes,
 		TypeErrors:   act.pkg.TypeErrors,
-
-		ResultOf:          inputs,
-		Report:            func(d analysis.Diagnostic) { act.diagnostics = append(act.diagnostics, d) },
-		ImportObjectFact:  act.importObjectFact,
-		ExportObjectFact:  act.exportObjectFact,
-		ImportPackageFact: act.importPackageFact,
-		ExportPackageFact: act.exportPackageFact,
-		AllObjectFacts:    act.allObjectFacts,
-		AllPackageFacts:   act.allPackageFacts,
+		Module:       act.module,
+
+		ResultOf:             inputs,
+		Report:               func(d analysis.Diagnostic) { act.diagnostics = append(act.diagnostics, d) },
+		ImportObjectFact:     act.importObjectFact,
+		ExportObjectFact:     act.exportObjectFact,
+		ImportPackageFact:    act.importPackageFact,
+		ExportPackageFact:    act.exportPackageFact,
+		AllObjectFacts:       act.allObjectFacts,
+		AllPackageFacts:      act.allPackageFacts,
+		ContributeObjectFact: act.contributeObjectFact,
+		AllContributedFacts:  act.allContributedFacts,
+	}
+	pass.ReadFile = func(filename string) ([]byte, error) {
+		if err := analysisinternal.CheckReadable(pass, filename); err != nil {
+			return nil, err
+		}
+		return act.opts.readFile(filename)
 	}
-	pass.ReadFile = analysisinternal.MakeReadFile(pass)
 	act.pass = pass
 
 	var err error
 	if act.pkg.IllTyped && !pass.Analyzer.RunDespiteErrors {
 		err = fmt.Errorf("analysis skipped due to errors in package")
+	} else if restore, ferr := act.setFlags(); ferr != nil {
+		err = ferr
 	} else {
 		act.result, err = pass.Analyzer.Run(pass)
+		restore()
 		if err == nil {
 			if got, want := reflect.TypeOf(act.result), pass.Analyzer.ResultType; got != want {
 				err = fmt.Errorf(
@@ -848,12 +1709,20 @@ func (act *action) execOnce() {
 	// disallow calls after Run
 	pass.ExportObjectFact = nil
 	pass.ExportPackageFact = nil
+	pass.ContributeObjectFact = nil
+
+	if act.opts.cacheDir != "" {
+		act.save()
+	}
 }
 
 // inheritFacts populates act.facts with
 // those it obtains from its dependency, dep.
 func inheritFacts(act, dep *action) {
-	serialize := dbg('s')
+	t0 := time.Now()
+	defer func() { act.factTime += time.Since(t0) }()
+
+	serialize := act.opts.dbg('s')
 
 	for key, fact := range dep.objectFacts {
 		// Filter out facts related to objects
@@ -903,6 +1772,20 @@ func inheritFacts(act, dep *action) {
 		}
 		act.packageFacts[key] = fact
 	}
+
+	// Contributed facts are inherited in full: unlike object
+	// facts, they may concern any object the contributor could
+	// see, and are of interest to all its dependents.
+	for key, fact := range dep.contributed {
+		if serialize {
+			encodedFact, err := codeFact(fact)
+			if err != nil {
+				log.Panicf("internal error: encoding of %T fact failed in %v: %v", fact, act, err)
+			}
+			fact = encodedFact
+		}
+		act.contributed[key] = fact
+	}
 }
 
 // codeFact encodes then decodes a fact,
@@ -988,7 +1871,7 @@ func (act *action) exportObjectFact(obj types.Object, fact analysis.Fact) {
 
 	key := objectFactKey{obj, factType(fact)}
 	act.objectFacts[key] = fact // clobber any existing entry
-	if dbg('f') {
+	if act.opts.dbg('f') {
 		objstr := types.ObjectString(obj, (*types.Package).Name)
 		fmt.Fprintf(os.Stderr, "%s: object %s has fact %s\n",
 			act.pkg.Fset.Position(obj.Pos()), objstr, fact)
@@ -1027,7 +1910,7 @@ func (act *action) exportPackageFact(fact analysis.Fact) {
 
 	key := packageFactKey{act.pass.Pkg, factType(fact)}
 	act.packageFacts[key] = fact // clobber any existing entry
-	if dbg('f') {
+	if act.opts.dbg('f') {
 		fmt.Fprintf(os.Stderr, "%s: package %s has fact %s\n",
 			act.pkg.Fset.Position(act.pass.Files[0].Pos()), act.pass.Pkg.Path(), fact)
 	}
@@ -1050,4 +1933,29 @@ func (act *action) allPackageFacts() []analysis.PackageFact {
 	return facts
 }
 
-func dbg(b byte) bool { return strings.IndexByte(Debug, b) >= 0 }
+// contributeObjectFact implements Pass.ContributeObjectFact.
+func (act *action) contributeObjectFact(obj types.Object, fact analysis.Fact) {
+	if act.pass.ContributeObjectFact == nil {
+		log.Panicf("%s: Pass.ContributeObjectFact(%s, %T) called after Run", act, obj, fact)
+	}
+	if obj.Pkg() == nil {
+		log.Panicf("%s: Pass.ContributeObjectFact(%s, %T): can't contribute fact about object belonging to no package", act, obj, fact)
+	}
+
+	key := contributedFactKey{act.pass.Pkg, obj, factType(fact)}
+	act.contributed[key] = fact // clobber any existing entry
+	if act.opts.dbg('f') {
+		objstr := types.ObjectString(obj, (*types.Package).Name)
+		fmt.Fprintf(os.Stderr, "%s: object %s has fact %s contributed by %s\n",
+			act.pkg.Fset.Position(obj.Pos()), objstr, fact, act.pass.Pkg.Path())
+	}
+}
+
+// allContributedFacts implements Pass.AllContributedFacts.
+func (act *action) allContributedFacts() []analysis.ContributedFact {
+	facts := make([]analysis.ContributedFact, 0, len(act.contributed))
+	for k, fact := range act.contributed {
+		facts = append(facts, analysis.ContributedFact{Object: k.obj, From: k.from.Path(), Fact: fact})
+	}
+	return facts
+}
@@ -5,12 +5,17 @@
 package checker_test
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
+	"go/types"
+	"log"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/TBD54566975/golang-tools/go/analysis"
@@ -18,6 +23,8 @@ import (
 	"github.com/TBD54566975/golang-tools/go/analysis/internal/checker"
 	"github.com/TBD54566975/golang-tools/go/analysis/passes/inspect"
 	"github.com/TBD54566975/golang-tools/go/ast/inspector"
+	"github.com/TBD54566975/golang-tools/go/packages"
+	"github.com/TBD54566975/golang-tools/internal/analysisinternal"
 	"github.com/TBD54566975/golang-tools/internal/testenv"
 	"github.com/TBD54566975/golang-tools/internal/testfiles"
 	"github.com/TBD54566975/golang-tools/txtar"
@@ -362,3 +369,808 @@ hello from other
 		t.Error("analyzer did not run")
 	}
 }
+
+func TestBaseline(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	const src = `
+-- go.mod --
+module example.com
+
+-- p/p.go --
+package p
+
+func F() {
+	bar := 1
+	_ = bar
+}
+
+type T struct{}
+
+func (*T) M() {
+	bar := 1
+	_ = bar
+}
+
+-- q/q.go --
+package q
+
+func H() {
+	bar := 1
+	_ = bar
+}
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	baseline := filepath.Join(dir, "baseline.json")
+	run := func(pattern string, update bool) *checker.Result {
+		t.Helper()
+		res, err := checker.RunWithResult([]string{pattern}, []*analysis.Analyzer{renameAnalyzer},
+			checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}),
+			checker.WithBaseline(baseline, update))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// Record the existing diagnostics.
+	if res := run("./...", true); len(res.Diagnostics()) > 0 {
+		t.Errorf("diagnostics were not suppressed while writing baseline: %v", res.Diagnostics())
+	}
+	data, err := os.ReadFile(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"file": "p/p.go"`, `"function": "F"`, `"function": "T.M"`, `"count": 2`, `"file": "q/q.go"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("baseline does not contain %s:\n%s", want, data)
+		}
+	}
+
+	// Delete F, move M, and add G. Only the diagnostics in G are
+	// new, and the entry for F is stale.
+	const edited = `package p
+
+type T struct{}
+
+// M has moved.
+func (*T) M() {
+	bar := 1
+	_ = bar
+}
+
+func G() {
+	bar := 1
+	_ = bar
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "p/p.go"), []byte(edited), 0666); err != nil {
+		t.Fatal(err)
+	}
+	res := run("./...", false)
+	var got []string
+	for _, d := range res.Diagnostics() {
+		got = append(got, fmt.Sprintf("%d: %s", d.Pos.Line, d.Message))
+	}
+	want := []string{`12: renaming "bar" to "baz"`, `13: renaming "bar" to "baz"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics %q, want %q", got, want)
+	}
+	if len(res.StaleBaseline) != 1 || res.StaleBaseline[0].Function != "F" || res.StaleBaseline[0].Count != 2 {
+		t.Errorf("got stale entries %v, want the two for F", res.StaleBaseline)
+	}
+
+	// Entries for files that a run does not analyze are not
+	// stale, and are kept when it rewrites the baseline.
+	if res := run("./q", false); len(res.StaleBaseline) > 0 {
+		t.Errorf("got stale entries %v for a run over q alone, want none", res.StaleBaseline)
+	}
+	run("./p", true)
+	data, err = os.ReadFile(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"function": "G"`, `"function": "H"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("rewritten baseline does not contain %s:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), `"function": "F"`) {
+		t.Errorf("rewritten baseline contains stale entry for F:\n%s", data)
+	}
+
+	// Update mode requires a file name.
+	if _, err := checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{renameAnalyzer},
+		checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}),
+		checker.WithBaseline("", true)); err == nil {
+		t.Errorf("updating a baseline without a file name succeeded")
+	}
+}
+
+func TestBaselineLineDirective(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	const src = `
+-- go.mod --
+module example.com
+
+-- p/p.go --
+package p
+
+//line gen.y:10
+func F() {
+	bar := 1
+	_ = bar
+}
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	baseline := filepath.Join(dir, "baseline.json")
+	run := func(update bool) *checker.Result {
+		t.Helper()
+		res, err := checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{renameAnalyzer},
+			checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}),
+			checker.WithBaseline(baseline, update))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// The entries name the file itself, not the one named by the
+	// directive, so rewriting the baseline replaces them.
+	for i := 0; i < 2; i++ {
+		run(true)
+		data, err := os.ReadFile(baseline)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{`"file": "p/p.go"`, `"count": 2`} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("baseline written by run %d does not contain %s:\n%s", i+1, want, data)
+			}
+		}
+	}
+
+	// Once F is deleted, its entry is stale.
+	if err := os.WriteFile(filepath.Join(dir, "p/p.go"), []byte("package p\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if res := run(false); len(res.StaleBaseline) != 1 || res.StaleBaseline[0].Function != "F" {
+		t.Errorf("got stale entries %v, want the one for F", res.StaleBaseline)
+	}
+}
+
+func TestBaselineFailure(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// flaky reports each package, unless it fails.
+	fail := false
+	flaky := &analysis.Analyzer{
+		Name: "flaky",
+		Doc:  "reports each package, or fails",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			if fail {
+				return nil, fmt.Errorf("failed")
+			}
+			pass.Reportf(pass.Files[0].Name.Pos(), "package %s", pass.Pkg.Name())
+			return nil, nil
+		},
+	}
+
+	const src = `
+-- go.mod --
+module example.com
+
+-- p/p.go --
+package p
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	baseline := filepath.Join(dir, "baseline.json")
+	for _, fail = range []bool{false, true} {
+		res, err := checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{flaky},
+			checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}),
+			checker.WithBaseline(baseline, !fail))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The entry for p is not stale just because
+		// the analysis of p failed.
+		if len(res.StaleBaseline) > 0 {
+			t.Errorf("fail=%t: got stale entries %v, want none", fail, res.StaleBaseline)
+		}
+	}
+}
+
+func TestIgnoreDirectives(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	const src = `
+-- go.mod --
+module example.com
+
+-- p/p.go --
+package p
+
+func F() {
+	bar := 1 //analysis:ignore rename bar is fine here
+	_ = bar
+}
+
+//analysis:ignore rename,nosuch no reason to rename
+func G() {
+	bar := 1
+	_ = bar
+}
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	res, err := checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{renameAnalyzer},
+		checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range res.Diagnostics() {
+		got = append(got, fmt.Sprintf("%d: %s", d.Pos.Line, d.Message))
+	}
+	want := []string{
+		`8: unknown analyzer "nosuch" in //analysis:ignore directive`, // reported by "ignore"
+		`5: renaming "bar" to "baz"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics %q, want %q", got, want)
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// words reports each identifier equal to its -word flag.
+	words := &analysis.Analyzer{
+		Name: "words",
+		Doc:  "reports a word",
+	}
+	word := words.Flags.String("word", "bar", "word to report")
+	words.Run = func(pass *analysis.Pass) (interface{}, error) {
+		for _, f := range pass.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && id.Name == *word {
+					pass.Reportf(id.Pos(), "found %s", id.Name)
+				}
+				return true
+			})
+		}
+		return nil, nil
+	}
+
+	const src = `
+-- go.mod --
+module example.com
+
+-- analysis.json --
+{
+	"settings": {"words": {"word": "foo"}},
+	"overrides": [
+		{"paths": ["q/..."], "settings": {"words": {"word": "baz"}}},
+		{"paths": ["r"], "analyzers": {"words": false}},
+		{"paths": ["nosuch"], "analyzers": {"nosuch": false}}
+	]
+}
+
+-- p/p.go --
+package p
+
+var foo, bar, baz int
+
+-- q/q.go --
+package q
+
+var foo, bar, baz int
+
+-- q/sub/sub.go --
+package sub
+
+var foo, bar, baz int
+
+-- r/r.go --
+package r
+
+var foo, bar, baz int
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	run := func(useConfig bool) []string {
+		res, err := checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{words},
+			checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}),
+			checker.WithConfigFiles(useConfig))
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		var got []string
+		for _, d := range res.Diagnostics() {
+			rel, _ := filepath.Rel(dir, d.Pos.Filename)
+			got = append(got, fmt.Sprintf("%s: %s", filepath.ToSlash(rel), d.Message))
+		}
+		return got
+	}
+	want := []string{
+		"p/p.go: found foo",
+		"q/q.go: found baz",
+		"q/sub/sub.go: found baz",
+	}
+	if got := run(true); !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics %q, want %q", got, want)
+	}
+	if *word != "bar" {
+		t.Errorf("flag -word is %q after run, want %q", *word, "bar")
+	}
+
+	// Concurrent runs do not observe each other's flags.
+	wantDefault := []string{
+		"p/p.go: found bar",
+		"q/q.go: found bar",
+		"q/sub/sub.go: found bar",
+		"r/r.go: found bar",
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if got := run(true); !reflect.DeepEqual(got, want) {
+				t.Errorf("concurrent run with config: got diagnostics %q, want %q", got, want)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if got := run(false); !reflect.DeepEqual(got, wantDefault) {
+				t.Errorf("concurrent run without config: got diagnostics %q, want %q", got, wantDefault)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPassModule(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	var got *analysis.Module
+	a := &analysis.Analyzer{
+		Name: "module",
+		Doc:  "records the module of its package",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			got = pass.Module
+			return nil, nil
+		},
+	}
+
+	const src = `
+-- go.mod --
+module example.com
+
+go 1.21
+
+require (
+	example.net/a v1.0.0
+	example.net/b v1.2.0 // indirect
+)
+
+replace example.net/a => ./a
+
+-- p/p.go --
+package p
+
+-- a/go.mod --
+module example.net/a
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	mode := packages.LoadSyntax | packages.NeedModule
+	if _, err := checker.RunWithResult([]string{"./p"}, []*analysis.Analyzer{a},
+		checker.WithLoadConfig(packages.Config{Mode: mode, Dir: dir})); err != nil {
+		t.Fatal(err)
+	}
+	want := &analysis.Module{
+		Path:      "example.com",
+		GoVersion: "go1.21",
+		Requires: []analysis.ModuleRequirement{
+			{Path: "example.net/a", Version: "v1.0.0"},
+			{Path: "example.net/b", Version: "v1.2.0", Indirect: true},
+		},
+		Replaces: []analysis.ModuleReplacement{
+			{OldPath: "example.net/a", NewPath: "./a"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Pass.Module = %+v, want %+v", got, want)
+	}
+}
+
+func TestOverlaidModuleAndConfig(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	var module *analysis.Module
+	a := &analysis.Analyzer{
+		Name: "module",
+		Doc:  "records the module of its package",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			module = pass.Module
+			return nil, nil
+		},
+	}
+	ran := false
+	disabled := &analysis.Analyzer{
+		Name: "disabled",
+		Doc:  "records that it ran",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			ran = true
+			return nil, nil
+		},
+	}
+
+	const src = `
+-- go.mod --
+module example.com
+
+go 1.21
+
+-- p/p.go --
+package p
+
+-- a/go.mod --
+module example.net/a
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither the edits to go.mod nor the
+	// configuration file are on disk.
+	overlay := map[string][]byte{
+		filepath.Join(dir, "go.mod"):                        []byte("module example.com\n\ngo 1.21\n\nreplace example.net/a => ./a\n"),
+		filepath.Join(dir, analysisinternal.ConfigFileName): []byte(`{"analyzers": {"disabled": false}}`),
+	}
+	mode := packages.LoadSyntax | packages.NeedModule
+	if _, err := checker.RunWithResult([]string{"./p"}, []*analysis.Analyzer{a, disabled},
+		checker.WithLoadConfig(packages.Config{Mode: mode, Dir: dir, Overlay: overlay}),
+		checker.WithConfigFiles(true)); err != nil {
+		t.Fatal(err)
+	}
+	want := []analysis.ModuleReplacement{{OldPath: "example.net/a", NewPath: "./a"}}
+	if module == nil || !reflect.DeepEqual(module.Replaces, want) {
+		t.Errorf("Pass.Module = %+v, want Replaces %+v", module, want)
+	}
+	if ran {
+		t.Errorf("analyzer disabled by overlaid configuration file ran")
+	}
+}
+
+func TestFinish(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// unused reports exported functions used by no package.
+	unused := &analysis.Analyzer{
+		Name:       "unused",
+		Doc:        "reports unused exported functions",
+		ResultType: reflect.TypeOf(map[types.Object]bool(nil)),
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			used := make(map[types.Object]bool)
+			for _, obj := range pass.TypesInfo.Uses {
+				used[obj] = true
+			}
+			return used, nil
+		},
+	}
+	unused.Finish = func(pass *analysis.FinishPass) error {
+		used := make(map[types.Object]bool)
+		for _, pkg := range pass.Packages {
+			for obj := range pkg.Result.(map[types.Object]bool) {
+				used[obj] = true
+			}
+		}
+		for _, pkg := range pass.Packages {
+			scope := pkg.Pkg.Scope()
+			for _, name := range scope.Names() {
+				if fn, ok := scope.Lookup(name).(*types.Func); ok && fn.Exported() && !used[fn] {
+					pass.Reportf(fn.Pos(), "%s is unused", fn.Name())
+				}
+			}
+		}
+		return nil
+	}
+
+	const src = `
+-- go.mod --
+module example.com
+
+-- a/a.go --
+package a
+
+func Used()   {}
+func Unused() {}
+
+-- b/b.go --
+package b
+
+import "example.com/a"
+
+func F() { a.Used() }
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	var events []string // diagnostics reported by OnActionComplete
+	res, err := checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{unused},
+		checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}),
+		checker.WithOnActionComplete(func(e *checker.ActionEvent) {
+			for _, d := range e.Diagnostics {
+				events = append(events, d.Message)
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range res.Diagnostics() {
+		rel, _ := filepath.Rel(dir, d.Pos.Filename)
+		got = append(got, fmt.Sprintf("%s: %s", filepath.ToSlash(rel), d.Message))
+	}
+	want := []string{
+		"a/a.go: Unused is unused",
+		"b/b.go: F is unused",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics %q, want %q", got, want)
+	}
+	sort.Strings(events)
+	if want := []string{"F is unused", "Unused is unused"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("OnActionComplete got diagnostics %q, want %q", events, want)
+	}
+
+	// A failure of Finish is reported in the result.
+	unused.Finish = func(pass *analysis.FinishPass) error { return fmt.Errorf("oops") }
+	res, err = checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{unused},
+		checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.FinishErrors[unused]; err == nil || err.Error() != "oops" {
+		t.Errorf("FinishErrors[unused] = %v, want oops", err)
+	}
+}
+
+// CallFact, contributed by a package about a function of another
+// package, records a call to it.
+type CallFact struct{ Caller string }
+
+func (f *CallFact) AFact() {}
+
+func (f *CallFact) String() string { return "called by " + f.Caller }
+
+func TestContributedFacts(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// calls reports the calls to other packages that each package
+	// knows of, and exported functions called by no package.
+	calls := &analysis.Analyzer{
+		Name:      "calls",
+		Doc:       "reports calls to functions of other packages",
+		FactTypes: []analysis.Fact{new(CallFact)},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			for _, obj := range pass.TypesInfo.Uses {
+				if fn, ok := obj.(*types.Func); ok && fn.Pkg() != pass.Pkg {
+					pass.ContributeObjectFact(fn, &CallFact{Caller: pass.Pkg.Path()})
+				}
+			}
+			var seen []string
+			for _, f := range pass.AllContributedFacts() {
+				seen = append(seen, fmt.Sprintf("%s.%s %s", f.Object.Pkg().Name(), f.Object.Name(), f.Fact))
+			}
+			if seen != nil {
+				sort.Strings(seen)
+				pass.Reportf(pass.Files[0].Name.Pos(), "%s", strings.Join(seen, ", "))
+			}
+			return nil, nil
+		},
+		Finish: func(pass *analysis.FinishPass) error {
+			called := make(map[string]bool)
+			for _, f := range pass.AllContributedFacts() {
+				called[f.Object.Pkg().Path()+"."+f.Object.Name()] = true
+			}
+			for _, pkg := range pass.Packages {
+				scope := pkg.Pkg.Scope()
+				for _, name := range scope.Names() {
+					if fn, ok := scope.Lookup(name).(*types.Func); ok && !called[pkg.Pkg.Path()+"."+name] {
+						pass.Reportf(fn.Pos(), "%s is never called from another package", name)
+					}
+				}
+			}
+			return nil
+		},
+	}
+
+	const src = `
+-- go.mod --
+module example.com
+
+-- a/a.go --
+package a
+
+func Used()   {}
+func Unused() {}
+
+-- b/b.go --
+package b
+
+import "example.com/a"
+
+func F() { a.Used() }
+
+-- c/c.go --
+package c
+
+import "example.com/b"
+
+func G() { b.F() }
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"a/a.go: Unused is never called from another package",
+		"b/b.go: a.Used called by example.com/b",
+		"c/c.go: G is never called from another package",
+		"c/c.go: a.Used called by example.com/b, b.F called by example.com/c",
+	}
+
+	// The second run restores the contributed facts from the cache.
+	cache := t.TempDir()
+	for i := 0; i < 2; i++ {
+		res, err := checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{calls},
+			checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}),
+			checker.WithCacheDir(cache),
+			checker.WithDebug("s"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, d := range res.Diagnostics() {
+			rel, _ := filepath.Rel(dir, d.Pos.Filename)
+			got = append(got, fmt.Sprintf("%s: %s", filepath.ToSlash(rel), d.Message))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("run %d: got diagnostics %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// Two analyzers of the same name, which report
+	// different things about each package's module.
+	path := &analysis.Analyzer{
+		Name: "module",
+		Doc:  "reports the module path",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			pass.Reportf(pass.Files[0].Name.Pos(), "path %s", pass.Module.Path)
+			return nil, nil
+		},
+	}
+	version := &analysis.Analyzer{
+		Name: "module",
+		Doc:  "reports the module's Go version",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			pass.Reportf(pass.Files[0].Name.Pos(), "go version %s", pass.Module.GoVersion)
+			return nil, nil
+		},
+	}
+
+	const src = `
+-- go.mod --
+module example.com
+
+go 1.21
+
+-- p/p.go --
+package p
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	cache := t.TempDir()
+	run := func(a *analysis.Analyzer) []string {
+		res, err := checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{a},
+			checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax | packages.NeedModule, Dir: dir}),
+			checker.WithCacheDir(cache))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, d := range res.Diagnostics() {
+			got = append(got, d.Message)
+		}
+		return got
+	}
+	for _, test := range []struct {
+		a    *analysis.Analyzer
+		want string
+	}{
+		{path, "path example.com"},
+		{version, "go version go1.21"}, // not the cached result of path
+	} {
+		if got := run(test.a); !reflect.DeepEqual(got, []string{test.want}) {
+			t.Errorf("got diagnostics %q, want %q", got, test.want)
+		}
+	}
+
+	// Changing go.mod invalidates the cached result.
+	gomod := filepath.Join(dir, "go.mod")
+	if err := os.WriteFile(gomod, []byte("module example.com\n\ngo 1.22\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := run(version), []string{"go version go1.22"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after editing go.mod, got diagnostics %q, want %q", got, want)
+	}
+}
+
+func TestDebugLogging(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	const src = `
+-- go.mod --
+module example.com
+
+-- p/p.go --
+package p
+`
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verbose logging goes to the writer of the standard
+	// logger, but leaves its prefix and flags alone.
+	var buf bytes.Buffer
+	prefix, flags, w := log.Prefix(), log.Flags(), log.Writer()
+	log.SetPrefix("prog: ")
+	log.SetFlags(0)
+	log.SetOutput(&buf)
+	defer func() {
+		log.SetPrefix(prefix)
+		log.SetFlags(flags)
+		log.SetOutput(w)
+	}()
+	if _, err := checker.RunWithResult([]string{"./..."}, []*analysis.Analyzer{renameAnalyzer},
+		checker.WithLoadConfig(packages.Config{Mode: packages.LoadSyntax, Dir: dir}),
+		checker.WithDebug("v")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "load [./...]") {
+		t.Errorf("verbose output does not record loading:\n%s", buf.String())
+	}
+	if log.Prefix() != "prog: " || log.Flags() != 0 {
+		t.Errorf("standard logger has prefix %q and flags %d after run, want %q and 0", log.Prefix(), log.Flags(), "prog: ")
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file applies analysis configuration files (see
+// analysisinternal.FindConfig and WithConfigFiles) to the actions of a run.
+//
+// The flags of an analyzer are global variables shared by all its
+// passes, and by all concurrent runs. So when a configuration file
+// sets the flags of an analyzer for some package, the run excludes
+// all other runs of that analyzer (see lockFlags), and the passes of
+// that analyzer run one at a time, each with the flags configured for
+// its package.
+
+import (
+	"flag"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/internal/analysisflags"
+	"github.com/TBD54566975/golang-tools/go/packages"
+	"github.com/TBD54566975/golang-tools/internal/analysisinternal"
+)
+
+// loadSettings records in opts the configuration that applies to each
+// of the packages and their dependencies, and a lock for each analyzer
+// whose flags it sets, which serializes the passes of the analyzer
+// within the run.
+func loadSettings(pkgs []*packages.Package, analyzers []*analysis.Analyzer, opts *checkerOptions) error {
+	configs := make(map[string]*analysisinternal.Config) // by directory
+	opts.settings = make(map[*packages.Package]*analysisinternal.ConfigSettings)
+	configured := make(map[string]bool) // names of analyzers with configured flags
+	var err error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		dir := pkgDir(pkg)
+		if err != nil || dir == "" {
+			return
+		}
+		cfg, ok := configs[dir]
+		if !ok {
+			cfg, err = analysisinternal.FindConfig(dir, opts.readFile)
+			if err != nil {
+				return
+			}
+			configs[dir] = cfg
+		}
+		if cfg != nil {
+			s := cfg.Resolve(dir)
+			opts.settings[pkg] = s
+			for name := range s.Flags {
+				configured[name] = true
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	opts.flagLocks = make(map[*analysis.Analyzer]*sync.Mutex)
+	seen := make(map[*analysis.Analyzer]bool)
+	var visit func([]*analysis.Analyzer)
+	visit = func(analyzers []*analysis.Analyzer) {
+		for _, a := range analyzers {
+			if !seen[a] {
+				seen[a] = true
+				if configured[a.Name] {
+					opts.flagLocks[a] = new(sync.Mutex)
+				}
+				visit(a.Requires)
+			}
+		}
+	}
+	visit(analyzers)
+	return nil
+}
+
+// pkgDir returns the directory of the package's files, or "" if
+// it has none.
+func pkgDir(pkg *packages.Package) string {
+	for _, files := range [][]string{pkg.GoFiles, pkg.CompiledGoFiles, pkg.OtherFiles} {
+		if len(files) > 0 {
+			return filepath.Dir(files[0])
+		}
+	}
+	return ""
+}
+
+// enabled reports whether the configuration of pkg, if any,
+// enables analyzer a.
+func (co *checkerOptions) enabled(a *analysis.Analyzer, pkg *packages.Package) bool {
+	s := co.settings[pkg]
+	return s == nil || s.Enabled(a.Name)
+}
+
+// setFlags sets the flags of act's analyzer to the values configured
+// for its package, if the configuration sets the analyzer's flags for
+// any package, and returns a function that restores them. Until then,
+// no other pass of the analyzer can start.
+func (act *action) setFlags() (restore func(), err error) {
+	mu := act.opts.flagLocks[act.a]
+	if mu == nil {
+		return func() {}, nil
+	}
+	var values map[string]string
+	if s := act.opts.settings[act.pkg]; s != nil {
+		values = s.Flags[act.a.Name]
+	}
+	mu.Lock()
+	undo, err := analysisflags.SetFlags(act.a, values)
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		undo()
+		mu.Unlock()
+	}, nil
+}
+
+// analyzerLocks holds a lock for each analyzer with flags that has been
+// run, which serializes runs that set the analyzer's flags with all
+// other runs of the analyzer. Runs acquire the locks in the order of
+// their creation, so they cannot deadlock.
+var analyzerLocks struct {
+	mu    sync.Mutex
+	locks map[*analysis.Analyzer]*analyzerLock
+}
+
+type analyzerLock struct {
+	seq int // order of creation
+	mu  sync.RWMutex
+}
+
+// lockFlags acquires, for each of the analyzers and their
+// requirements that has flags, a lock that is exclusive if the run
+// sets its flags (see loadSettings) and shared otherwise, and returns
+// a function that releases them.
+func lockFlags(analyzers []*analysis.Analyzer, opts *checkerOptions) (unlock func()) {
+	type held struct {
+		lock      *analyzerLock
+		exclusive bool
+	}
+	var locks []held
+
+	analyzerLocks.mu.Lock()
+	if analyzerLocks.locks == nil {
+		analyzerLocks.locks = make(map[*analysis.Analyzer]*analyzerLock)
+	}
+	seen := make(map[*analysis.Analyzer]bool)
+	var visit func([]*analysis.Analyzer)
+	visit = func(analyzers []*analysis.Analyzer) {
+		for _, a := range analyzers {
+			if seen[a] {
+				continue
+			}
+			seen[a] = true
+			if hasFlags(a) {
+				l := analyzerLocks.locks[a]
+				if l == nil {
+					l = &analyzerLock{seq: len(analyzerLocks.locks)}
+					analyzerLocks.locks[a] = l
+				}
+				locks = append(locks, held{l, opts.flagLocks[a] != nil})
+			}
+			visit(a.Requires)
+		}
+	}
+	visit(analyzers)
+	analyzerLocks.mu.Unlock()
+
+	sort.Slice(locks, func(i, j int) bool { return locks[i].lock.seq < locks[j].lock.seq })
+	for _, h := range locks {
+		if h.exclusive {
+			h.lock.mu.Lock()
+		} else {
+			h.lock.mu.RLock()
+		}
+	}
+	return func() {
+		for _, h := range locks {
+			if h.exclusive {
+				h.lock.mu.Unlock()
+			} else {
+				h.lock.mu.RUnlock()
+			}
+		}
+	}
+}
+
+// hasFlags reports whether analyzer a has any flags.
+func hasFlags(a *analysis.Analyzer) bool {
+	has := false
+	a.Flags.VisitAll(func(*flag.Flag) { has = true })
+	return has
+}
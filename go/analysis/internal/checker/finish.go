@@ -0,0 +1,148 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file calls the Finish functions of whole-program analyzers
+// (see analysis.Analyzer.Finish) once their root actions are done.
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+)
+
+// finish calls the Finish function of each of the analyzers that has
+// one, with the results of its successful root actions. Diagnostics
+// are added to the root action of the package whose file they
+// concern, and errors are recorded in opts.finishErrors.
+//
+// The root actions of such analyzers are not complete until then, so
+// finish reports them to the onActionComplete callback, if any.
+func finish(roots []*action, analyzers []*analysis.Analyzer, opts *checkerOptions) {
+	for _, a := range analyzers {
+		if a.Finish == nil {
+			continue
+		}
+
+		var all, acts []*action // root actions of a, and the successful subset
+		for _, act := range roots {
+			if act.a == a {
+				all = append(all, act)
+				if act.err == nil {
+					acts = append(acts, act)
+				}
+			}
+		}
+
+		if len(acts) > 0 && interrupted(opts.ctx, "analysis") == nil {
+			if opts.dbg('v') {
+				opts.logger.Printf("finish %s", a)
+			}
+			t0 := time.Now()
+			if err := finishAnalyzer(a, acts, opts); err != nil {
+				if opts.finishErrors == nil {
+					opts.finishErrors = make(map[*analysis.Analyzer]error)
+				}
+				opts.finishErrors[a] = err
+			}
+			if opts.dbg('t') {
+				opts.logger.Printf("finish %s took %s", a, time.Since(t0))
+			}
+		}
+
+		if f := opts.onActionComplete; f != nil {
+			for _, act := range all {
+				act.notify(f)
+			}
+		}
+	}
+}
+
+// finishAnalyzer calls the Finish function of a, whose successful
+// root actions are acts.
+func finishAnalyzer(a *analysis.Analyzer, acts []*action, opts *checkerOptions) error {
+	// The actions inherit the facts of their dependencies,
+	// so together they hold all the facts of the program.
+	objectFacts := make(map[objectFactKey]analysis.Fact)
+	packageFacts := make(map[packageFactKey]analysis.Fact)
+	contributed := make(map[contributedFactKey]analysis.Fact)
+
+	byFile := make(map[string]*action) // root action by file name
+	readable := make(map[string]bool)
+	var pkgs []*analysis.PackageResult
+	for _, act := range acts {
+		for k, fact := range act.objectFacts {
+			objectFacts[k] = fact
+		}
+		for k, fact := range act.packageFacts {
+			packageFacts[k] = fact
+		}
+		for k, fact := range act.contributed {
+			contributed[k] = fact
+		}
+		for _, list := range [][]string{act.pkg.CompiledGoFiles, act.pkg.OtherFiles, act.pkg.IgnoredFiles} {
+			for _, filename := range list {
+				if byFile[filename] == nil {
+					byFile[filename] = act
+				}
+				readable[filename] = true
+			}
+		}
+		pkgs = append(pkgs, &analysis.PackageResult{
+			Pkg:       act.pkg.Types,
+			Files:     act.pkg.Syntax,
+			TypesInfo: act.pkg.TypesInfo,
+			Result:    act.result,
+		})
+	}
+
+	// Finish diagnostics in files of no root package (which
+	// Report disallows) are attributed to the first one.
+	fset := acts[0].pkg.Fset
+	pass := &analysis.FinishPass{
+		Analyzer: a,
+		Fset:     fset,
+		Packages: pkgs,
+		Report: func(d analysis.Diagnostic) {
+			// Ignore //line directives, which name
+			// files that are not among the packages'.
+			act := byFile[fset.PositionFor(d.Pos, false).Filename]
+			if act == nil {
+				act = acts[0]
+			}
+			act.diagnostics = append(act.diagnostics, d)
+		},
+		ReadFile: func(filename string) ([]byte, error) {
+			if !readable[filename] {
+				return nil, fmt.Errorf("Finish function of %s attempted to read %s, which is not a file of the program", a, filename)
+			}
+			return opts.readFile(filename)
+		},
+		AllObjectFacts: func() []analysis.ObjectFact {
+			facts := make([]analysis.ObjectFact, 0, len(objectFacts))
+			for k, fact := range objectFacts {
+				facts = append(facts, analysis.ObjectFact{Object: k.obj, Fact: fact})
+			}
+			return facts
+		},
+		AllPackageFacts: func() []analysis.PackageFact {
+			facts := make([]analysis.PackageFact, 0, len(packageFacts))
+			for k, fact := range packageFacts {
+				facts = append(facts, analysis.PackageFact{Package: k.pkg, Fact: fact})
+			}
+			return facts
+		},
+		AllContributedFacts: func() []analysis.ContributedFact {
+			facts := make([]analysis.ContributedFact, 0, len(contributed))
+			for k, fact := range contributed {
+				facts = append(facts, analysis.ContributedFact{Object: k.obj, From: k.from.Path(), Fact: fact})
+			}
+			return facts
+		},
+	}
+
+	return a.Finish(pass)
+}
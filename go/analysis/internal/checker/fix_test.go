@@ -61,10 +61,16 @@ const (
 // directory, applying the comma-separated list of named analyzers to
 // the packages matching the patterns. It returns the CombinedOutput.
 func fix(t *testing.T, dir, analyzers string, wantExit int, patterns ...string) string {
+	return runChecker(t, "-fix", dir, analyzers, wantExit, patterns...)
+}
+
+// runChecker is like fix, but runs the multichecker with the
+// specified flag instead of -fix.
+func runChecker(t *testing.T, flag, dir, analyzers string, wantExit int, patterns ...string) string {
 	testenv.NeedsExec(t)
 	testenv.NeedsTool(t, "go")
 
-	cmd := exec.Command(os.Args[0], "-fix")
+	cmd := exec.Command(os.Args[0], flag)
 	cmd.Args = append(cmd.Args, patterns...)
 	cmd.Env = append(os.Environ(),
 		"ANALYZERS="+analyzers,
@@ -205,9 +211,9 @@ func Foo() {
 	}
 	defer cleanup()
 
-	out := fix(t, dir, "rename,other", exitCodeFailed, "conflict")
+	out := fix(t, dir, "rename,other", exitCodeDiagnostics, "conflict")
 
-	pattern := `conflicting edits from rename and rename on .*foo.go`
+	pattern := `foo.go:4:2: rename: fix for .* not applied: invalid fix: overlapping edits to .*foo.go`
 	matched, err := regexp.MatchString(pattern, out)
 	if err != nil {
 		t.Errorf("error matching pattern %s: %v", pattern, err)
@@ -229,7 +235,8 @@ func Foo() {
 }
 
 // TestOther ensures that checker.Run reports conflicts from
-// distinct actions correctly.
+// distinct actions correctly, and applies the fixes that
+// do not conflict.
 // This test fork/execs the main function above.
 func TestOther(t *testing.T) {
 	files := map[string]string{
@@ -249,14 +256,69 @@ func Foo() {
 	}
 	defer cleanup()
 
-	out := fix(t, dir, "rename,other", exitCodeFailed, "other")
+	out := fix(t, dir, "rename,other", exitCodeDiagnostics, "other")
 
-	pattern := `.*conflicting edits from other and rename on .*foo.go`
-	matched, err := regexp.MatchString(pattern, out)
+	for _, pattern := range []string{
+		`foo.go:4:2: rename: fix for .* not applied: conflicts with fix of other at .*foo.go:4:2`,
+		`foo.go:5:6: rename: fix for .* not applied: conflicts with fix of other at .*foo.go:5:6`,
+	} {
+		matched, err := regexp.MatchString(pattern, out)
+		if err != nil {
+			t.Errorf("error matching pattern %s: %v", pattern, err)
+		} else if !matched {
+			t.Errorf("output did not match pattern: %s", pattern)
+		}
+	}
+
+	// The fixes of other, which come first, are applied.
+	path := path.Join(dir, "src/other/foo.go")
+	contents, err := os.ReadFile(path)
 	if err != nil {
-		t.Errorf("error matching pattern %s: %v", pattern, err)
-	} else if !matched {
-		t.Errorf("output did not match pattern: %s", pattern)
+		t.Fatal(err)
+	}
+	const want = `package other
+
+func Foo() {
+	bbaz := 12
+	_ = bbaz
+}
+
+// the end
+`
+	if got := string(contents); got != want {
+		t.Errorf("contents of %s file did not match expectations. got=%s, want=%s", path, got, want)
+	}
+}
+
+// TestDiff ensures that -diff prints the fixes as
+// unified diffs without modifying any files.
+// This test fork/execs the main function above.
+func TestDiff(t *testing.T) {
+	files := map[string]string{
+		"rename/foo.go": `package rename
+
+func Foo() {
+	bar := 12
+	_ = bar
+}
+`,
+	}
+	dir, cleanup, err := analysistest.WriteFiles(files)
+	if err != nil {
+		t.Fatalf("Creating test files failed with %s", err)
+	}
+	defer cleanup()
+
+	out := runChecker(t, "-diff", dir, "rename", exitCodeDiagnostics, "rename")
+
+	for _, want := range []string{
+		"-\tbar := 12\n+\tbaz := 12\n",
+		"-\t_ = bar\n+\t_ = baz\n",
+		"@@ -1,6 +1,6 @@",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q", want)
+		}
 	}
 
 	// No files updated
@@ -44,7 +44,8 @@ type CFGs struct {
 	defs      map[*ast.Ident]types.Object // from Pass.TypesInfo.Defs
 	funcDecls map[*types.Func]*declInfo
 	funcLits  map[*ast.FuncLit]*litInfo
-	pass      *analysis.Pass // transient; nil after construction
+	noReturns map[*ast.CallExpr]bool // call statements that never return
+	pass      *analysis.Pass         // transient; nil after construction
 }
 
 // CFGs has two maps: funcDecls for named functions and funcLits for
@@ -80,6 +81,15 @@ func (c *CFGs) FuncLit(lit *ast.FuncLit) *cfg.CFG {
 	return c.funcLits[lit].cfg
 }
 
+// CallMayReturn reports whether the call may return to its caller.
+// It reports false only for a call that is an expression statement
+// of a function in the current package, such as os.Exit(1) or
+// panic(err), and whose callee never returns; the CFG of the
+// enclosing function has no edge out of the block of such a call.
+func (c *CFGs) CallMayReturn(call *ast.CallExpr) bool {
+	return !c.noReturns[call]
+}
+
 func run(pass *analysis.Pass) (interface{}, error) {
 	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 
@@ -118,6 +128,7 @@ func run(pass *analysis.Pass) (interface{}, error) {
 		defs:      pass.TypesInfo.Defs,
 		funcDecls: funcDecls,
 		funcLits:  funcLits,
+		noReturns: make(map[*ast.CallExpr]bool),
 		pass:      pass,
 	}
 
@@ -184,6 +195,12 @@ func (c *CFGs) buildDecl(fn *types.Func, di *declInfo) {
 // callMayReturn reports whether the called function may return.
 // It is passed to the CFG builder.
 func (c *CFGs) callMayReturn(call *ast.CallExpr) (r bool) {
+	defer func() {
+		if !r {
+			c.noReturns[call] = true
+		}
+	}()
+
 	if id, ok := call.Fun.(*ast.Ident); ok && c.pass.TypesInfo.Uses[id] == panicBuiltin {
 		return false // panic never returns
 	}
@@ -29,5 +29,18 @@ func Test(t *testing.T) {
 				}
 			}
 		}
+
+		// Statement calls to panic never return.
+		ast.Inspect(result.Pass.Files[0], func(n ast.Node) bool {
+			if stmt, ok := n.(*ast.ExprStmt); ok {
+				if call, ok := stmt.X.(*ast.CallExpr); ok {
+					if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "panic" && cfgs.CallMayReturn(call) {
+						t.Errorf("%s: CallMayReturn(panic(...)) = true",
+							result.Pass.Fset.Position(call.Pos()))
+					}
+				}
+			}
+			return true
+		})
 	}
 }
@@ -0,0 +1,50 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package exhaustive defines an Analyzer that reports switch
+// statements that do not handle every case.
+//
+// # Analyzer exhaustive
+//
+// exhaustive: check for switches with missing cases
+//
+// The exhaustive checker reports two kinds of switch statement that
+// lack a default case and omit some of their possible cases:
+//
+//   - a switch on a value of a named type, such as an 'enum' type,
+//     that does not list every accessible named constant of that type
+//     declared in the type's package;
+//   - a type switch on a value of a sealed interface type, that is,
+//     one with an unexported method, that does not list every
+//     accessible named type T or pointer *T of the interface's
+//     package that implements it.
+//
+// For example:
+//
+//	type Suit int8
+//
+//	const (
+//		Spades Suit = iota
+//		Hearts
+//		Diamonds
+//		Clubs
+//	)
+//
+//	switch s { // missing cases in switch of type Suit: Diamonds, Clubs
+//	case Spades:
+//	case Hearts:
+//	}
+//
+// A constant is not missing if another case has the same value. The
+// suggested fix adds the missing cases and a default case that panics,
+// as does gopls' "Add cases" code action.
+//
+// Switches that are intentionally incomplete should have a default
+// case, or an //analysis:ignore directive naming this analyzer:
+//
+//	//analysis:ignore exhaustive only spades are special
+//	switch s {
+//	case Spades:
+//	}
+package exhaustive
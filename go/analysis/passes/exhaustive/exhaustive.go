@@ -0,0 +1,79 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package exhaustive
+
+import (
+	_ "embed"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/inspect"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/internal/analysisutil"
+	"github.com/TBD54566975/golang-tools/go/ast/inspector"
+	"github.com/TBD54566975/golang-tools/internal/fillswitch"
+)
+
+//go:embed doc.go
+var doc string
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "exhaustive",
+	Doc:      analysisutil.MustExtractDoc(doc, "exhaustive"),
+	URL:      "https://pkg.go.dev/github.com/TBD54566975/golang-tools/go/analysis/passes/exhaustive",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.SwitchStmt)(nil),
+		(*ast.TypeSwitchStmt)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		var missing *fillswitch.Missing
+		switch n := n.(type) {
+		case *ast.SwitchStmt:
+			if n.Tag != nil {
+				missing = fillswitch.SwitchCases(n, pass.Pkg, pass.TypesInfo)
+			}
+		case *ast.TypeSwitchStmt:
+			missing = fillswitch.TypeSwitchCases(n, pass.Pkg, pass.TypesInfo)
+			if missing != nil && !sealed(missing.Type) {
+				missing = nil // other packages may add cases
+			}
+		}
+		if missing == nil {
+			return
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:            n.Pos(),
+			End:            n.Pos() + token.Pos(len("switch")),
+			Message:        fmt.Sprintf("missing cases in switch of type %s: %s", missing.Type.Obj().Name(), strings.Join(missing.Cases, ", ")),
+			SuggestedFixes: []analysis.SuggestedFix{*missing.Fix()},
+		})
+	})
+	return nil, nil
+}
+
+// sealed reports whether t is an interface type with an unexported
+// method, which only types of its own package can implement.
+func sealed(t types.Type) bool {
+	iface, ok := t.Underlying().(*types.Interface)
+	if !ok {
+		return false
+	}
+	for i := 0; i < iface.NumMethods(); i++ {
+		if !iface.Method(i).Exported() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package a
+
+import (
+	"fmt"
+
+	"b"
+)
+
+type Suit int8
+
+const (
+	Spades Suit = iota
+	Hearts
+	Diamonds
+	Clubs
+
+	Default = Spades
+)
+
+func _(s Suit) {
+	switch s { // want "missing cases in switch of type Suit: Clubs, Diamonds"
+	case Spades:
+	case Hearts:
+	}
+
+	switch s {
+	case Spades, Hearts, Diamonds, Clubs: // ok: Default has the value of Spades
+	}
+
+	switch s {
+	case Spades:
+	default:
+	}
+
+	switch {
+	case s == Spades:
+	}
+}
+
+func _(c b.Color) {
+	switch c { // want "missing cases in switch of type Color: b.Blue"
+	case b.Red, b.Green:
+	}
+}
+
+func _(sh b.Shape) {
+	switch sh.(type) { // want `missing cases in switch of type Shape: \*b.Square`
+	case b.Circle:
+	}
+
+	switch sh := sh.(type) {
+	case b.Circle, *b.Square:
+		fmt.Println(sh)
+	}
+}
+
+func _(s b.Stringer) {
+	switch s.(type) { // ok: not sealed
+	case b.Name:
+	}
+}
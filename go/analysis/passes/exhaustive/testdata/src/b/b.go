@@ -0,0 +1,35 @@
+package b
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+	blank // unexported
+)
+
+// Shape is sealed: only this package can implement it.
+type Shape interface {
+	Area() float64
+	shape()
+}
+
+type Circle struct{}
+
+func (Circle) Area() float64 { return 0 }
+func (Circle) shape()        {}
+
+type Square struct{}
+
+func (*Square) Area() float64 { return 0 }
+func (*Square) shape()        {}
+
+// Stringer is not sealed.
+type Stringer interface {
+	String() string
+}
+
+type Name string
+
+func (Name) String() string { return string(Name("")) }
@@ -0,0 +1,55 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lockorder defines an Analyzer that reports misuse of
+// mutexes that may lead to deadlock.
+//
+// # Analyzer lockorder
+//
+// lockorder: check for double locking, missing unlocks, and inconsistent lock order
+//
+// The lockorder checker tracks the sync.Mutex and sync.RWMutex values
+// held along each path through a function, including mutexes embedded
+// in other types. It reports three kinds of mistake.
+//
+// A function that locks a mutex it already holds deadlocks:
+//
+//	s.mu.Lock()
+//	...
+//	s.mu.Lock() // s.mu is already locked
+//
+// Read-locking an RWMutex twice is also reported, because the second
+// RLock blocks if another goroutine is waiting to acquire the write lock.
+//
+// A function that unlocks a mutex on some paths but returns with it
+// locked on another has probably forgotten to unlock it:
+//
+//	s.mu.Lock()
+//	if s.closed {
+//		return errClosed // missing unlock of s.mu before return
+//	}
+//	s.mu.Unlock()
+//
+// Paths that end in a call that never returns, such as os.Exit or
+// log.Fatal, are ignored, as are functions that never unlock the mutex,
+// since they may be helpers whose callers unlock it.
+//
+// Two goroutines that lock the same pair of mutexes in opposite orders
+// may deadlock. The checker identifies mutexes by class: a struct
+// field of a particular named type, or a package-level variable. It
+// records an edge A -> B in the lock-order graph each time a function
+// locks B, or calls a function that may lock B, while holding A, and
+// reports an edge that completes a cycle in the graph:
+//
+//	func transfer(from, to *Account) {
+//		from.mu.Lock()
+//		defer from.mu.Unlock()
+//		audit.Lock() // lock ordering cycle may deadlock: bank.Account.mu -> bank.audit -> bank.Account.mu
+//		...
+//	}
+//
+// The graph spans packages: each function is summarized by the
+// classes of the mutexes it may lock, and each package by its edges,
+// as facts. Only calls with a static callee are considered.
+package lockorder
@@ -0,0 +1,593 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lockorder
+
+import (
+	_ "embed"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/buildssa"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/ctrlflow"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/internal/analysisutil"
+	"github.com/TBD54566975/golang-tools/go/ssa"
+)
+
+//go:embed doc.go
+var doc string
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "lockorder",
+	Doc:       analysisutil.MustExtractDoc(doc, "lockorder"),
+	URL:       "https://pkg.go.dev/github.com/TBD54566975/golang-tools/go/analysis/passes/lockorder",
+	Requires:  []*analysis.Analyzer{buildssa.Analyzer, ctrlflow.Analyzer},
+	FactTypes: []analysis.Fact{new(acquiresFact), new(orderFact)},
+	Run:       run,
+}
+
+// An acquiresFact records the classes of the mutexes that a function
+// may lock, directly or through the functions it calls.
+type acquiresFact struct {
+	Classes []string // sorted
+}
+
+func (*acquiresFact) AFact() {}
+
+func (f *acquiresFact) String() string {
+	var names []string
+	for _, c := range f.Classes {
+		names = append(names, className(c))
+	}
+	return "acquires " + strings.Join(names, ", ")
+}
+
+// An orderFact records the edges of the lock-order graph
+// contributed by the functions of a package.
+type orderFact struct {
+	Edges []edge
+}
+
+func (*orderFact) AFact() {}
+
+func (f *orderFact) String() string {
+	var edges []string
+	for _, e := range f.Edges {
+		edges = append(edges, className(e.From)+" -> "+className(e.To))
+	}
+	return strings.Join(edges, ", ")
+}
+
+// An edge records that a mutex of class To was locked while one of
+// class From was held.
+type edge struct {
+	From, To string
+	Posn     string // file:line of the Lock call or of the call to Via
+	Via      string // name of the function that locked To, if not locked directly
+
+	pos token.Pos // position of the edge in the current package
+}
+
+func (e *edge) String() string {
+	if e.Via != "" {
+		return fmt.Sprintf("%s locked by %s while holding %s", className(e.To), e.Via, className(e.From))
+	}
+	return fmt.Sprintf("%s locked while holding %s", className(e.To), className(e.From))
+}
+
+// A class identifies a set of mutexes, such as the mu field of every
+// value of type T ("path/to/pkg.T.mu"), or a package-level variable
+// ("path/to/pkg.mu").
+
+// className returns the short form of a class used in messages,
+// which names its package by the last element of its path.
+func className(class string) string {
+	if i := strings.LastIndex(class, "/"); i >= 0 {
+		return class[i+1:]
+	}
+	return class
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ssainput := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+
+	// Find the call statements that never return, by the position
+	// of their Lparen, which is the position of the SSA call.
+	noReturn := make(map[token.Pos]bool)
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if stmt, ok := n.(*ast.ExprStmt); ok {
+				if call, ok := stmt.X.(*ast.CallExpr); ok && !cfgs.CallMayReturn(call) {
+					noReturn[call.Lparen] = true
+				}
+			}
+			return true
+		})
+	}
+
+	// Summarize the mutexes locked by each function, iterating to
+	// a fixed point as they may call each other in any order.
+	acquires := make(map[*ssa.Function]map[string]bool)
+	for _, fn := range ssainput.SrcFuncs {
+		acquires[fn] = make(map[string]bool)
+	}
+	lookup := func(callee *ssa.Function) []string {
+		if callee == nil {
+			return nil
+		}
+		if orig := callee.Origin(); orig != nil {
+			callee = orig
+		}
+		if classes, ok := acquires[callee]; ok {
+			return keys(classes)
+		}
+		if obj, ok := callee.Object().(*types.Func); ok {
+			var f acquiresFact
+			if pass.ImportObjectFact(obj, &f) {
+				return f.Classes
+			}
+		}
+		return nil
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range ssainput.SrcFuncs {
+			classes := acquires[fn]
+			for _, b := range fn.Blocks {
+				for _, instr := range b.Instrs {
+					call, ok := instr.(*ssa.Call)
+					if !ok {
+						continue
+					}
+					var add []string
+					if op, recv := lockOp(call.Common()); op == "Lock" || op == "RLock" {
+						if _, _, class := instance(pass.Pkg, recv); class != "" {
+							add = []string{class}
+						}
+					} else if op == "" {
+						add = lookup(call.Common().StaticCallee())
+					}
+					for _, c := range add {
+						if !classes[c] {
+							classes[c] = true
+							changed = true
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, fn := range ssainput.SrcFuncs {
+		if obj, ok := fn.Object().(*types.Func); ok && len(acquires[fn]) > 0 {
+			pass.ExportObjectFact(obj, &acquiresFact{Classes: keys(acquires[fn])})
+		}
+	}
+
+	// Check each function, recording the edges of the lock-order graph.
+	c := &checker{
+		pass:     pass,
+		lookup:   lookup,
+		noReturn: noReturn,
+		edges:    make(map[[2]string]*edge),
+	}
+	for _, fn := range ssainput.SrcFuncs {
+		c.checkFunc(fn)
+	}
+
+	var local []*edge
+	for _, e := range c.edges {
+		local = append(local, e)
+	}
+	sort.Slice(local, func(i, j int) bool { return local[i].pos < local[j].pos })
+	if len(local) > 0 {
+		f := new(orderFact)
+		for _, e := range local {
+			f.Edges = append(f.Edges, *e)
+		}
+		pass.ExportPackageFact(f)
+	}
+
+	c.reportCycles(local)
+	return nil, nil
+}
+
+// A held mutex is one locked along the current path.
+type held struct {
+	name     string    // source form of the mutex, for messages
+	class    string    // class of the mutex, or "" if unknown
+	read     bool      // held by RLock
+	pos      token.Pos // position of the Lock call
+	deferred bool      // a deferred call unlocks it
+}
+
+// A state maps each mutex held on entry to a block, keyed by its
+// instance, to information about it.
+type state map[string]held
+
+func (s state) clone() state {
+	s2 := make(state, len(s))
+	for k, v := range s {
+		s2[k] = v
+	}
+	return s2
+}
+
+// A checker finds lock misuse in the functions of a package.
+type checker struct {
+	pass     *analysis.Pass
+	lookup   func(*ssa.Function) []string
+	noReturn map[token.Pos]bool
+	edges    map[[2]string]*edge // the package's lock-order edges, by class pair
+}
+
+// checkFunc reports double locks and missing unlocks in fn, and
+// records the edges of the lock-order graph implied by fn.
+func (c *checker) checkFunc(fn *ssa.Function) {
+	if fn.Blocks == nil {
+		return
+	}
+
+	// Find the mutexes that fn unlocks anywhere.
+	unlocks := make(map[string]bool)
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if call, ok := instr.(ssa.CallInstruction); ok {
+				if op, recv := lockOp(call.Common()); op == "Unlock" || op == "RUnlock" {
+					key, _, _ := instance(c.pass.Pkg, recv)
+					unlocks[key] = true
+				}
+			}
+		}
+	}
+
+	// Compute the mutexes held on entry to each block: those held
+	// on every path to it. The sets only shrink once computed, so
+	// the iteration terminates.
+	entry := make([]state, len(fn.Blocks))
+	entry[0] = make(state)
+	for changed := true; changed; {
+		changed = false
+		for _, b := range fn.Blocks {
+			if entry[b.Index] == nil {
+				continue
+			}
+			out, ok := c.transfer(fn, b, entry[b.Index].clone(), nil)
+			if !ok {
+				continue // the block never completes
+			}
+			for _, succ := range b.Succs {
+				if entry[succ.Index] == nil {
+					entry[succ.Index] = out.clone()
+					changed = true
+					continue
+				}
+				for k, h := range entry[succ.Index] {
+					if o, ok := out[k]; !ok {
+						delete(entry[succ.Index], k)
+						changed = true
+					} else if h.deferred && !o.deferred {
+						h.deferred = false
+						entry[succ.Index][k] = h
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	// Report problems, now that the states are final.
+	for _, b := range fn.Blocks {
+		if entry[b.Index] != nil {
+			c.transfer(fn, b, entry[b.Index].clone(), unlocks)
+		}
+	}
+}
+
+// transfer updates s to reflect the execution of b, and reports
+// whether b completes normally. If unlocks is non-nil, it reports
+// problems and records edges along the way; unlocks is the set of
+// mutexes that fn unlocks somewhere.
+func (c *checker) transfer(fn *ssa.Function, b *ssa.BasicBlock, s state, unlocks map[string]bool) (state, bool) {
+	report := unlocks != nil
+	for _, instr := range b.Instrs {
+		switch instr := instr.(type) {
+		case *ssa.Call:
+			op, recv := lockOp(instr.Common())
+			switch op {
+			case "Lock", "RLock":
+				key, name, class := instance(c.pass.Pkg, recv)
+				if h, ok := s[key]; ok {
+					if report {
+						msg := fmt.Sprintf("%s is already locked", name)
+						if h.read && op == "RLock" {
+							msg = fmt.Sprintf("%s is already read-locked; recursive read locking may deadlock", name)
+						}
+						c.pass.Report(analysis.Diagnostic{
+							Pos:     instr.Pos(),
+							Message: msg,
+							Related: []analysis.RelatedInformation{{Pos: h.pos, Message: "locked here"}},
+						})
+					}
+					continue
+				}
+				if report && class != "" {
+					for _, h := range s {
+						c.addEdge(h.class, class, instr.Pos(), "")
+					}
+				}
+				s[key] = held{name: name, class: class, read: op == "RLock", pos: instr.Pos()}
+
+			case "Unlock", "RUnlock":
+				key, _, _ := instance(c.pass.Pkg, recv)
+				delete(s, key)
+
+			case "":
+				if report {
+					if callee := instr.Common().StaticCallee(); callee != nil {
+						for _, class := range c.lookup(callee) {
+							for _, h := range s {
+								c.addEdge(h.class, class, instr.Pos(), funcName(callee))
+							}
+						}
+					}
+				}
+				if c.noReturn[instr.Pos()] {
+					return s, false
+				}
+			}
+
+		case *ssa.Defer:
+			if op, recv := lockOp(instr.Common()); op == "Unlock" || op == "RUnlock" {
+				key, _, _ := instance(c.pass.Pkg, recv)
+				if h, ok := s[key]; ok {
+					h.deferred = true
+					s[key] = h
+				}
+			}
+
+		case *ssa.Return:
+			if !report {
+				break
+			}
+			pos := instr.Pos()
+			if !pos.IsValid() {
+				pos = endOf(fn)
+			}
+			var missing []string
+			for key, h := range s {
+				if !h.deferred && unlocks[key] {
+					missing = append(missing, key)
+				}
+			}
+			sort.Slice(missing, func(i, j int) bool { return s[missing[i]].pos < s[missing[j]].pos })
+			for _, key := range missing {
+				h := s[key]
+				c.pass.Report(analysis.Diagnostic{
+					Pos:     pos,
+					Message: fmt.Sprintf("missing unlock of %s before return", h.name),
+					Related: []analysis.RelatedInformation{{Pos: h.pos, Message: "locked here"}},
+				})
+			}
+
+		case *ssa.Panic:
+			return s, false
+		}
+	}
+	return s, true
+}
+
+// addEdge records the lock-order edge from -> to.
+func (c *checker) addEdge(from, to string, pos token.Pos, via string) {
+	if from == "" || from == to {
+		return
+	}
+	k := [2]string{from, to}
+	if e, ok := c.edges[k]; ok && e.pos <= pos {
+		return
+	}
+	posn := c.pass.Fset.Position(pos)
+	c.edges[k] = &edge{
+		From: from,
+		To:   to,
+		Posn: fmt.Sprintf("%s:%d", filepath.Base(posn.Filename), posn.Line),
+		Via:  via,
+		pos:  pos,
+	}
+}
+
+// reportCycles reports each cycle in the lock-order graph of the
+// package and its dependencies that includes one of the package's
+// own edges, at the first such edge.
+func (c *checker) reportCycles(local []*edge) {
+	graph := make(map[string][]*edge)
+	for _, f := range c.pass.AllPackageFacts() {
+		if fact, ok := f.Fact.(*orderFact); ok && f.Package != c.pass.Pkg {
+			for i := range fact.Edges {
+				e := &fact.Edges[i]
+				graph[e.From] = append(graph[e.From], e)
+			}
+		}
+	}
+	isLocal := make(map[*edge]bool)
+	for _, e := range local {
+		graph[e.From] = append(graph[e.From], e)
+		isLocal[e] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range local {
+		path := shortestPath(graph, e.To, e.From)
+		if path == nil {
+			continue
+		}
+		cycle := append([]*edge{e}, path...)
+
+		var key []string
+		for _, e := range cycle {
+			key = append(key, e.From+" -> "+e.To)
+		}
+		sort.Strings(key)
+		if seen[strings.Join(key, ", ")] {
+			continue
+		}
+		seen[strings.Join(key, ", ")] = true
+
+		classes := []string{className(e.From)}
+		var elsewhere []string
+		var related []analysis.RelatedInformation
+		for _, e := range cycle {
+			classes = append(classes, className(e.To))
+			if isLocal[e] {
+				related = append(related, analysis.RelatedInformation{Pos: e.pos, Message: e.String()})
+			} else {
+				elsewhere = append(elsewhere, fmt.Sprintf("%s at %s", e, e.Posn))
+			}
+		}
+		msg := "lock ordering cycle may deadlock: " + strings.Join(classes, " -> ")
+		if len(elsewhere) > 0 {
+			msg += "; " + strings.Join(elsewhere, "; ")
+		}
+		c.pass.Report(analysis.Diagnostic{
+			Pos:     e.pos,
+			Message: msg,
+			Related: related,
+		})
+	}
+}
+
+// shortestPath returns the edges of a shortest path from one class
+// to another in the graph, or nil if there is none.
+func shortestPath(graph map[string][]*edge, from, to string) []*edge {
+	prev := map[string]*edge{from: nil}
+	queue := []string{from}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n == to {
+			var path []*edge
+			for e := prev[n]; e != nil; e = prev[e.From] {
+				path = append([]*edge{e}, path...)
+			}
+			return path
+		}
+		for _, e := range graph[n] {
+			if _, ok := prev[e.To]; !ok {
+				prev[e.To] = e
+				queue = append(queue, e.To)
+			}
+		}
+	}
+	return nil
+}
+
+// lockOp returns the name of the sync.Mutex or sync.RWMutex method
+// called by call, and the mutex it is called on, or "" if call is
+// not such a call.
+func lockOp(call *ssa.CallCommon) (string, ssa.Value) {
+	callee := call.StaticCallee()
+	if callee == nil || len(call.Args) == 0 {
+		return "", nil
+	}
+	fn, ok := callee.Object().(*types.Func)
+	if !ok {
+		return "", nil
+	}
+	switch fn.FullName() {
+	case "(*sync.Mutex).Lock", "(*sync.RWMutex).Lock":
+		return "Lock", call.Args[0]
+	case "(*sync.Mutex).Unlock", "(*sync.RWMutex).Unlock":
+		return "Unlock", call.Args[0]
+	case "(*sync.RWMutex).RLock":
+		return "RLock", call.Args[0]
+	case "(*sync.RWMutex).RUnlock":
+		return "RUnlock", call.Args[0]
+	}
+	return "", nil
+}
+
+// instance describes the mutex that v points to. It returns a key
+// identifying the mutex within the function, its source form, and
+// its class, if it has one.
+func instance(pkg *types.Package, v ssa.Value) (key, name, class string) {
+	switch v := v.(type) {
+	case *ssa.FieldAddr:
+		key, name, _ = instance(pkg, v.X)
+		ptr, ok := v.X.Type().Underlying().(*types.Pointer)
+		if !ok {
+			break
+		}
+		st, ok := ptr.Elem().Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		field := st.Field(v.Field).Name()
+		key, name = key+"."+field, name+"."+field
+		if named, ok := types.Unalias(ptr.Elem()).(*types.Named); ok && named.Obj().Pkg() != nil {
+			obj := named.Origin().Obj()
+			class = obj.Pkg().Path() + "." + obj.Name() + "." + field
+		}
+		return key, name, class
+
+	case *ssa.Global:
+		class = v.Pkg.Pkg.Path() + "." + v.Name()
+		name = v.Name()
+		if v.Pkg.Pkg != pkg {
+			name = v.Pkg.Pkg.Name() + "." + name
+		}
+		return class, name, class
+
+	case *ssa.UnOp:
+		if v.Op == token.MUL {
+			key, name, class = instance(pkg, v.X)
+			return "*" + key, name, class
+		}
+
+	case *ssa.Alloc:
+		if v.Comment != "" {
+			return v.Name(), v.Comment, ""
+		}
+	}
+	return v.Name(), v.Name(), ""
+}
+
+// endOf returns the position of the closing brace of fn's body.
+func endOf(fn *ssa.Function) token.Pos {
+	switch syntax := fn.Syntax().(type) {
+	case *ast.FuncDecl:
+		return syntax.Body.Rbrace
+	case *ast.FuncLit:
+		return syntax.Body.Rbrace
+	}
+	return fn.Pos()
+}
+
+func funcName(fn *ssa.Function) string {
+	if obj, ok := fn.Object().(*types.Func); ok {
+		qual := func(pkg *types.Package) string { return pkg.Name() }
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			if ptr, ok := recv.Type().(*types.Pointer); ok {
+				return fmt.Sprintf("(*%s).%s", types.TypeString(ptr.Elem(), qual), obj.Name())
+			}
+			return types.TypeString(recv.Type(), qual) + "." + obj.Name()
+		}
+		return obj.Pkg().Name() + "." + obj.Name()
+	}
+	return fn.Name()
+}
+
+func keys(m map[string]bool) []string {
+	var s []string
+	for k := range m {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,128 @@
+package a // want package:"a.mu1 -> a.mu2, a.mu2 -> a.mu1, a.Outer -> a.Inner"
+
+import "sync"
+
+type Account struct {
+	mu      sync.Mutex
+	balance int
+}
+
+func (a *Account) Double() { // want Double:"acquires a.Account.mu"
+	a.mu.Lock()
+	a.mu.Lock() // want `a.mu is already locked`
+	a.mu.Unlock()
+}
+
+func (a *Account) Withdraw(n int) bool { // want Withdraw:"acquires a.Account.mu"
+	a.mu.Lock()
+	if a.balance < n {
+		return false // want `missing unlock of a.mu before return`
+	}
+	a.balance -= n
+	a.mu.Unlock()
+	return true
+}
+
+func (a *Account) Deposit(n int) { // want Deposit:"acquires a.Account.mu"
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n < 0 {
+		return
+	}
+	a.balance += n
+}
+
+func (a *Account) Reset() { // want Reset:"acquires a.Account.mu"
+	a.mu.Lock()
+	if a.balance >= 0 {
+		a.mu.Unlock()
+		return
+	}
+	fail() // ok: never returns
+}
+
+func fail() {
+	panic("negative balance")
+}
+
+// lock is a helper whose callers unlock the mutex.
+func (a *Account) lock() { // want lock:"acquires a.Account.mu"
+	a.mu.Lock()
+}
+
+func (a *Account) Loop(n int) { // want Loop:"acquires a.Account.mu"
+	for i := 0; i < n; i++ {
+		a.mu.Lock()
+		a.balance++
+		a.mu.Unlock()
+	}
+}
+
+// Cache embeds an RWMutex.
+type Cache struct {
+	sync.RWMutex
+	m map[string]int
+}
+
+func (c *Cache) Get(k string) int { // want Get:"acquires a.Cache.RWMutex"
+	c.RLock()
+	defer c.RUnlock()
+	return c.m[k]
+}
+
+func (c *Cache) Recursive(k string) int { // want Recursive:"acquires a.Cache.RWMutex"
+	c.RLock()
+	defer c.RUnlock()
+	c.RLock() // want `c.RWMutex is already read-locked; recursive read locking may deadlock`
+	defer c.RUnlock()
+	return c.m[k]
+}
+
+func (c *Cache) Upgrade(k string) { // want Upgrade:"acquires a.Cache.RWMutex"
+	c.RLock()
+	if _, ok := c.m[k]; !ok {
+		c.Lock() // want `c.RWMutex is already locked`
+		c.m[k] = 0
+		c.Unlock()
+	}
+	c.RUnlock()
+}
+
+var (
+	mu1 sync.Mutex
+	mu2 sync.Mutex
+)
+
+func f() { // want f:"acquires a.mu1, a.mu2"
+	mu1.Lock()
+	mu2.Lock() // want `lock ordering cycle may deadlock: a.mu1 -> a.mu2 -> a.mu1` -> `a.mu2 locked while holding a.mu1` -> `a.mu1 locked by a.lockMu1 while holding a.mu2`
+	mu2.Unlock()
+	mu1.Unlock()
+}
+
+func g() { // want g:"acquires a.mu1, a.mu2"
+	mu2.Lock()
+	defer mu2.Unlock()
+	lockMu1()
+}
+
+func lockMu1() { // want lockMu1:"acquires a.mu1"
+	mu1.Lock()
+	mu1.Unlock()
+}
+
+// Outer is always locked before Inner.
+var Outer, Inner sync.Mutex
+
+func Nested() { // want Nested:"acquires a.Inner, a.Outer"
+	Outer.Lock()
+	defer Outer.Unlock()
+	Inner.Lock()
+	defer Inner.Unlock()
+}
+
+func Local() {
+	var mu sync.Mutex
+	mu.Lock()
+	mu.Lock() // want `mu is already locked`
+}
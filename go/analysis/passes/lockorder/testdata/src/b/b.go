@@ -0,0 +1,9 @@
+package b // want package:"a.Inner -> a.Outer"
+
+import "a"
+
+func Reversed() { // want Reversed:"acquires a.Inner, a.Outer"
+	a.Inner.Lock()
+	defer a.Inner.Unlock()
+	a.Nested() // want `lock ordering cycle may deadlock: a.Inner -> a.Outer -> a.Inner; a.Inner locked while holding a.Outer at a.go:\d+`
+}
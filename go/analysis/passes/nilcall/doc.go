@@ -0,0 +1,42 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package nilcall defines an Analyzer that reports nil pointer
+// dereferences that arise across function calls.
+//
+// # Analyzer nilcall
+//
+// nilcall: check for nil dereferences across function calls
+//
+// The nilcall checker complements nilness, which reasons only within
+// a single function. It summarizes each function by two kinds of fact:
+// which pointer results it may return as nil along with a nil error,
+// and which pointer parameters it dereferences unconditionally, that
+// is, on every path that returns normally. The facts flow across
+// packages, and through calls that pass results and parameters along
+// unchanged.
+//
+// The checker uses the facts at call sites. It reports a dereference
+// of a result that may be nil, on paths where the error was found to
+// be nil and the result was not checked:
+//
+//	func load() (*T, error) {
+//		if missing {
+//			return nil, nil
+//		}
+//		...
+//	}
+//
+//	t, err := load()
+//	if err != nil {
+//		return err
+//	}
+//	print(t.x) // nil dereference: load may return a nil result with a nil error
+//
+// It also reports calls that pass nil, or such a result, to a
+// parameter that the callee dereferences unconditionally.
+//
+// Each diagnostic lists, as related information, the chain of calls
+// that leads from the call site to the nil return or dereference.
+package nilcall
@@ -0,0 +1,570 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nilcall
+
+import (
+	_ "embed"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/buildssa"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/internal/analysisutil"
+	"github.com/TBD54566975/golang-tools/go/ssa"
+	"github.com/TBD54566975/golang-tools/go/types/objectpath"
+)
+
+//go:embed doc.go
+var doc string
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "nilcall",
+	Doc:       analysisutil.MustExtractDoc(doc, "nilcall"),
+	URL:       "https://pkg.go.dev/github.com/TBD54566975/golang-tools/go/analysis/passes/nilcall",
+	Requires:  []*analysis.Analyzer{buildssa.Analyzer},
+	FactTypes: []analysis.Fact{new(nilFact)},
+	Run:       run,
+}
+
+// A nilFact summarizes the nilness behavior of a function.
+type nilFact struct {
+	Results []chain // pointer results that may be nil when the error result is nil
+	Params  []chain // pointer parameters dereferenced on every normal return
+}
+
+func (*nilFact) AFact() {}
+
+func (f *nilFact) String() string {
+	var parts []string
+	for _, c := range f.Results {
+		parts = append(parts, fmt.Sprintf("nil result %d", c.Index))
+	}
+	for _, c := range f.Params {
+		parts = append(parts, "derefs "+c.Name)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// result returns the chain for result i, or nil.
+func (f *nilFact) result(i int) *chain {
+	if f != nil {
+		for j := range f.Results {
+			if f.Results[j].Index == i {
+				return &f.Results[j]
+			}
+		}
+	}
+	return nil
+}
+
+// param returns the chain for parameter i, or nil.
+func (f *nilFact) param(i int) *chain {
+	if f != nil {
+		for j := range f.Params {
+			if f.Params[j].Index == i {
+				return &f.Params[j]
+			}
+		}
+	}
+	return nil
+}
+
+// A chain explains why a result may be nil or why a parameter is
+// dereferenced.
+type chain struct {
+	Index int    // index of the result, or of the parameter (counting any receiver)
+	Name  string // name of the parameter
+	Steps []step // calls leading to the nil return or dereference, outermost first
+}
+
+// A step is one function in a chain.
+type step struct {
+	PkgPath string          // package of the function
+	Object  objectpath.Path // path of the function within its package; empty if unnameable
+	Message string
+}
+
+// maxSteps bounds the length of chains, and thus the size of facts.
+const maxSteps = 8
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ssainput := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+
+	// Summarize the package's functions, iterating to a fixed
+	// point as they may call each other in any order.
+	facts := make(map[*types.Func]*nilFact)
+	lookup := func(callee *ssa.Function) *nilFact {
+		if callee == nil {
+			return nil
+		}
+		if orig := callee.Origin(); orig != nil {
+			callee = orig
+		}
+		obj, ok := callee.Object().(*types.Func)
+		if !ok {
+			return nil
+		}
+		if obj.Pkg() == pass.Pkg {
+			return facts[obj]
+		}
+		f := new(nilFact)
+		if pass.ImportObjectFact(obj, f) {
+			return f
+		}
+		return nil
+	}
+	var funcs []*ssa.Function
+	for _, fn := range ssainput.SrcFuncs {
+		if obj, ok := fn.Object().(*types.Func); ok && fn.Blocks != nil {
+			funcs = append(funcs, fn)
+			facts[obj] = new(nilFact)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range funcs {
+			if summarize(fn, facts[fn.Object().(*types.Func)], lookup) {
+				changed = true
+			}
+		}
+	}
+	for _, fn := range funcs {
+		obj := fn.Object().(*types.Func)
+		if f := facts[obj]; len(f.Results)+len(f.Params) > 0 {
+			sort.Slice(f.Results, func(i, j int) bool { return f.Results[i].Index < f.Results[j].Index })
+			sort.Slice(f.Params, func(i, j int) bool { return f.Params[i].Index < f.Params[j].Index })
+			pass.ExportObjectFact(obj, f)
+		}
+	}
+
+	// Check the calls of all functions, including function literals.
+	c := &checker{pass: pass, lookup: lookup}
+	for _, fn := range ssainput.SrcFuncs {
+		c.checkFunc(fn)
+	}
+	return nil, nil
+}
+
+// summarize adds to f the facts about fn that follow from the
+// current facts about its callees, and reports whether it added any.
+func summarize(fn *ssa.Function, f *nilFact, lookup func(*ssa.Function) *nilFact) bool {
+	obj := fn.Object().(*types.Func)
+	name := funcName(obj)
+	path, _ := objectpath.For(obj)
+	newStep := func(format string, args ...interface{}) step {
+		return step{PkgPath: obj.Pkg().Path(), Object: path, Message: fmt.Sprintf(format, args...)}
+	}
+	changed := false
+
+	var returns []*ssa.BasicBlock
+	for _, b := range fn.Blocks {
+		if len(b.Instrs) > 0 {
+			if _, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return); ok {
+				returns = append(returns, b)
+			}
+		}
+	}
+
+	// Results that may be nil along with a nil error.
+	results := fn.Signature.Results()
+	if n := results.Len(); n > 1 && isError(results.At(n-1).Type()) {
+		for _, b := range returns {
+			ret := b.Instrs[len(b.Instrs)-1].(*ssa.Return)
+			for i := 0; i < n-1; i++ {
+				if f.result(i) != nil || !isPointer(results.At(i).Type()) {
+					continue
+				}
+				v, err := ret.Results[i], ret.Results[n-1]
+				if isNilConst(err) && mayBeNil(v, make(map[*ssa.Phi]bool)) {
+					f.Results = append(f.Results, chain{Index: i, Steps: []step{
+						newStep("%s returns nil with a nil error", name),
+					}})
+					changed = true
+				} else if call, c := nilResult(v, lookup); c != nil && (isNilConst(err) || isExtract(err, call, n-1)) {
+					callee := call.Call.StaticCallee().Object().(*types.Func)
+					f.Results = append(f.Results, chain{Index: i, Steps: appendSteps(
+						newStep("%s returns the result of %s", name, funcName(callee)), c.Steps)})
+					changed = true
+				}
+			}
+		}
+	}
+
+	// Parameters dereferenced on every path to a return.
+	// (A function that never returns normally has no such parameters.)
+	unconditional := func(b *ssa.BasicBlock) bool {
+		for _, ret := range returns {
+			if !b.Dominates(ret) {
+				return false
+			}
+		}
+		return len(returns) > 0
+	}
+	for i, p := range fn.Params {
+		if f.param(i) != nil || !isPointer(p.Type()) {
+			continue
+		}
+		for _, instr := range *p.Referrers() {
+			if !unconditional(instr.Block()) {
+				continue
+			}
+			if derefOperand(instr) == p {
+				f.Params = append(f.Params, chain{Index: i, Name: p.Name(), Steps: []step{
+					newStep("%s dereferences %s", name, p.Name()),
+				}})
+				changed = true
+				break
+			}
+			if call, ok := instr.(*ssa.Call); ok {
+				if c := calleeParam(call.Common(), p, lookup); c != nil {
+					callee := call.Call.StaticCallee().Object().(*types.Func)
+					f.Params = append(f.Params, chain{Index: i, Name: p.Name(), Steps: appendSteps(
+						newStep("%s passes %s to %s", name, p.Name(), funcName(callee)), c.Steps)})
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return changed
+}
+
+// nilResult reports whether v is a pointer result of a call that may
+// be nil when the call's error is nil; if so, it returns the call and
+// the chain for the result.
+func nilResult(v ssa.Value, lookup func(*ssa.Function) *nilFact) (*ssa.Call, *chain) {
+	if e, ok := v.(*ssa.Extract); ok {
+		if call, ok := e.Tuple.(*ssa.Call); ok {
+			if c := lookup(call.Call.StaticCallee()).result(e.Index); c != nil {
+				return call, c
+			}
+		}
+	}
+	return nil, nil
+}
+
+// calleeParam returns the chain of the first parameter of the static
+// callee of call to which v is passed and which the callee
+// dereferences unconditionally, or nil.
+func calleeParam(call *ssa.CallCommon, v ssa.Value, lookup func(*ssa.Function) *nilFact) *chain {
+	if f := lookup(call.StaticCallee()); f != nil {
+		for i, arg := range call.Args {
+			if arg == v {
+				if c := f.param(i); c != nil {
+					return c
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// A checker reports the nil dereferences implied by the facts of
+// callees within one package.
+type checker struct {
+	pass     *analysis.Pass
+	lookup   func(*ssa.Function) *nilFact
+	packages map[string]*types.Package // packages visible to pass.Pkg, by path; built lazily
+}
+
+func (c *checker) checkFunc(fn *ssa.Function) {
+	reported := make(map[ssa.Value]bool)
+
+	// visit visits reachable blocks of the CFG in dominance order,
+	// maintaining a stack of dominating nilness facts, as nilness does.
+	seen := make([]bool, len(fn.Blocks))
+	var visit func(b *ssa.BasicBlock, stack []fact)
+	visit = func(b *ssa.BasicBlock, stack []fact) {
+		if seen[b.Index] {
+			return
+		}
+		seen[b.Index] = true
+
+		for _, instr := range b.Instrs {
+			// Dereference of a result that may be nil.
+			if v := derefOperand(instr); v != nil && !reported[v] {
+				if call, r := c.nilResultOf(stack, v); r != nil {
+					reported[v] = true
+					callee := call.Call.StaticCallee().Object().(*types.Func)
+					c.report(instr.Pos(), r.Steps,
+						"nil dereference: %s may return a nil result with a nil error", funcName(callee))
+				}
+			}
+
+			// Nil argument to a parameter that is dereferenced.
+			call, ok := instr.(ssa.CallInstruction)
+			if !ok {
+				continue
+			}
+			common := call.Common()
+			f := c.lookup(common.StaticCallee())
+			if f == nil {
+				continue
+			}
+			callee := common.StaticCallee().Object().(*types.Func)
+			for _, p := range f.Params {
+				if p.Index >= len(common.Args) {
+					continue // can't happen
+				}
+				arg := common.Args[p.Index]
+				if nilnessOf(stack, arg) == isnil {
+					c.report(call.Pos(), p.Steps,
+						"nil passed as %s to %s, which dereferences it", p.Name, funcName(callee))
+				} else if rcall, r := c.nilResultOf(stack, arg); r != nil && !reported[arg] {
+					reported[arg] = true
+					rcallee := rcall.Call.StaticCallee().Object().(*types.Func)
+					steps := append(append([]step(nil), r.Steps...), p.Steps...)
+					c.report(call.Pos(), steps,
+						"nil dereference: %s may return a nil result with a nil error, and %s dereferences %s",
+						funcName(rcallee), funcName(callee), p.Name)
+				}
+			}
+		}
+
+		// Successors of "x == nil" and "x != nil" conditions
+		// learn a fact about x.
+		if binop, tsucc, fsucc := eq(b); binop != nil {
+			var newFact *fact
+			if nilnessOf(stack, binop.Y) == isnil {
+				newFact = &fact{binop.X, isnil}
+			} else if nilnessOf(stack, binop.X) == isnil {
+				newFact = &fact{binop.Y, isnil}
+			}
+			if newFact != nil {
+				for _, d := range b.Dominees() {
+					s := stack
+					if len(d.Preds) == 1 {
+						if d == tsucc {
+							s = append(s, *newFact)
+						} else if d == fsucc {
+							s = append(s, newFact.negate())
+						}
+					}
+					visit(d, s)
+				}
+				return
+			}
+		}
+		for _, d := range b.Dominees() {
+			visit(d, stack)
+		}
+	}
+	if fn.Blocks != nil {
+		visit(fn.Blocks[0], make([]fact, 0, 20))
+	}
+}
+
+// nilResultOf reports whether v is a result that may be nil, given
+// the stack of facts: it must be a result of a call whose error result
+// is known to be nil, and v itself must not be known to be non-nil.
+func (c *checker) nilResultOf(stack []fact, v ssa.Value) (*ssa.Call, *chain) {
+	call, r := nilResult(v, c.lookup)
+	if r == nil || nilnessOf(stack, v) == isnonnil {
+		return nil, nil
+	}
+	errIndex := call.Call.Signature().Results().Len() - 1
+	for _, instr := range *call.Referrers() {
+		if isExtract(instr, call, errIndex) && nilnessOf(stack, instr.(ssa.Value)) == isnil {
+			return call, r
+		}
+	}
+	return nil, nil
+}
+
+// report reports a diagnostic at pos, with the steps of a chain as
+// related information.
+func (c *checker) report(pos token.Pos, steps []step, format string, args ...interface{}) {
+	if !pos.IsValid() {
+		return // no syntax
+	}
+	var related []analysis.RelatedInformation
+	for _, s := range steps {
+		related = append(related, analysis.RelatedInformation{
+			Pos:     c.stepPos(s, pos),
+			Message: s.Message,
+		})
+	}
+	c.pass.Report(analysis.Diagnostic{
+		Pos:     pos,
+		Message: fmt.Sprintf(format, args...),
+		Related: related,
+	})
+}
+
+// stepPos returns the position of the function of step s, or
+// fallback if the function is not visible to the current package.
+func (c *checker) stepPos(s step, fallback token.Pos) token.Pos {
+	if c.packages == nil {
+		c.packages = make(map[string]*types.Package)
+		var add func(pkg *types.Package)
+		add = func(pkg *types.Package) {
+			if c.packages[pkg.Path()] == nil {
+				c.packages[pkg.Path()] = pkg
+				for _, imp := range pkg.Imports() {
+					add(imp)
+				}
+			}
+		}
+		add(c.pass.Pkg)
+	}
+	if pkg := c.packages[s.PkgPath]; pkg != nil && s.Object != "" {
+		if obj, err := objectpath.Object(pkg, s.Object); err == nil && obj.Pos().IsValid() {
+			return obj.Pos()
+		}
+	}
+	return fallback
+}
+
+// appendSteps returns a chain that starts with s and continues with
+// rest, truncated to maxSteps.
+func appendSteps(s step, rest []step) []step {
+	steps := append([]step{s}, rest...)
+	if len(steps) > maxSteps {
+		steps = steps[:maxSteps]
+	}
+	return steps
+}
+
+// derefOperand returns the pointer that instr dereferences, or nil.
+func derefOperand(instr ssa.Instruction) ssa.Value {
+	switch instr := instr.(type) {
+	case *ssa.FieldAddr:
+		return instr.X
+	case *ssa.IndexAddr:
+		if isPointer(instr.X.Type()) { // *array
+			return instr.X
+		}
+	case *ssa.Slice:
+		if isPointer(instr.X.Type()) { // *array
+			return instr.X
+		}
+	case *ssa.Store:
+		return instr.Addr
+	case *ssa.UnOp:
+		if instr.Op == token.MUL {
+			return instr.X
+		}
+	}
+	return nil
+}
+
+// mayBeNil reports whether v is nil, or a φ-node of which some
+// operand may be nil.
+func mayBeNil(v ssa.Value, seen map[*ssa.Phi]bool) bool {
+	switch v := v.(type) {
+	case *ssa.Const:
+		return v.IsNil()
+	case *ssa.Phi:
+		if !seen[v] {
+			seen[v] = true
+			for _, edge := range v.Edges {
+				if mayBeNil(edge, seen) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isExtract reports whether v extracts result i of call.
+func isExtract(v interface{}, call *ssa.Call, i int) bool {
+	e, ok := v.(*ssa.Extract)
+	return ok && e.Tuple == call && e.Index == i
+}
+
+func isNilConst(v ssa.Value) bool {
+	c, ok := v.(*ssa.Const)
+	return ok && c.IsNil()
+}
+
+func isPointer(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Pointer)
+	return ok
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func isError(t types.Type) bool {
+	return types.Identical(t, errorType)
+}
+
+// funcName returns the name of fn qualified by its package name,
+// such as "a.F" or "(*a.T).M".
+func funcName(fn *types.Func) string {
+	qual := func(pkg *types.Package) string { return pkg.Name() }
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		if ptr, ok := recv.Type().(*types.Pointer); ok {
+			return fmt.Sprintf("(*%s).%s", types.TypeString(ptr.Elem(), qual), fn.Name())
+		}
+		return types.TypeString(recv.Type(), qual) + "." + fn.Name()
+	}
+	return fn.Pkg().Name() + "." + fn.Name()
+}
+
+// A fact records that a block is dominated
+// by the condition v == nil or v != nil.
+type fact struct {
+	value   ssa.Value
+	nilness nilness
+}
+
+func (f fact) negate() fact { return fact{f.value, -f.nilness} }
+
+type nilness int
+
+const (
+	isnonnil         = -1
+	unknown  nilness = 0
+	isnil            = 1
+)
+
+// nilnessOf reports whether v is definitely nil, definitely not nil,
+// or unknown given the dominating stack of facts. It is a simplified
+// form of the function of the same name in the nilness analyzer.
+func nilnessOf(stack []fact, v ssa.Value) nilness {
+	switch v := v.(type) {
+	case *ssa.Alloc,
+		*ssa.FieldAddr,
+		*ssa.Function,
+		*ssa.Global,
+		*ssa.IndexAddr,
+		*ssa.MakeChan,
+		*ssa.MakeClosure,
+		*ssa.MakeMap,
+		*ssa.MakeSlice:
+		return isnonnil
+	case *ssa.Const:
+		if v.IsNil() {
+			return isnil
+		}
+		return unknown
+	}
+	for _, f := range stack {
+		if f.value == v {
+			return f.nilness
+		}
+	}
+	return unknown
+}
+
+// If b ends with an equality comparison, eq returns the operation and
+// its true (equal) and false (not equal) successors.
+func eq(b *ssa.BasicBlock) (op *ssa.BinOp, tsucc, fsucc *ssa.BasicBlock) {
+	if If, ok := b.Instrs[len(b.Instrs)-1].(*ssa.If); ok {
+		if binop, ok := If.Cond.(*ssa.BinOp); ok {
+			switch binop.Op {
+			case token.EQL:
+				return binop, b.Succs[0], b.Succs[1]
+			case token.NEQ:
+				return binop, b.Succs[1], b.Succs[0]
+			}
+		}
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,95 @@
+package a
+
+import "errors"
+
+type T struct{ X int }
+
+func Get(ok bool) (*T, error) { // want Get:"nil result 0"
+	if ok {
+		return &T{}, nil
+	}
+	return nil, nil
+}
+
+func Wrap() (*T, error) { // want Wrap:"nil result 0"
+	return Get(false)
+}
+
+func Phi(ok bool) (*T, error) { // want Phi:"nil result 0"
+	var t *T
+	if ok {
+		t = new(T)
+	}
+	return t, nil
+}
+
+func Safe() (*T, error) { return new(T), nil }
+
+func Fail() (*T, error) { return nil, errors.New("fail") }
+
+func Use(t *T) int { // want Use:"derefs t"
+	return t.X
+}
+
+func UseVia(t *T) int { // want UseVia:"derefs t"
+	return Use(t)
+}
+
+func Maybe(t *T) int {
+	if t == nil {
+		return 0
+	}
+	return t.X
+}
+
+func (t *T) Method() int { // want Method:"derefs t"
+	return t.X
+}
+
+func _() {
+	t, err := Get(true)
+	if err != nil {
+		return
+	}
+	print(t.X) // want "nil dereference: a.Get may return a nil result with a nil error"
+}
+
+func _() {
+	t, err := Phi(true)
+	if err != nil {
+		return
+	}
+	if t == nil {
+		return
+	}
+	print(t.X) // ok: checked
+}
+
+func _() {
+	t, err := Get(true)
+	if err == nil {
+		print(t.X) // want "nil dereference: a.Get may return a nil result with a nil error"
+	}
+	t, _ = Get(true)
+	print(t.X) // ok: error not checked
+}
+
+func _() {
+	t, err := Safe()
+	if err != nil {
+		return
+	}
+	print(t.X) // ok
+	t, err = Fail()
+	if err != nil {
+		return
+	}
+	print(t.X) // ok
+}
+
+func _() {
+	Use(nil) // want "nil passed as t to a.Use, which dereferences it"
+	Maybe(nil)
+	var t *T
+	t.Method() // want `nil passed as t to \(\*a.T\).Method, which dereferences it`
+}
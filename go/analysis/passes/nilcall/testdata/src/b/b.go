@@ -0,0 +1,38 @@
+package b
+
+import "a"
+
+// Deref dereferences its parameter through a chain of calls.
+func Deref(t *a.T) int { // want Deref:"derefs t"
+	return a.UseVia(t)
+}
+
+func _() {
+	t, err := a.Wrap()
+	if err != nil {
+		return
+	}
+	print(t.X) // want "nil dereference: a.Wrap may return a nil result with a nil error" -> "a.Wrap returns the result of a.Get" -> "a.Get returns nil with a nil error"
+}
+
+func _() {
+	Deref(nil) // want "nil passed as t to b.Deref, which dereferences it" -> "b.Deref passes t to a.UseVia" -> "a.UseVia passes t to a.Use" -> "a.Use dereferences t"
+}
+
+func _() {
+	t, err := a.Get(false)
+	if err != nil {
+		return
+	}
+	a.Use(t) // want "nil dereference: a.Get may return a nil result with a nil error, and a.Use dereferences t"
+}
+
+func _() {
+	t, err := a.Get(false)
+	if err != nil {
+		return
+	}
+	if t != nil {
+		print(t.X) // ok
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package taint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A Config specifies the sources, sinks, and sanitizers of untrusted
+// data. Functions and methods are named as by types.Func.FullName,
+// for example "net/url.QueryEscape" or "(*net/http.Request).FormValue";
+// struct fields and types are named by their package path and name,
+// for example "net/http.Request.URL" or "html/template.HTML".
+//
+// A Config may be written as JSON; see the -config flag.
+type Config struct {
+	// Sources are the functions whose results are untrusted,
+	// and the struct fields whose values are untrusted.
+	Sources []string `json:"sources,omitempty"`
+
+	// Sinks are the places that untrusted data must not reach.
+	Sinks []Sink `json:"sinks,omitempty"`
+
+	// Sanitizers are the functions whose results are trusted
+	// even if their arguments are not.
+	Sanitizers []string `json:"sanitizers,omitempty"`
+}
+
+// A Sink is a function whose parameters, or a type whose
+// conversions, must not receive untrusted data.
+type Sink struct {
+	Func string `json:"func,omitempty"` // function or method
+	Args []int  `json:"args,omitempty"` // indices of Func's sensitive parameters, excluding any receiver; all if empty
+	Type string `json:"type,omitempty"` // named type to which untrusted data must not be converted
+}
+
+// Default is the configuration for the standard library. It treats
+// the contents of HTTP requests as untrusted, and reports them
+// reaching SQL queries, commands, and trusted HTML template content.
+var Default = &Config{
+	Sources: []string{
+		"(*net/http.Request).Cookie",
+		"(*net/http.Request).Cookies",
+		"(*net/http.Request).FormFile",
+		"(*net/http.Request).FormValue",
+		"(*net/http.Request).PathValue",
+		"(*net/http.Request).PostFormValue",
+		"(*net/http.Request).Referer",
+		"(*net/http.Request).UserAgent",
+		"net/http.Request.Body",
+		"net/http.Request.Form",
+		"net/http.Request.Header",
+		"net/http.Request.Host",
+		"net/http.Request.MultipartForm",
+		"net/http.Request.PostForm",
+		"net/http.Request.RequestURI",
+		"net/http.Request.Trailer",
+		"net/http.Request.URL",
+	},
+	Sinks: []Sink{
+		{Func: "(*database/sql.Conn).ExecContext", Args: []int{1}},
+		{Func: "(*database/sql.Conn).PrepareContext", Args: []int{1}},
+		{Func: "(*database/sql.Conn).QueryContext", Args: []int{1}},
+		{Func: "(*database/sql.Conn).QueryRowContext", Args: []int{1}},
+		{Func: "(*database/sql.DB).Exec", Args: []int{0}},
+		{Func: "(*database/sql.DB).ExecContext", Args: []int{1}},
+		{Func: "(*database/sql.DB).Prepare", Args: []int{0}},
+		{Func: "(*database/sql.DB).PrepareContext", Args: []int{1}},
+		{Func: "(*database/sql.DB).Query", Args: []int{0}},
+		{Func: "(*database/sql.DB).QueryContext", Args: []int{1}},
+		{Func: "(*database/sql.DB).QueryRow", Args: []int{0}},
+		{Func: "(*database/sql.DB).QueryRowContext", Args: []int{1}},
+		{Func: "(*database/sql.Tx).Exec", Args: []int{0}},
+		{Func: "(*database/sql.Tx).ExecContext", Args: []int{1}},
+		{Func: "(*database/sql.Tx).Prepare", Args: []int{0}},
+		{Func: "(*database/sql.Tx).PrepareContext", Args: []int{1}},
+		{Func: "(*database/sql.Tx).Query", Args: []int{0}},
+		{Func: "(*database/sql.Tx).QueryContext", Args: []int{1}},
+		{Func: "(*database/sql.Tx).QueryRow", Args: []int{0}},
+		{Func: "(*database/sql.Tx).QueryRowContext", Args: []int{1}},
+		{Func: "os/exec.Command"},
+		{Func: "os/exec.CommandContext", Args: []int{1, 2}},
+		{Type: "html/template.CSS"},
+		{Type: "html/template.HTML"},
+		{Type: "html/template.HTMLAttr"},
+		{Type: "html/template.JS"},
+		{Type: "html/template.JSStr"},
+		{Type: "html/template.Srcset"},
+		{Type: "html/template.URL"},
+	},
+	Sanitizers: []string{
+		"html.EscapeString",
+		"html/template.HTMLEscapeString",
+		"html/template.JSEscapeString",
+		"html/template.URLQueryEscaper",
+		"net/url.PathEscape",
+		"net/url.QueryEscape",
+		"strconv.Atoi",
+		"strconv.ParseBool",
+		"strconv.ParseFloat",
+		"strconv.ParseInt",
+		"strconv.ParseUint",
+	},
+}
+
+// config is the configuration in effect: Default, plus the
+// configurations named by -config flags.
+var config = Default
+
+// configFlag is the type of the -config flag, which adds the
+// sources, sinks, and sanitizers of a JSON Config file to config.
+type configFlag []string
+
+func (f *configFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *configFlag) Set(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("invalid taint config %s: %v", file, err)
+	}
+	for _, s := range c.Sinks {
+		if (s.Func == "") == (s.Type == "") {
+			return fmt.Errorf("invalid taint config %s: sink must have exactly one of func and type", file)
+		}
+	}
+	config = &Config{
+		Sources:    append(append([]string(nil), config.Sources...), c.Sources...),
+		Sinks:      append(append([]Sink(nil), config.Sinks...), c.Sinks...),
+		Sanitizers: append(append([]string(nil), config.Sanitizers...), c.Sanitizers...),
+	}
+	*f = append(*f, file)
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package taint defines an Analyzer that reports untrusted data
+// reaching sensitive operations, such as SQL queries and commands.
+//
+// # Analyzer taint
+//
+// taint: check for untrusted data reaching injection-sensitive operations
+//
+// The taint checker tracks the flow of untrusted data from its sources,
+// such as the contents of an HTTP request, through the values, struct
+// fields, and memory of each function, and reports it reaching a sink,
+// such as the query string of database/sql or the arguments of an
+// os/exec command:
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		name := r.FormValue("name")
+//		db.Query("SELECT * FROM users WHERE name = '" + name + "'") // untrusted data from (*http.Request).FormValue reaches (*sql.DB).Query
+//	}
+//
+// Data passed through a sanitizer, such as url.QueryEscape or
+// strconv.Atoi, is trusted. Data passed to any other function
+// taints the function's results, and the memory that its other
+// arguments refer to.
+//
+// Each function is summarized by facts: which of its results are
+// untrusted whatever its arguments, and which of its parameters reach
+// a sink. The checker uses the facts at calls, so it finds paths that
+// span functions and packages, and describes each step of a path in
+// the related information of its diagnostic.
+//
+// The default configuration covers the standard library: the sources
+// are the untrusted fields and methods of net/http.Request, and the
+// sinks are the query parameters of the database/sql DB, Tx, and Conn
+// types, the command and arguments of os/exec.Command and
+// CommandContext, and conversions to the html/template types, such as
+// HTML, that mark content as trusted. The -config flag names a JSON
+// file whose sources, sinks, and sanitizers are added to the default:
+//
+//	{
+//		"sources":    ["example.com/rpc.Request.Payload"],
+//		"sinks":      [{"func": "example.com/shell.Run", "args": [0]}],
+//		"sanitizers": ["example.com/shell.Quote"]
+//	}
+//
+// See the Config type for the form of the names.
+package taint
@@ -0,0 +1,16 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build ignore
+
+// The taint command runs the taint analyzer
+// on the specified packages.
+package main
+
+import (
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/taint"
+	"github.com/TBD54566975/golang-tools/go/analysis/singlechecker"
+)
+
+func main() { singlechecker.Main(taint.Analyzer) }
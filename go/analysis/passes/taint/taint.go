@@ -0,0 +1,797 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package taint
+
+import (
+	_ "embed"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/buildssa"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/internal/analysisutil"
+	"github.com/TBD54566975/golang-tools/go/ssa"
+	"github.com/TBD54566975/golang-tools/go/types/objectpath"
+)
+
+//go:embed doc.go
+var doc string
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "taint",
+	Doc:       analysisutil.MustExtractDoc(doc, "taint"),
+	URL:       "https://pkg.go.dev/github.com/TBD54566975/golang-tools/go/analysis/passes/taint",
+	Requires:  []*analysis.Analyzer{buildssa.Analyzer},
+	FactTypes: []analysis.Fact{new(summary)},
+	Run:       run,
+}
+
+func init() {
+	Analyzer.Flags.Var(new(configFlag), "config", "name of a JSON file of additional sources, sinks, and sanitizers")
+}
+
+// A summary records how untrusted data flows out of and into a
+// function, for use at its calls.
+type summary struct {
+	Results []flow // results that are untrusted whatever the arguments
+	Params  []flow // parameters that reach a sink
+}
+
+func (*summary) AFact() {}
+
+func (s *summary) String() string {
+	var parts []string
+	for _, f := range s.Results {
+		parts = append(parts, fmt.Sprintf("result %d from %s", f.Index, f.Source))
+	}
+	for _, f := range s.Params {
+		parts = append(parts, fmt.Sprintf("%s to %s", f.Name, f.Sink))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (s *summary) result(i int) *flow {
+	if s != nil {
+		for j := range s.Results {
+			if s.Results[j].Index == i {
+				return &s.Results[j]
+			}
+		}
+	}
+	return nil
+}
+
+func (s *summary) param(i int) *flow {
+	if s != nil {
+		for j := range s.Params {
+			if s.Params[j].Index == i {
+				return &s.Params[j]
+			}
+		}
+	}
+	return nil
+}
+
+// A flow describes a path of untrusted data out of a function, from
+// a source to one of its results, or into it, from one of its
+// parameters to a sink.
+type flow struct {
+	Index  int    // index of the result, or of the parameter (counting any receiver)
+	Name   string // name of the parameter
+	Source string // source of an untrusted result
+	Sink   string // sink reached by a parameter
+	Steps  []step // steps of the path, in order
+}
+
+// A step is one event along a path of untrusted data.
+type step struct {
+	PkgPath string          // package of the function containing the step
+	Object  objectpath.Path // path of that function within its package; empty if unnameable
+	Message string
+
+	pos token.Pos // position of the step, if in the current package
+}
+
+// maxSteps bounds the length of paths, and thus the size of facts.
+const maxSteps = 8
+
+func appendSteps(steps []step, more ...step) []step {
+	steps = append(append([]step(nil), steps...), more...)
+	if len(steps) > maxSteps {
+		steps = steps[:maxSteps]
+	}
+	return steps
+}
+
+// A taint describes why a value is untrusted.
+type taint struct {
+	source string // description of the source
+	steps  []step // path from the source to the value
+}
+
+// A hit is untrusted data reaching a sink.
+type hit struct {
+	pos   token.Pos
+	taint *taint
+	sink  string // description of the sink
+	via   string // function through which the data reached the sink, if any
+	steps []step // path from the argument to the sink
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ssainput := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+
+	a := &analyzer{
+		pass:       pass,
+		sources:    set(config.Sources),
+		sanitizers: set(config.Sanitizers),
+		sinkFuncs:  make(map[string]Sink),
+		sinkTypes:  make(map[string]bool),
+		summaries:  make(map[*ssa.Function]*summary),
+	}
+	for _, s := range config.Sinks {
+		if s.Func != "" {
+			a.sinkFuncs[s.Func] = s
+		} else {
+			a.sinkTypes[s.Type] = true
+		}
+	}
+
+	// Summarize the package's functions, iterating to a fixed
+	// point as they may call each other in any order.
+	for _, fn := range ssainput.SrcFuncs {
+		a.summaries[fn] = new(summary)
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range ssainput.SrcFuncs {
+			if a.summarize(fn) {
+				changed = true
+			}
+		}
+	}
+	for _, fn := range ssainput.SrcFuncs {
+		s := a.summaries[fn]
+		if obj, ok := fn.Object().(*types.Func); ok && len(s.Results)+len(s.Params) > 0 {
+			pass.ExportObjectFact(obj, s)
+		}
+	}
+
+	// Report untrusted data reaching sinks.
+	for _, fn := range ssainput.SrcFuncs {
+		if !a.mayReachSink(fn) {
+			continue
+		}
+		_, hits := a.analyze(fn, nil)
+		for _, h := range hits {
+			a.report(h)
+		}
+	}
+	return nil, nil
+}
+
+// An analyzer holds the state of the analysis of one package.
+type analyzer struct {
+	pass       *analysis.Pass
+	sources    map[string]bool
+	sanitizers map[string]bool
+	sinkFuncs  map[string]Sink
+	sinkTypes  map[string]bool
+	summaries  map[*ssa.Function]*summary    // summaries of the package's functions
+	packages   map[string]*types.Package     // packages visible to pass.Pkg, by path; built lazily
+	reported   map[token.Pos]map[string]bool // diagnostics reported, by position
+}
+
+// summaryOf returns the summary of the callee of a call, or nil.
+func (a *analyzer) summaryOf(callee *ssa.Function) *summary {
+	if callee == nil {
+		return nil
+	}
+	if orig := callee.Origin(); orig != nil {
+		callee = orig
+	}
+	if s, ok := a.summaries[callee]; ok {
+		return s
+	}
+	if obj, ok := callee.Object().(*types.Func); ok {
+		s := new(summary)
+		if a.pass.ImportObjectFact(obj, s) {
+			return s
+		}
+	}
+	return nil
+}
+
+// summarize adds to the summary of fn the flows that follow from the
+// current summaries of its callees, and reports whether it added any.
+func (a *analyzer) summarize(fn *ssa.Function) bool {
+	s := a.summaries[fn]
+	changed := false
+
+	if a.mayReturnSource(fn) {
+		results, _ := a.analyze(fn, nil)
+		for _, i := range sortedKeys(results) {
+			if s.result(i) == nil {
+				t := results[i]
+				s.Results = append(s.Results, flow{
+					Index:  i,
+					Source: t.source,
+					Steps:  appendSteps(t.steps, a.newStep(fn, token.NoPos, "%s returns untrusted data from %s", funcName(fn), t.source)),
+				})
+				changed = true
+			}
+		}
+	}
+
+	if a.mayReachSink(fn) {
+		for i, p := range fn.Params {
+			if s.param(i) != nil {
+				continue
+			}
+			var h *hit
+			_, hits := a.analyze(fn, p)
+			for i := range hits {
+				if hits[i].taint.source == seedSource(p) {
+					h = &hits[i]
+					break
+				}
+			}
+			if h == nil {
+				continue
+			}
+			steps := h.taint.steps
+			if h.via != "" {
+				steps = appendSteps(steps, a.newStep(fn, h.pos, "%s passes %s to %s", funcName(fn), p.Name(), h.via))
+				steps = appendSteps(steps, h.steps...)
+			} else {
+				steps = appendSteps(steps, a.newStep(fn, h.pos, "%s passes %s to %s", funcName(fn), p.Name(), h.sink))
+			}
+			s.Params = append(s.Params, flow{Index: i, Name: p.Name(), Sink: h.sink, Steps: steps})
+			changed = true
+		}
+	}
+	return changed
+}
+
+// mayReturnSource reports whether fn reads from a source, or calls a
+// function that returns untrusted data.
+func (a *analyzer) mayReturnSource(fn *ssa.Function) bool {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Call:
+				if a.sources[calleeName(instr.Common())] || len(a.summaryOf(instr.Common().StaticCallee()).resultsOrNil()) > 0 {
+					return true
+				}
+			case *ssa.FieldAddr, *ssa.Field:
+				if a.sources[fieldName(instr.(ssa.Value))] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (s *summary) resultsOrNil() []flow {
+	if s == nil {
+		return nil
+	}
+	return s.Results
+}
+
+// mayReachSink reports whether fn contains a sink, or calls a function
+// whose parameters reach one.
+func (a *analyzer) mayReachSink(fn *ssa.Function) bool {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case ssa.CallInstruction:
+				if _, ok := a.sinkFuncs[calleeName(instr.Common())]; ok {
+					return true
+				}
+				if s := a.summaryOf(instr.Common().StaticCallee()); s != nil && len(s.Params) > 0 {
+					return true
+				}
+			case *ssa.ChangeType, *ssa.Convert, *ssa.MultiConvert:
+				if a.sinkTypes[typeName(instr.(ssa.Value).Type())] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// analyze computes the untrusted values of fn, treating seed, if
+// non-nil, as untrusted. It returns the untrusted results of fn, by
+// index, and the untrusted data reaching sinks.
+func (a *analyzer) analyze(fn *ssa.Function, seed *ssa.Parameter) (map[int]*taint, []hit) {
+	st := &state{
+		a:      a,
+		fn:     fn,
+		values: make(map[ssa.Value]*taint),
+		memory: make(map[string]*taint),
+	}
+	if seed != nil {
+		st.values[seed] = &taint{source: seedSource(seed)}
+	}
+	for st.changed = true; st.changed; {
+		st.changed = false
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				st.transfer(instr)
+			}
+		}
+	}
+
+	results := make(map[int]*taint)
+	var hits []hit
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Return:
+				for i, v := range instr.Results {
+					if t := st.taintOf(v); t != nil && results[i] == nil {
+						results[i] = t
+					}
+				}
+			case ssa.CallInstruction:
+				hits = append(hits, st.callHits(instr)...)
+			case *ssa.ChangeType, *ssa.Convert, *ssa.MultiConvert:
+				v := instr.(ssa.Value)
+				if name := typeName(v.Type()); a.sinkTypes[name] {
+					if t := st.taintOf(*instr.Operands(nil)[0]); t != nil {
+						hits = append(hits, hit{pos: v.Pos(), taint: t, sink: "conversion to " + displayName(name)})
+					}
+				}
+			}
+		}
+	}
+	return results, hits
+}
+
+// seedSource returns the description of the source of data from
+// parameter p, when analyzing the flow of p to sinks.
+func seedSource(p *ssa.Parameter) string {
+	return "parameter " + p.Name()
+}
+
+// A state records the untrusted values and memory of a function.
+type state struct {
+	a       *analyzer
+	fn      *ssa.Function
+	values  map[ssa.Value]*taint
+	memory  map[string]*taint // untrusted memory locations, by key
+	changed bool
+}
+
+// setValue records that v is untrusted.
+func (st *state) setValue(v ssa.Value, t *taint) {
+	if st.values[v] == nil {
+		st.values[v] = t
+		st.changed = true
+	}
+}
+
+// setMemory records that the memory at the address ptr is untrusted.
+func (st *state) setMemory(ptr ssa.Value, t *taint) {
+	if k := key(ptr); st.memory[k] == nil {
+		st.memory[k] = t
+		st.changed = true
+	}
+}
+
+// taintOf returns the taint of v, or nil if v is trusted. A pointer
+// is untrusted if the memory it points to, or memory containing it,
+// or memory within it, is untrusted.
+func (st *state) taintOf(v ssa.Value) *taint {
+	if t := st.values[v]; t != nil {
+		return t
+	}
+	if _, ok := v.Type().Underlying().(*types.Pointer); !ok || len(st.memory) == 0 {
+		return nil
+	}
+	k := key(v)
+	var match []string
+	for mk := range st.memory {
+		if within(mk, k) || within(k, mk) {
+			match = append(match, mk)
+		}
+	}
+	if len(match) == 0 {
+		return nil
+	}
+	sort.Strings(match)
+	return st.memory[match[0]]
+}
+
+// within reports whether memory location k is within, or is, location outer.
+func within(k, outer string) bool {
+	return k == outer || strings.HasPrefix(k, outer+".") || strings.HasPrefix(k, outer+"[")
+}
+
+// key returns a key for the memory location to which ptr points.
+// Fields are distinguished, but not array or slice elements.
+func key(ptr ssa.Value) string {
+	switch ptr := ptr.(type) {
+	case *ssa.FieldAddr:
+		return key(ptr.X) + "." + strconv.Itoa(ptr.Field)
+	case *ssa.IndexAddr:
+		return key(ptr.X) + "[]"
+	case *ssa.Global:
+		return "global " + ptr.String()
+	}
+	return ptr.Name()
+}
+
+// transfer updates the state to reflect the execution of instr.
+func (st *state) transfer(instr ssa.Instruction) {
+	a := st.a
+	switch instr := instr.(type) {
+	case *ssa.Store:
+		if t := st.taintOf(instr.Val); t != nil {
+			st.setMemory(instr.Addr, t)
+		}
+		return
+
+	case *ssa.MapUpdate:
+		if t := st.taintOf(instr.Value); t != nil {
+			st.setValue(instr.Map, t)
+		}
+		return
+
+	case *ssa.Send:
+		if t := st.taintOf(instr.X); t != nil {
+			st.setValue(instr.Chan, t)
+		}
+		return
+
+	case ssa.CallInstruction:
+		st.call(instr)
+		return
+
+	case *ssa.FieldAddr, *ssa.Field:
+		v := instr.(ssa.Value)
+		if name := fieldName(v); a.sources[name] {
+			st.setValue(v, &taint{
+				source: displayName(name),
+				steps:  []step{a.newStep(st.fn, v.Pos(), "%s is untrusted", displayName(name))},
+			})
+			return
+		}
+		if fa, ok := instr.(*ssa.FieldAddr); ok {
+			// The address of a field is untrusted if its base is, but
+			// not merely because another field's memory is untrusted.
+			if t := st.values[fa.X]; t != nil {
+				st.setValue(fa, t)
+			}
+			return
+		}
+
+	case *ssa.Alloc:
+		return // untrusted only through memory
+
+	case *ssa.BinOp:
+		switch instr.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return // comparisons yield trusted booleans
+		}
+
+	case *ssa.Lookup:
+		if t := st.taintOf(instr.X); t != nil {
+			st.setValue(instr, t)
+		}
+		return // an untrusted key does not make the element untrusted
+
+	case *ssa.Index:
+		if t := st.taintOf(instr.X); t != nil {
+			st.setValue(instr, t)
+		}
+		return
+
+	case *ssa.IndexAddr:
+		if t := st.taintOf(instr.X); t != nil {
+			st.setValue(instr, t)
+		}
+		return
+	}
+
+	// Other values are untrusted if any operand is.
+	if v, ok := instr.(ssa.Value); ok {
+		for _, op := range instr.Operands(nil) {
+			if *op != nil {
+				if t := st.taintOf(*op); t != nil {
+					st.setValue(v, t)
+					return
+				}
+			}
+		}
+	}
+}
+
+// call updates the state to reflect a call. The results of a call to
+// a source are untrusted, and those of a call to a sanitizer are
+// trusted. Otherwise, untrusted arguments make the results untrusted,
+// along with any memory or containers the other arguments refer to.
+func (st *state) call(call ssa.CallInstruction) {
+	a := st.a
+	common := call.Common()
+	name := calleeName(common)
+	if a.sanitizers[name] {
+		return
+	}
+	v := call.Value() // nil for go and defer
+
+	if a.sources[name] && v != nil {
+		display := displayName(name)
+		st.setValue(v, &taint{
+			source: display,
+			steps:  []step{a.newStep(st.fn, call.Pos(), "%s returns untrusted data", display)},
+		})
+		return
+	}
+	if s := a.summaryOf(common.StaticCallee()); s != nil && len(s.Results) > 0 && v != nil {
+		f := s.Results[0]
+		st.setValue(v, &taint{source: f.Source, steps: f.Steps})
+		return
+	}
+	if _, ok := a.sinkFuncs[name]; ok {
+		return // a sink's results are not its arguments
+	}
+
+	var t *taint
+	args := common.Args
+	if common.IsInvoke() {
+		args = append([]ssa.Value{common.Value}, args...)
+	}
+	for _, arg := range args {
+		if t = st.taintOf(arg); t != nil {
+			break
+		}
+	}
+	if t == nil {
+		return
+	}
+	if v != nil {
+		st.setValue(v, t)
+	}
+	for _, arg := range args {
+		switch arg.Type().Underlying().(type) {
+		case *types.Pointer:
+			st.setMemory(arg, t)
+		case *types.Map, *types.Slice, *types.Chan, *types.Interface:
+			st.setValue(arg, t)
+		}
+	}
+}
+
+// callHits returns the untrusted arguments of a call that reach a
+// sink: those of a sensitive parameter of a sink, or of a parameter
+// of a function that passes it to one.
+func (st *state) callHits(call ssa.CallInstruction) []hit {
+	common := call.Common()
+	name := calleeName(common)
+
+	if sink, ok := st.a.sinkFuncs[name]; ok {
+		offset := 0 // SSA arguments of static method calls include the receiver
+		if !common.IsInvoke() && common.Signature().Recv() != nil {
+			offset = 1
+		}
+		indices := sink.Args
+		if len(indices) == 0 {
+			for i := range common.Args[offset:] {
+				indices = append(indices, i)
+			}
+		}
+		for _, i := range indices {
+			if i+offset < len(common.Args) {
+				if t := st.taintOf(common.Args[i+offset]); t != nil {
+					return []hit{{pos: call.Pos(), taint: t, sink: displayName(name)}}
+				}
+			}
+		}
+		return nil
+	}
+
+	var hits []hit
+	callee := common.StaticCallee()
+	if s := st.a.summaryOf(callee); s != nil {
+		for _, f := range s.Params {
+			if f.Index < len(common.Args) {
+				if t := st.taintOf(common.Args[f.Index]); t != nil {
+					hits = append(hits, hit{pos: call.Pos(), taint: t, sink: f.Sink, via: funcName(callee), steps: f.Steps})
+				}
+			}
+		}
+	}
+	return hits
+}
+
+// report reports a hit found in the current package.
+func (a *analyzer) report(h hit) {
+	if !h.pos.IsValid() {
+		return // no syntax
+	}
+	msg := fmt.Sprintf("untrusted data from %s reaches %s", h.taint.source, h.sink)
+	if h.via != "" {
+		msg += " through " + h.via
+	}
+	if a.reported == nil {
+		a.reported = make(map[token.Pos]map[string]bool)
+	}
+	if a.reported[h.pos][msg] {
+		return
+	}
+	if a.reported[h.pos] == nil {
+		a.reported[h.pos] = make(map[string]bool)
+	}
+	a.reported[h.pos][msg] = true
+
+	var related []analysis.RelatedInformation
+	for _, s := range appendSteps(h.taint.steps, h.steps...) {
+		related = append(related, analysis.RelatedInformation{
+			Pos:     a.stepPos(s, h.pos),
+			Message: s.Message,
+		})
+	}
+	a.pass.Report(analysis.Diagnostic{
+		Pos:     h.pos,
+		Message: msg,
+		Related: related,
+	})
+}
+
+// newStep returns a step at pos within fn.
+func (a *analyzer) newStep(fn *ssa.Function, pos token.Pos, format string, args ...interface{}) step {
+	s := step{PkgPath: a.pass.Pkg.Path(), Message: fmt.Sprintf(format, args...), pos: pos}
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	if obj, ok := fn.Object().(*types.Func); ok {
+		s.Object, _ = objectpath.For(obj)
+		if !pos.IsValid() {
+			s.pos = obj.Pos()
+		}
+	}
+	return s
+}
+
+// stepPos returns the position of step s: its own position if it is
+// in the current package, otherwise that of its function, or fallback
+// if the function is not visible to the current package.
+func (a *analyzer) stepPos(s step, fallback token.Pos) token.Pos {
+	if s.pos.IsValid() && s.PkgPath == a.pass.Pkg.Path() {
+		return s.pos
+	}
+	if a.packages == nil {
+		a.packages = make(map[string]*types.Package)
+		var add func(pkg *types.Package)
+		add = func(pkg *types.Package) {
+			if a.packages[pkg.Path()] == nil {
+				a.packages[pkg.Path()] = pkg
+				for _, imp := range pkg.Imports() {
+					add(imp)
+				}
+			}
+		}
+		add(a.pass.Pkg)
+	}
+	if pkg := a.packages[s.PkgPath]; pkg != nil && s.Object != "" {
+		if obj, err := objectpath.Object(pkg, s.Object); err == nil && obj.Pos().IsValid() {
+			return obj.Pos()
+		}
+	}
+	return fallback
+}
+
+// calleeName returns the full name of the function or method called,
+// or "" if it is not known statically.
+func calleeName(common *ssa.CallCommon) string {
+	if common.IsInvoke() {
+		return common.Method.FullName()
+	}
+	if callee := common.StaticCallee(); callee != nil {
+		if orig := callee.Origin(); orig != nil {
+			callee = orig
+		}
+		if obj, ok := callee.Object().(*types.Func); ok {
+			return obj.FullName()
+		}
+	}
+	return ""
+}
+
+// fieldName returns the name of the field selected by a Field or
+// FieldAddr instruction, in the form "path/to/pkg.T.f", or "" if the
+// struct type is unnamed.
+func fieldName(v ssa.Value) string {
+	var x types.Type
+	var index int
+	switch v := v.(type) {
+	case *ssa.FieldAddr:
+		ptr, ok := v.X.Type().Underlying().(*types.Pointer)
+		if !ok {
+			return ""
+		}
+		x, index = ptr.Elem(), v.Field
+	case *ssa.Field:
+		x, index = v.X.Type(), v.Field
+	default:
+		return ""
+	}
+	st, ok := x.Underlying().(*types.Struct)
+	if !ok {
+		return ""
+	}
+	if name := typeName(x); name != "" {
+		return name + "." + st.Field(index).Name()
+	}
+	return ""
+}
+
+// typeName returns the name of a named type, in the form
+// "path/to/pkg.T", or "".
+func typeName(t types.Type) string {
+	if named, ok := types.Unalias(t).(*types.Named); ok && named.Obj().Pkg() != nil {
+		obj := named.Origin().Obj()
+		return obj.Pkg().Path() + "." + obj.Name()
+	}
+	return ""
+}
+
+// displayName returns the short form of a function, field, or type
+// name used in messages, which names packages by the last element
+// of their path.
+func displayName(name string) string {
+	if strings.HasPrefix(name, "(") {
+		// method: (*path/to/pkg.T).M or (path/to/pkg.T).M
+		i := strings.Index(name, ")")
+		recv, method := name[1:i], name[i+1:]
+		star := ""
+		if strings.HasPrefix(recv, "*") {
+			star, recv = "*", recv[1:]
+		}
+		return "(" + star + displayName(recv) + ")" + method
+	}
+	// Strip the directories of the package path; dots may appear
+	// only after the last slash.
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// funcName returns the name of fn for use in messages.
+func funcName(fn *ssa.Function) string {
+	if orig := fn.Origin(); orig != nil {
+		fn = orig
+	}
+	if obj, ok := fn.Object().(*types.Func); ok {
+		return displayName(obj.FullName())
+	}
+	return fn.Name()
+}
+
+func set(list []string) map[string]bool {
+	m := make(map[string]bool, len(list))
+	for _, s := range list {
+		m[s] = true
+	}
+	return m
+}
+
+func sortedKeys(m map[int]*taint) []int {
+	var keys []int
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
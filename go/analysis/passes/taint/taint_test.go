@@ -0,0 +1,21 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package taint_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/TBD54566975/golang-tools/go/analysis/analysistest"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/taint"
+)
+
+func Test(t *testing.T) {
+	testdata := analysistest.TestData()
+	if err := taint.Analyzer.Flags.Set("config", filepath.Join(testdata, "config.json")); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.Run(t, testdata, taint.Analyzer, "a")
+}
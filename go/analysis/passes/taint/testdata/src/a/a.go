@@ -0,0 +1,75 @@
+package a
+
+import (
+	"b"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var db *sql.DB
+
+func Query(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	db.Query("SELECT * FROM users WHERE name = '" + name + "'") // want `untrusted data from \(\*http.Request\).FormValue reaches \(\*sql.DB\).Query`
+
+	q := fmt.Sprintf("SELECT * FROM users WHERE id = %s", r.URL.Query().Get("id"))
+	db.Exec(q) // want `untrusted data from http.Request.URL reaches \(\*sql.DB\).Exec`
+
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	db.Exec(fmt.Sprintf("DELETE FROM users WHERE id = %d", id)) // ok: sanitized
+
+	db.QueryRow("SELECT name FROM users WHERE id = ?", name) // ok: parameterized
+}
+
+func Command(r *http.Request) {
+	exec.Command("ls", r.Header.Get("Dir")) // want `untrusted data from http.Request.Header reaches exec.Command`
+
+	var args []string
+	args = append(args, r.PostFormValue("arg"))
+	exec.Command("echo", args...) // want `untrusted data from \(\*http.Request\).PostFormValue reaches exec.Command`
+
+	exec.Command("echo", url.QueryEscape(r.FormValue("arg"))) // ok: sanitized
+}
+
+func HTML(w http.ResponseWriter, r *http.Request) {
+	var sb strings.Builder
+	sb.WriteString("<p>")
+	sb.WriteString(r.FormValue("msg"))
+	_ = template.HTML(sb.String()) // want `untrusted data from \(\*http.Request\).FormValue reaches conversion to template.HTML`
+
+	_ = template.HTML(template.HTMLEscapeString(r.FormValue("msg"))) // ok: sanitized
+}
+
+type request struct {
+	user  string
+	limit int
+}
+
+func Fields(r *http.Request) {
+	var req request
+	req.user = r.FormValue("user")
+	req.limit = 10
+	db.Query(fmt.Sprint("SELECT * FROM t LIMIT ", req.limit)) // ok: another field
+	db.Query("SELECT * FROM t WHERE user = " + req.user)      // want `untrusted data from \(\*http.Request\).FormValue reaches \(\*sql.DB\).Query`
+}
+
+func Facts(r *http.Request) {
+	b.Find(db, b.Name(r))                // want `untrusted data from \(\*http.Request\).FormValue reaches \(\*sql.DB\).Query through b.Find` -> `\(\*http.Request\).FormValue returns untrusted data` -> `b.Name returns untrusted data from \(\*http.Request\).FormValue` -> `b.Find passes name to \(\*sql.DB\).Query`
+	b.Shell(r.FormValue("cmd"))          // want `untrusted data from \(\*http.Request\).FormValue reaches b.run through b.Shell` -> `\(\*http.Request\).FormValue returns untrusted data` -> `b.Shell passes cmd to b.run`
+	b.Shell(b.Quote(r.FormValue("cmd"))) // ok: sanitized by config
+}
+
+// lookup returns untrusted data.
+func lookup(r *http.Request) string { // want lookup:"result 0 from \\(\\*http.Request\\).FormValue"
+	return strings.TrimSpace(r.FormValue("q"))
+}
+
+func Local(r *http.Request) {
+	db.Query(lookup(r)) // want `untrusted data from \(\*http.Request\).FormValue reaches \(\*sql.DB\).Query`
+}
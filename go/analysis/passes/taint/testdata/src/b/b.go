@@ -0,0 +1,28 @@
+package b
+
+import (
+	"database/sql"
+	"net/http"
+)
+
+// Name returns untrusted data.
+func Name(r *http.Request) string { // want Name:"result 0 from \\(\\*http.Request\\).FormValue"
+	return r.FormValue("name")
+}
+
+// Find passes its parameter to a query.
+func Find(db *sql.DB, name string) (*sql.Rows, error) { // want Find:"name to \\(\\*sql.DB\\).Query"
+	return db.Query("SELECT * FROM users WHERE name = '" + name + "'")
+}
+
+// Shell runs a command.
+func Shell(cmd string) { // want Shell:"cmd to b.run"
+	run(cmd)
+}
+
+func run(cmd string) {}
+
+// Quote makes a command safe.
+func Quote(s string) string {
+	return "'" + s + "'"
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package unclosed defines an Analyzer that reports resources that
+// are not released on every path through a function.
+//
+// # Analyzer unclosed
+//
+// unclosed: check that resources are closed on all paths
+//
+// The unclosed checker tracks each value of a closable type returned
+// by a call, from the call to every return from the enclosing
+// function, and reports the value if some path neither releases it nor
+// lets it escape. The closable types are:
+//
+//   - types with a method Close(), perhaps returning an error, such as
+//     *os.File, *sql.Rows and io.ReadCloser, released by calling Close;
+//   - *net/http.Response, released by calling Close on its Body;
+//   - *time.Ticker, released by calling Stop;
+//   - context.CancelFunc, released by calling it. (Functions of the
+//     context package itself are the concern of the lostcancel checker.)
+//
+// Paths on which the call's error result is known to be non-nil, or
+// the value itself to be nil, are ignored. A value escapes if it is
+// stored, returned, captured by a function literal, or passed to a
+// function as anything other than an interface lacking the release
+// method, such as io.Reader. Deferred calls release the value on the
+// path on which they are deferred.
+//
+// For example:
+//
+//	resp, err := http.Get(url)
+//	if err != nil {
+//		return err
+//	}
+//	if resp.StatusCode != http.StatusOK { // resp.Body.Close() is not called on all paths
+//		return fmt.Errorf("%s: %s", url, resp.Status)
+//	}
+//	defer resp.Body.Close()
+//
+// When the value is assigned to a variable and any error is checked
+// immediately, the checker suggests a fix that releases it with a
+// deferred call after the check.
+//
+// The -closers flag adds types whose values must be released, as a
+// comma-separated list of entries of the form
+// importpath.Type.Method, or importpath.Type.Field.Method if the
+// resource is held by a field. A leading '*' on Type is optional. For
+// example:
+//
+//	-closers=example.com/pool.*Conn.Release,example.com/rpc.Reply.Stream.Close
+package unclosed
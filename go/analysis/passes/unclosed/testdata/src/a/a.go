@@ -0,0 +1,138 @@
+package a
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+func _(name string) error {
+	f, err := os.Open(name) // want `f.Close\(\) is not called on all paths`
+	if err != nil {
+		return err
+	}
+	_, err = bufio.NewReader(f).ReadString('\n')
+	return err
+}
+
+func _(name string) error {
+	f, err := os.Open(name) // ok: closed
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.ReadAll(f)
+	return err
+}
+
+func _(name string) (*os.File, error) {
+	f, err := os.Open(name) // ok: returned
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func _(url string) error {
+	resp, err := http.Get(url) // want `resp.Body.Close\(\) is not called on all paths`
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	return err
+}
+
+func _(url string) error {
+	resp, err := http.Get(url) // ok
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	return err
+}
+
+func _(db *sql.DB) error {
+	rows, err := db.Query("SELECT 1") // want `rows.Close\(\) is not called on all paths`
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+func _(db *sql.DB) {
+	rows, _ := db.Query("SELECT 1") // want `rows.Close\(\) is not called on all paths`
+	if rows == nil {
+		return
+	}
+	rows.Next()
+}
+
+func _() {
+	t := time.NewTicker(time.Second) // want `t.Stop\(\) is not called on all paths`
+	<-t.C
+}
+
+func _() {
+	t := time.NewTicker(time.Second) // ok: stopped
+	defer t.Stop()
+	<-t.C
+}
+
+func _(names []string) {
+	for _, name := range names {
+		f, err := os.Open(name) // want `f.Close\(\) is not called on all paths`
+		if err != nil {
+			continue
+		}
+		f.Stat()
+	}
+}
+
+func start() context.CancelFunc { return func() {} }
+
+func _() {
+	stop := start() // want `stop\(\) is not called on all paths`
+	_ = stop
+}
+
+func _() {
+	stop := start() // ok: called
+	stop()
+}
+
+func _() {
+	_, cancel := context.WithCancel(context.Background()) // ok: see lostcancel
+	_ = cancel
+}
+
+func _(name string) {
+	f, err := os.Open(name) // ok: captured
+	if err != nil {
+		return
+	}
+	defer func() { f.Close() }()
+}
+
+func _(name string) {
+	if f, err := os.Open(name); err == nil { // want `f.Close\(\) is not called on all paths`
+		f.Stat()
+	}
+}
+
+func open() io.ReadCloser { return nil }
+
+func _() {
+	open().Read(nil) // want `result of a.open is not released on all paths`
+}
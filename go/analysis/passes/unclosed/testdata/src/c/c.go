@@ -0,0 +1,17 @@
+package c
+
+type Conn struct{}
+
+func (*Conn) Release() {}
+
+func Dial() *Conn { return new(Conn) }
+
+func _() {
+	conn := Dial() // want `conn.Release\(\) is not called on all paths`
+	_ = conn
+}
+
+func _() {
+	conn := Dial() // ok
+	defer conn.Release()
+}
@@ -0,0 +1,521 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unclosed
+
+import (
+	_ "embed"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/buildssa"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/internal/analysisutil"
+	"github.com/TBD54566975/golang-tools/go/ast/astutil"
+	"github.com/TBD54566975/golang-tools/go/ssa"
+)
+
+func init() {
+	Analyzer.Flags.Var(&closers, "closers", "comma-separated list of additional types to release, as importpath.Type.Method or importpath.Type.Field.Method")
+}
+
+//go:embed doc.go
+var doc string
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "unclosed",
+	Doc:      analysisutil.MustExtractDoc(doc, "unclosed"),
+	URL:      "https://pkg.go.dev/github.com/TBD54566975/golang-tools/go/analysis/passes/unclosed",
+	Requires: []*analysis.Analyzer{buildssa.Analyzer},
+	Run:      run,
+}
+
+// A closer describes a named type whose values must be released.
+type closer struct {
+	pkg, typ string // package path and name of the type, which may be used through a pointer
+	field    string // field holding the resource, if not the value itself
+	method   string // method that releases the resource; empty if the value is a function to call
+}
+
+// closers holds the types, other than those with a Close method,
+// whose values must be released.
+var closers = closerList{
+	{pkg: "context", typ: "CancelFunc"},
+	{pkg: "net/http", typ: "Response", field: "Body", method: "Close"},
+	{pkg: "time", typ: "Ticker", method: "Stop"},
+}
+
+type closerList []closer
+
+func (list *closerList) String() string {
+	var entries []string
+	for _, c := range *list {
+		entry := c.pkg + "." + c.typ
+		if c.field != "" {
+			entry += "." + c.field
+		}
+		if c.method != "" {
+			entry += "." + c.method
+		}
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+func (list *closerList) Set(flag string) error {
+	for _, entry := range strings.Split(flag, ",") {
+		// The package path may contain dots before its last slash.
+		slash := strings.LastIndex(entry, "/")
+		parts := strings.Split(entry[slash+1:], ".")
+		if len(parts) != 3 && len(parts) != 4 {
+			return fmt.Errorf("invalid entry %q: want importpath.Type.Method or importpath.Type.Field.Method", entry)
+		}
+		for _, part := range parts {
+			if part == "" {
+				return fmt.Errorf("invalid entry %q: empty component", entry)
+			}
+		}
+		c := closer{
+			pkg:    entry[:slash+1] + parts[0],
+			typ:    strings.TrimPrefix(parts[1], "*"),
+			method: parts[len(parts)-1],
+		}
+		if len(parts) == 4 {
+			c.field = parts[2]
+		}
+		*list = append(*list, c)
+	}
+	return nil
+}
+
+// closerOf returns the closer for values of type t returned by a call
+// to callee (nil for a dynamic call), or nil if they need no release.
+func closerOf(t types.Type, callee *types.Func) *closer {
+	named := t
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		named = ptr.Elem()
+	}
+	if named, ok := named.(*types.Named); ok && named.Obj().Pkg() != nil {
+		obj := named.Obj()
+		for i, c := range closers {
+			if c.pkg == obj.Pkg().Path() && c.typ == obj.Name() {
+				if c.method == "" && callee != nil && callee.Pkg() != nil && callee.Pkg().Path() == "context" {
+					return nil // see lostcancel
+				}
+				return &closers[i]
+			}
+		}
+	}
+	if hasRelease(t, "Close") {
+		return &closer{method: "Close"}
+	}
+	return nil
+}
+
+// hasRelease reports whether the method set of t has a method name
+// with no parameters and no results other than an error.
+func hasRelease(t types.Type, name string) bool {
+	sel := types.NewMethodSet(t).Lookup(nil, name)
+	if sel == nil {
+		return false
+	}
+	sig := sel.Type().(*types.Signature)
+	res := sig.Results()
+	return sig.Params().Len() == 0 &&
+		(res.Len() == 0 || res.Len() == 1 && types.Identical(res.At(0).Type(), errorType))
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ssainput := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	for _, fn := range ssainput.SrcFuncs {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(*ssa.Call); ok {
+					checkCall(pass, call)
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// checkCall checks each closable result of a call.
+func checkCall(pass *analysis.Pass, call *ssa.Call) {
+	var callee *types.Func
+	if fn := call.Call.StaticCallee(); fn != nil {
+		callee, _ = fn.Object().(*types.Func)
+	}
+	tuple, ok := call.Type().(*types.Tuple)
+	if !ok {
+		if c := closerOf(call.Type(), callee); c != nil {
+			check(pass, call, call, 0, nil, c)
+		}
+		return
+	}
+
+	// The error result, if any, indicates whether the others are valid.
+	var errv ssa.Value
+	if n := tuple.Len(); n > 1 && types.Identical(tuple.At(n-1).Type(), errorType) {
+		for _, instr := range *call.Referrers() {
+			if e, ok := instr.(*ssa.Extract); ok && e.Index == n-1 {
+				errv = e
+			}
+		}
+	}
+	for _, instr := range *call.Referrers() {
+		if e, ok := instr.(*ssa.Extract); ok && e != errv {
+			if c := closerOf(e.Type(), callee); c != nil {
+				check(pass, call, e, e.Index, errv, c)
+			}
+		}
+	}
+}
+
+// check reports v, result i of call, if it is not released on all
+// paths and does not escape.
+func check(pass *analysis.Pass, call *ssa.Call, v ssa.Value, i int, errv ssa.Value, c *closer) {
+	if !call.Pos().IsValid() || escapes(v, c) {
+		return
+	}
+	ret, ok := leak(call, v, errv, c)
+	if !ok {
+		return
+	}
+
+	diag := analysis.Diagnostic{Pos: call.Pos()}
+	name, fix := suggestFix(pass, call, i, errv != nil, c)
+	if name != "" {
+		diag.Message = fmt.Sprintf("%s is not called on all paths", c.release(name))
+		diag.SuggestedFixes = fix
+	} else {
+		diag.Message = fmt.Sprintf("result of %s is not released on all paths", describe(call.Common()))
+	}
+	if ret != nil && ret.Pos().IsValid() {
+		diag.Related = []analysis.RelatedInformation{{Pos: ret.Pos(), Message: "this return leaks the resource"}}
+	}
+	pass.Report(diag)
+}
+
+// release returns the expression that releases the resource of the
+// variable name.
+func (c *closer) release(name string) string {
+	if c.method == "" {
+		return name + "()"
+	}
+	if c.field != "" {
+		name += "." + c.field
+	}
+	return name + "." + c.method + "()"
+}
+
+// escapes reports whether v may be released other than by the
+// function that created it.
+func escapes(v ssa.Value, c *closer) bool {
+	for _, instr := range *v.Referrers() {
+		switch instr := instr.(type) {
+		case ssa.CallInstruction:
+			cc := instr.Common()
+			if receiver(cc) == v || c.method == "" && !cc.IsInvoke() && cc.Value == v {
+				continue // method call, or call of the function itself
+			}
+			return true // passed as an argument
+
+		case *ssa.FieldAddr:
+			if c.field != "" && fieldName(instr) == c.field {
+				for _, use := range *instr.Referrers() {
+					load, ok := use.(*ssa.UnOp)
+					if !ok || load.Op != token.MUL {
+						return true // address of field escapes
+					}
+					if escapes(load, &closer{method: c.method}) {
+						return true
+					}
+				}
+			}
+
+		case *ssa.MakeInterface, *ssa.ChangeInterface:
+			// Conversion to an interface, such as io.Reader, through
+			// which the resource cannot be released is harmless.
+			if c.method == "" || hasRelease(instr.(ssa.Value).Type(), c.method) {
+				return true
+			}
+
+		case *ssa.Store:
+			if instr.Val == v {
+				return true
+			}
+
+		case *ssa.BinOp, *ssa.UnOp, *ssa.DebugRef:
+			// comparison or dereference
+
+		default:
+			return true // returned, captured, sent, merged, and so on
+		}
+	}
+	return false
+}
+
+// leak reports whether some path from call to a return from its
+// function does not release v. It returns the return instruction of
+// such a path, or nil if the path instead leads back to the call,
+// which creates another resource.
+func leak(call *ssa.Call, v, errv ssa.Value, c *closer) (*ssa.Return, bool) {
+	seen := make(map[*ssa.BasicBlock]bool)
+	var visit func(b *ssa.BasicBlock, start int) (*ssa.Return, bool)
+	visit = func(b *ssa.BasicBlock, start int) (*ssa.Return, bool) {
+		for _, instr := range b.Instrs[start:] {
+			if instr == call {
+				return nil, true
+			}
+			if isRelease(instr, v, c) {
+				return nil, false
+			}
+			switch instr := instr.(type) {
+			case *ssa.Return:
+				return instr, true
+			case *ssa.Panic:
+				return nil, false
+			}
+		}
+
+		// Skip the successor on which err != nil or v == nil.
+		var skip *ssa.BasicBlock
+		if binop, tsucc, fsucc := eq(b); binop != nil {
+			if isNilComparison(binop, errv) {
+				skip = fsucc
+			} else if isNilComparison(binop, v) {
+				skip = tsucc
+			}
+		}
+		for _, succ := range b.Succs {
+			if succ != skip && !seen[succ] {
+				seen[succ] = true
+				if ret, ok := visit(succ, 0); ok {
+					return ret, true
+				}
+			}
+		}
+		return nil, false
+	}
+	b := call.Block()
+	for i, instr := range b.Instrs {
+		if instr == call {
+			return visit(b, i+1)
+		}
+	}
+	return nil, false // unreachable
+}
+
+// isRelease reports whether instr releases v.
+func isRelease(instr ssa.Instruction, v ssa.Value, c *closer) bool {
+	call, ok := instr.(ssa.CallInstruction)
+	if !ok {
+		return false
+	}
+	cc := call.Common()
+	if c.method == "" {
+		return !cc.IsInvoke() && cc.Value == v
+	}
+	if methodName(cc) != c.method {
+		return false
+	}
+	recv := receiver(cc)
+	if c.field == "" {
+		return recv == v
+	}
+	// Match v.field.method().
+	if load, ok := recv.(*ssa.UnOp); ok && load.Op == token.MUL {
+		if fa, ok := load.X.(*ssa.FieldAddr); ok {
+			return fa.X == v && fieldName(fa) == c.field
+		}
+	}
+	return false
+}
+
+// receiver returns the receiver of a method call, or nil.
+func receiver(cc *ssa.CallCommon) ssa.Value {
+	if cc.IsInvoke() {
+		return cc.Value
+	}
+	if fn := cc.StaticCallee(); fn != nil && fn.Signature.Recv() != nil && len(cc.Args) > 0 {
+		return cc.Args[0]
+	}
+	return nil
+}
+
+// methodName returns the name of the method called by cc, or "".
+func methodName(cc *ssa.CallCommon) string {
+	if cc.IsInvoke() {
+		return cc.Method.Name()
+	}
+	if fn := cc.StaticCallee(); fn != nil && fn.Signature.Recv() != nil {
+		return fn.Name()
+	}
+	return ""
+}
+
+// describe returns the name of the function called by cc.
+func describe(cc *ssa.CallCommon) string {
+	if cc.IsInvoke() {
+		return cc.Method.Name()
+	}
+	if fn := cc.StaticCallee(); fn != nil {
+		if obj, ok := fn.Object().(*types.Func); ok {
+			if obj.Type().(*types.Signature).Recv() == nil {
+				return obj.Pkg().Name() + "." + obj.Name()
+			}
+			return obj.Name()
+		}
+	}
+	return "call"
+}
+
+// fieldName returns the name of the field selected by fa.
+func fieldName(fa *ssa.FieldAddr) string {
+	ptr := fa.X.Type().Underlying().(*types.Pointer)
+	return ptr.Elem().Underlying().(*types.Struct).Field(fa.Field).Name()
+}
+
+// isNilComparison reports whether binop compares v with nil.
+func isNilComparison(binop *ssa.BinOp, v ssa.Value) bool {
+	if v == nil {
+		return false
+	}
+	return binop.X == v && isNil(binop.Y) || binop.Y == v && isNil(binop.X)
+}
+
+func isNil(v ssa.Value) bool {
+	c, ok := v.(*ssa.Const)
+	return ok && c.IsNil()
+}
+
+// If b ends with an equality comparison, eq returns the operation and
+// its true (equal) and false (not equal) successors.
+func eq(b *ssa.BasicBlock) (op *ssa.BinOp, tsucc, fsucc *ssa.BasicBlock) {
+	if If, ok := b.Instrs[len(b.Instrs)-1].(*ssa.If); ok {
+		if binop, ok := If.Cond.(*ssa.BinOp); ok {
+			switch binop.Op {
+			case token.EQL:
+				return binop, b.Succs[0], b.Succs[1]
+			case token.NEQ:
+				return binop, b.Succs[1], b.Succs[0]
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// suggestFix returns the name of the variable to which result i of
+// call is assigned, if any, and a fix that releases it with a
+// deferred call, if the call is followed by a check of its error
+// result (if hasErr) such as:
+//
+//	f, err := os.Open(name)
+//	if err != nil {
+//		...
+//	}
+func suggestFix(pass *analysis.Pass, call *ssa.Call, i int, hasErr bool, c *closer) (string, []analysis.SuggestedFix) {
+	var file *ast.File
+	for _, f := range pass.Files {
+		if f.Pos() <= call.Pos() && call.Pos() < f.End() {
+			file = f
+		}
+	}
+	if file == nil {
+		return "", nil
+	}
+	path, _ := astutil.PathEnclosingInterval(file, call.Pos(), call.Pos())
+	var assign *ast.AssignStmt
+	for j, n := range path {
+		if a, ok := n.(*ast.AssignStmt); ok {
+			assign = a
+			path = path[j+1:]
+			break
+		}
+	}
+	if assign == nil || len(assign.Rhs) != 1 || i >= len(assign.Lhs) {
+		return "", nil
+	}
+	if rhs, ok := assign.Rhs[0].(*ast.CallExpr); !ok || rhs.Lparen != call.Pos() {
+		return "", nil // not the outermost call
+	}
+	id, ok := assign.Lhs[i].(*ast.Ident)
+	if !ok || id.Name == "_" {
+		return "", nil
+	}
+	name := id.Name
+
+	// A deferred call in a loop would not run until the function returns.
+	for _, n := range path {
+		if _, ok := n.(*ast.FuncLit); ok {
+			break
+		}
+		switch n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			return name, nil
+		}
+	}
+
+	// Find the statement after which to insert the deferred call.
+	var stmts []ast.Stmt
+	switch parent := path[0].(type) {
+	case *ast.BlockStmt:
+		stmts = parent.List
+	case *ast.CaseClause:
+		stmts = parent.Body
+	case *ast.CommClause:
+		stmts = parent.Body
+	}
+	var after ast.Stmt
+	for j, stmt := range stmts {
+		if stmt != assign {
+			continue
+		}
+		if !hasErr {
+			after = assign
+		} else if errID, ok := assign.Lhs[len(assign.Lhs)-1].(*ast.Ident); ok && j+1 < len(stmts) {
+			if ifstmt, ok := stmts[j+1].(*ast.IfStmt); ok && ifstmt.Init == nil && ifstmt.Else == nil && isErrCheck(ifstmt.Cond, errID.Name) {
+				after = ifstmt
+			}
+		}
+	}
+	if after == nil {
+		return name, nil
+	}
+
+	// Insert at the end of the line, after any comment.
+	tf := pass.Fset.File(after.End())
+	line := tf.Line(after.End())
+	if line == tf.LineCount() {
+		return name, nil
+	}
+	eol := tf.LineStart(line+1) - 1
+	indent := strings.Repeat("\t", pass.Fset.Position(assign.Pos()).Column-1)
+	release := c.release(name)
+	return name, []analysis.SuggestedFix{{
+		Message: "Defer " + release,
+		TextEdits: []analysis.TextEdit{{
+			Pos:     eol,
+			End:     eol,
+			NewText: []byte("\n" + indent + "defer " + release),
+		}},
+	}}
+}
+
+// isErrCheck reports whether cond is "err != nil" for the named variable.
+func isErrCheck(cond ast.Expr, err string) bool {
+	if bin, ok := cond.(*ast.BinaryExpr); ok && bin.Op == token.NEQ {
+		x, ok1 := bin.X.(*ast.Ident)
+		y, ok2 := bin.Y.(*ast.Ident)
+		return ok1 && ok2 && x.Name == err && y.Name == "nil"
+	}
+	return false
+}
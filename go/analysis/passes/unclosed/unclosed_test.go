@@ -0,0 +1,21 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unclosed_test
+
+import (
+	"testing"
+
+	"github.com/TBD54566975/golang-tools/go/analysis/analysistest"
+	"github.com/TBD54566975/golang-tools/go/analysis/passes/unclosed"
+)
+
+func init() {
+	unclosed.Analyzer.Flags.Set("closers", "c.*Conn.Release")
+}
+
+func Test(t *testing.T) {
+	testdata := analysistest.TestData()
+	analysistest.RunWithSuggestedFixes(t, testdata, unclosed.Analyzer, "a", "c")
+}
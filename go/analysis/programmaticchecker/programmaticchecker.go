@@ -2,28 +2,122 @@
 package programmaticchecker
 
 import (
+	"context"
+
 	"github.com/TBD54566975/golang-tools/go/analysis"
 	"github.com/TBD54566975/golang-tools/go/analysis/internal/checker"
 	"github.com/TBD54566975/golang-tools/go/packages"
+	"github.com/TBD54566975/golang-tools/internal/analysisinternal"
 )
 
 // Config specifies the configuration for the programmatic checker.
 type Config struct {
 	// LoadConfig is the packages.Config to use when loading packages.
+	// Its Overlay, if any, is honoured by every file access of the checker,
+	// including Pass.ReadFile and the application of fixes.
 	LoadConfig packages.Config
 	// ReverseImportExecutionOrder is true if packages that import a given package should execute _after_ the package itself.
 	ReverseImportExecutionOrder bool
 	// Patterns specify directory patterns for the package loader.
 	Patterns []string
+	// StopOnLoadErrors is true if no analyzers should run when any loaded package has errors.
+	// Otherwise, only analyzers marked with RunDespiteErrors are applied to packages with errors.
+	// In either case the errors are reported in Result.Errors.
+	StopOnLoadErrors bool
+	// Fix is true if all suggested fixes should be applied to the files on disk.
+	// Fixes to files overlaid by LoadConfig are not written to disk;
+	// the fixed contents are returned in Result.Fixed instead.
+	// Only the first fix of each diagnostic is applied, and fixes that conflict
+	// with others are skipped and reported in Result.SkippedFixes.
+	Fix bool
+	// Diff is true if the fixes that Fix would apply should instead be returned
+	// as unified diffs in Result.Diffs, without modifying any files.
+	Diff bool
+	// UseConfigFiles is true if each package should be analyzed according to the
+	// analysis.json configuration file in its directory or the nearest parent
+	// directory, which may disable analyzers and set their flags for the packages
+	// beneath it, with overrides for particular subdirectories.
+	UseConfigFiles bool
+	// Debug is a set of single-letter debug flags, as for the -debug flag of the checker commands.
+	Debug string
+	// CacheDir, if non-empty, is a directory in which to persist analysis summaries between runs.
+	// Actions whose inputs are unchanged since an earlier run are then skipped and their
+	// diagnostics, facts and (gob-encodable) results restored from the cache.
+	// The directory may be shared by concurrent runs.
+	CacheDir string
+	// OnActionComplete, if non-nil, is called as each (analyzer, package) action completes,
+	// so that results and diagnostics can be consumed while analysis continues.
+	// Calls are serialized. Actions that ran only as prerequisites are reported too,
+	// with IsRoot false.
+	OnActionComplete func(*ActionEvent)
+	// PackageFilters optionally restricts the packages on which each analyzer reports.
+	// An analyzer with a filter is applied only to the matched packages among those
+	// denoted by Patterns, although it still runs on their dependencies as needed
+	// to compute facts. Analyzers without a filter are applied to all packages.
+	PackageFilters map[*analysis.Analyzer]func(*packages.Package) bool
 }
 
-func Run(cfg Config, analyzers ...*analysis.Analyzer) (analyzerResults map[*analysis.Analyzer][]any, diagnostics []analysis.SimpleDiagnostic, err error) {
+// A Result holds the results of a call to Run, grouped by package.
+type Result = checker.Result
+
+// A PackageResult holds the results of applying the analyzers to a single package.
+type PackageResult = checker.PackageResult
+
+// An AnalyzerResult holds the result, diagnostics and facts of applying
+// one analyzer to one package.
+type AnalyzerResult = checker.AnalyzerResult
+
+// A Profile holds timing and memory statistics about a call to Run.
+type Profile = checker.Profile
+
+// An ActionProfile holds statistics about one (analyzer, package) action.
+type ActionProfile = checker.ActionProfile
+
+// A SkippedFix describes a suggested fix that was not applied
+// because it was invalid or conflicted with another fix.
+type SkippedFix = checker.SkippedFix
+
+// An ActionEvent describes the completion of one (analyzer, package) action.
+type ActionEvent = checker.ActionEvent
+
+// IgnoreAnalyzer is the pseudo-analyzer under which Run reports, for
+// each package, problems with its //analysis:ignore directives, such as
+// directives that name unknown analyzers or suppress no diagnostic.
+// Diagnostics suppressed by the directives are omitted from the Result.
+var IgnoreAnalyzer = analysisinternal.IgnoreAnalyzer
+
+// Categories of the diagnostics in Result.Errors.
+const (
+	ListErrorCategory     = checker.ListErrorCategory
+	CompilerErrorCategory = checker.CompilerErrorCategory
+)
+
+// Run loads the packages specified by cfg and applies the analyzers to them.
+// It depends on no global state, so it may be called concurrently.
+//
+// The context is used both to load the packages (in place of
+// cfg.LoadConfig.Context) and to schedule the analysis: once it is
+// cancelled, no further actions are started, and Run returns an error
+// that wraps ctx.Err() and names the phase that was interrupted.
+func Run(ctx context.Context, cfg Config, analyzers ...*analysis.Analyzer) (*Result, error) {
 	if err := analysis.Validate(analyzers); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return checker.RunWithResult(cfg.Patterns, analyzers,
+	opts := []checker.Option{
+		checker.WithContext(ctx),
 		checker.WithLoadConfig(cfg.LoadConfig),
 		checker.WithReverseImportExecutionOrder(cfg.ReverseImportExecutionOrder),
-	)
+		checker.WithStopOnLoadErrors(cfg.StopOnLoadErrors),
+		checker.WithFix(cfg.Fix),
+		checker.WithDiff(cfg.Diff),
+		checker.WithDebug(cfg.Debug),
+		checker.WithConfigFiles(cfg.UseConfigFiles),
+		checker.WithCacheDir(cfg.CacheDir),
+		checker.WithOnActionComplete(cfg.OnActionComplete),
+	}
+	for a, filter := range cfg.PackageFilters {
+		opts = append(opts, checker.WithPackageFilter(a, filter))
+	}
+	return checker.RunWithResult(cfg.Patterns, analyzers, opts...)
 }
@@ -0,0 +1,547 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package programmaticchecker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/programmaticchecker"
+	"github.com/TBD54566975/golang-tools/go/packages"
+	"github.com/TBD54566975/golang-tools/internal/testenv"
+	"github.com/TBD54566975/golang-tools/internal/testfiles"
+	"github.com/TBD54566975/golang-tools/txtar"
+)
+
+const src = `
+-- go.mod --
+module example.com
+
+go 1.19
+
+-- a/a.go --
+package a
+
+func A() {}
+
+-- b/b.go --
+package b
+
+import "example.com/a"
+
+func B() { a.A() }
+`
+
+// setup expands src into a temporary module and returns a Config
+// that loads all of its packages.
+func setup(t *testing.T, src string) programmaticchecker.Config {
+	testenv.NeedsGoPackages(t)
+
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	return programmaticchecker.Config{
+		LoadConfig: packages.Config{Mode: packages.LoadAllSyntax, Dir: dir},
+		Patterns:   []string{"./..."},
+	}
+}
+
+type funcsFact struct{ N int }
+
+func (*funcsFact) AFact()           {}
+func (f *funcsFact) String() string { return fmt.Sprintf("funcs(%d)", f.N) }
+
+type declaredFact struct{}
+
+func (*declaredFact) AFact()         {}
+func (*declaredFact) String() string { return "declared" }
+
+// funcs reports each function declaration, exports a fact for each
+// declared function and a package fact counting them, and returns the
+// names of the functions.
+var funcs = &analysis.Analyzer{
+	Name:       "funcs",
+	Doc:        "reports function declarations",
+	FactTypes:  []analysis.Fact{new(funcsFact), new(declaredFact)},
+	ResultType: reflect.TypeOf([]string(nil)),
+	Run: func(pass *analysis.Pass) (any, error) {
+		var names []string
+		for _, f := range pass.Files {
+			for _, decl := range f.Decls {
+				if decl, ok := decl.(*ast.FuncDecl); ok {
+					names = append(names, decl.Name.Name)
+					pass.ExportObjectFact(pass.TypesInfo.Defs[decl.Name], new(declaredFact))
+					pass.Reportf(decl.Name.Pos(), "func %s", decl.Name.Name)
+				}
+			}
+		}
+		pass.ExportPackageFact(&funcsFact{N: len(names)})
+		return names, nil
+	},
+}
+
+func TestRun(t *testing.T) {
+	cfg := setup(t, src)
+	res, err := programmaticchecker.Run(context.Background(), cfg, funcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		id    string
+		fn    string
+		names []string
+	}{
+		{"example.com/a", "A", []string{"A"}},
+		{"example.com/b", "B", []string{"B"}},
+	} {
+		pr, ok := res.Packages[test.id]
+		if !ok {
+			t.Errorf("no result for package %s", test.id)
+			continue
+		}
+		if pr.Package.ID != test.id {
+			t.Errorf("Packages[%q].Package.ID = %q", test.id, pr.Package.ID)
+		}
+		ar := pr.Analyzers[funcs]
+		if ar == nil {
+			t.Errorf("%s: no result for analyzer %s", test.id, funcs)
+			continue
+		}
+		if ar.Err != nil {
+			t.Errorf("%s: %v", test.id, ar.Err)
+		}
+		if got := ar.Result; !reflect.DeepEqual(got, test.names) {
+			t.Errorf("%s: result = %v, want %v", test.id, got, test.names)
+		}
+		if len(ar.Diagnostics) != 1 || ar.Diagnostics[0].Message != "func "+test.fn {
+			t.Errorf("%s: diagnostics = %v, want [func %s]", test.id, ar.Diagnostics, test.fn)
+		}
+
+		// Only facts about the package itself are reported.
+		var objs []types.Object
+		for obj := range ar.Facts {
+			objs = append(objs, obj)
+		}
+		if len(objs) != 2 {
+			t.Errorf("%s: got facts for %v, want package and %s", test.id, objs, test.fn)
+		}
+		if got := fmt.Sprint(ar.Facts[nil]); got != "[funcs(1)]" {
+			t.Errorf("%s: package facts = %s, want [funcs(1)]", test.id, got)
+		}
+		fn := pr.Package.Types.Scope().Lookup(test.fn)
+		if got := fmt.Sprint(ar.Facts[fn]); got != "[declared]" {
+			t.Errorf("%s: facts for %s = %s, want [declared]", test.id, fn, got)
+		}
+	}
+
+	if got := len(res.Diagnostics()); got != 2 {
+		t.Errorf("Diagnostics() returned %d diagnostics, want 2", got)
+	}
+}
+
+func TestRunSimpleDiagnostic(t *testing.T) {
+	cfg := setup(t, src)
+	cfg.Patterns = []string{"example.com/b"}
+
+	// rename suggests renaming each function to lower case,
+	// and relates the diagnostic to the package clause.
+	rename := &analysis.Analyzer{
+		Name: "rename",
+		Doc:  "suggests lower-case function names",
+		URL:  "https://example.com/rename",
+		Run: func(pass *analysis.Pass) (any, error) {
+			for _, f := range pass.Files {
+				for _, decl := range f.Decls {
+					if decl, ok := decl.(*ast.FuncDecl); ok {
+						id := decl.Name
+						pass.Report(analysis.Diagnostic{
+							Pos:      id.Pos(),
+							End:      id.End(),
+							Category: "case",
+							Message:  "upper-case function name",
+							Related:  []analysis.RelatedInformation{{Pos: f.Name.Pos(), Message: "in this package"}},
+							SuggestedFixes: []analysis.SuggestedFix{{
+								Message:   "Rename",
+								TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte("b")}},
+							}},
+						})
+					}
+				}
+			}
+			return nil, nil
+		},
+	}
+
+	res, err := programmaticchecker.Run(context.Background(), cfg, rename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := res.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1", len(diags))
+	}
+	d := diags[0]
+	if want := "https://example.com/rename#case"; d.URL != want {
+		t.Errorf("URL = %q, want %q", d.URL, want)
+	}
+	if len(d.Related) != 1 || d.Related[0].Pos.Line != 1 || d.Related[0].Message != "in this package" {
+		t.Errorf("Related = %+v, want one entry on line 1", d.Related)
+	}
+	if len(d.SuggestedFixes) != 1 || len(d.SuggestedFixes[0].TextEdits) != 1 {
+		t.Fatalf("SuggestedFixes = %+v, want one fix with one edit", d.SuggestedFixes)
+	}
+	edit := d.SuggestedFixes[0].TextEdits[0]
+	if edit.Filename != d.Pos.Filename || edit.Start != d.Pos.Offset || edit.End != d.End.Offset || string(edit.NewText) != "b" {
+		t.Errorf("TextEdit = %+v, want replacement of %v-%v with %q", edit, d.Pos, d.End, "b")
+	}
+}
+
+func TestRunLoadErrors(t *testing.T) {
+	const src = `
+-- go.mod --
+module example.com
+
+go 1.19
+
+-- a/a.go --
+package a
+
+func A() int { return "" }
+`
+	for _, stop := range []bool{false, true} {
+		cfg := setup(t, src)
+		cfg.StopOnLoadErrors = stop
+		res, err := programmaticchecker.Run(context.Background(), cfg, funcs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Errors) != 1 {
+			t.Fatalf("stop=%t: got errors %+v, want 1", stop, res.Errors)
+		}
+		e := res.Errors[0]
+		if e.Category != programmaticchecker.CompilerErrorCategory || e.Pos.Line != 3 || e.Pos.Offset == 0 {
+			t.Errorf("stop=%t: got error %+v, want compiler error at line 3", stop, e)
+		}
+		if got := res.Diagnostics(); len(got) != 1 {
+			t.Errorf("stop=%t: Diagnostics() = %+v, want just the load error", stop, got)
+		}
+
+		pr := res.Packages["example.com/a"]
+		if pr == nil {
+			t.Fatalf("stop=%t: no result for package", stop)
+		}
+		ar := pr.Analyzers[funcs]
+		if stop {
+			if ar != nil {
+				t.Errorf("stop=%t: analyzer ran despite load errors", stop)
+			}
+		} else if ar == nil || ar.Err == nil {
+			t.Errorf("stop=%t: analyzer without RunDespiteErrors was not skipped", stop)
+		}
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	for _, debug := range []string{"", "p", "s", "ps"} {
+		cfg := setup(t, src)
+		cfg.Debug = debug
+		wg.Add(1)
+		go func(cfg programmaticchecker.Config) {
+			defer wg.Done()
+			res, err := programmaticchecker.Run(context.Background(), cfg, funcs)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := len(res.Diagnostics()); got != 2 {
+				t.Errorf("debug=%q: got %d diagnostics, want 2", cfg.Debug, got)
+			}
+		}(cfg)
+	}
+	wg.Wait()
+}
+
+func TestRunCache(t *testing.T) {
+	cfg := setup(t, src)
+	cfg.CacheDir = t.TempDir()
+
+	// counting wraps funcs, recording the packages it is run on.
+	var ran []string
+	counting := *funcs
+	counting.Run = func(pass *analysis.Pass) (any, error) {
+		ran = append(ran, pass.Pkg.Path())
+		return funcs.Run(pass)
+	}
+	cfg.Debug = "p" // sequential, for ran
+
+	run := func(wantRan ...string) *programmaticchecker.Result {
+		t.Helper()
+		ran = nil
+		res, err := programmaticchecker.Run(context.Background(), cfg, &counting)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(ran)
+		if !reflect.DeepEqual(ran, wantRan) {
+			t.Errorf("analyzer ran on %v, want %v", ran, wantRan)
+		}
+		return res
+	}
+
+	first := run("example.com/a", "example.com/b")
+	second := run() // everything is cached
+
+	for id, pr := range second.Packages {
+		got, want := pr.Analyzers[&counting], first.Packages[id].Analyzers[&counting]
+		if !got.Cached {
+			t.Errorf("%s: result not restored from cache", id)
+		}
+		if !reflect.DeepEqual(got.Result, want.Result) {
+			t.Errorf("%s: cached result = %v, want %v", id, got.Result, want.Result)
+		}
+		if !reflect.DeepEqual(got.Diagnostics, want.Diagnostics) {
+			t.Errorf("%s: cached diagnostics = %v, want %v", id, got.Diagnostics, want.Diagnostics)
+		}
+		if fmt.Sprint(got.Facts[nil]) != fmt.Sprint(want.Facts[nil]) || len(got.Facts) != len(want.Facts) {
+			t.Errorf("%s: cached facts = %v, want %v", id, got.Facts, want.Facts)
+		}
+	}
+
+	// Changing b invalidates only b.
+	filename := filepath.Join(cfg.LoadConfig.Dir, "b/b.go")
+	if err := os.WriteFile(filename, []byte("package b\n\nfunc B2() {}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	third := run("example.com/b")
+	if got := third.Packages["example.com/b"].Analyzers[&counting].Result; !reflect.DeepEqual(got, []string{"B2"}) {
+		t.Errorf("result after change = %v, want [B2]", got)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	cfg := setup(t, src)
+	cfg.Debug = "p" // sequential
+
+	// cancel cancels the run from within the analysis of the first package.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var ran []string
+	cancelling := *funcs
+	cancelling.Run = func(pass *analysis.Pass) (any, error) {
+		ran = append(ran, pass.Pkg.Path())
+		cancel()
+		return funcs.Run(pass)
+	}
+
+	_, err := programmaticchecker.Run(ctx, cfg, &cancelling)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v, want context.Canceled", err)
+	}
+	if want := "analysis interrupted"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+	if len(ran) != 1 {
+		t.Errorf("analyzer ran on %v after cancellation", ran)
+	}
+
+	// A run that is cancelled before it starts never gets past loading.
+	_, err = programmaticchecker.Run(ctx, cfg, funcs)
+	if want := "loading packages interrupted"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Run returned %v, want error containing %q", err, want)
+	}
+}
+
+func TestRunOnActionComplete(t *testing.T) {
+	cfg := setup(t, src)
+	cfg.Patterns = []string{"example.com/b"}
+
+	var events []string
+	cfg.OnActionComplete = func(e *programmaticchecker.ActionEvent) {
+		if e.Err != nil {
+			t.Errorf("%s@%s: %v", e.Analyzer, e.Package, e.Err)
+		}
+		events = append(events, fmt.Sprintf("%s@%s root=%t diags=%d result=%v",
+			e.Analyzer, e.Package.PkgPath, e.IsRoot, len(e.Diagnostics), e.Result))
+	}
+	if _, err := programmaticchecker.Run(context.Background(), cfg, funcs); err != nil {
+		t.Fatal(err)
+	}
+
+	// a is analyzed first, as a prerequisite of b.
+	want := []string{
+		"funcs@example.com/a root=false diags=1 result=[A]",
+		"funcs@example.com/b root=true diags=1 result=[B]",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunOverlay(t *testing.T) {
+	cfg := setup(t, src)
+	cfg.Patterns = []string{"example.com/b"}
+	filename := filepath.Join(cfg.LoadConfig.Dir, "b/b.go")
+	const buffer = "package b\n\nfunc Unsaved() {}\n"
+	cfg.LoadConfig.Overlay = map[string][]byte{filename: []byte(buffer)}
+
+	// readfile checks that Pass.ReadFile sees the overlay,
+	// and suggests a fix that renames each function.
+	readfile := &analysis.Analyzer{
+		Name: "readfile",
+		Doc:  "reads files",
+		Run: func(pass *analysis.Pass) (any, error) {
+			for _, f := range pass.Files {
+				name := pass.Fset.File(f.Pos()).Name()
+				content, err := pass.ReadFile(name)
+				if err != nil {
+					return nil, err
+				}
+				if name == filename && string(content) != buffer {
+					t.Errorf("ReadFile(%s) = %q, want overlay %q", name, content, buffer)
+				}
+				for _, decl := range f.Decls {
+					if decl, ok := decl.(*ast.FuncDecl); ok {
+						id := decl.Name
+						pass.Report(analysis.Diagnostic{
+							Pos:     id.Pos(),
+							Message: "func " + id.Name,
+							SuggestedFixes: []analysis.SuggestedFix{{
+								TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte("Saved")}},
+							}},
+						})
+					}
+				}
+			}
+			return nil, nil
+		},
+	}
+
+	onDisk, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Fix = true
+	res, err := programmaticchecker.Run(context.Background(), cfg, readfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags := res.Diagnostics(); len(diags) != 1 || diags[0].Message != "func Unsaved" {
+		t.Errorf("Diagnostics() = %+v, want [func Unsaved]", diags)
+	}
+
+	// The fix applies to the overlay, and leaves the file on disk untouched.
+	if got, want := string(res.Fixed[filename]), "package b\n\nfunc Saved() {}\n"; got != want {
+		t.Errorf("Fixed[%s] = %q, want %q", filename, got, want)
+	}
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(onDisk) {
+		t.Errorf("file on disk = %q, want unchanged %q", got, onDisk)
+	}
+}
+
+func TestRunProfile(t *testing.T) {
+	cfg := setup(t, src)
+	res, err := programmaticchecker.Run(context.Background(), cfg, funcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prof := res.Profile
+	if prof.LoadTime <= 0 || prof.AnalysisTime <= 0 {
+		t.Errorf("Profile = %+v, want positive load and analysis times", prof)
+	}
+	var got []string
+	for _, ap := range prof.Actions {
+		got = append(got, fmt.Sprintf("%s@%s facts=%d", ap.Analyzer, ap.Package.PkgPath, ap.FactsExported))
+		if ap.Duration <= 0 {
+			t.Errorf("%s@%s: non-positive duration %v", ap.Analyzer, ap.Package, ap.Duration)
+		}
+		// b inherits the facts of a.
+		if ap.Package.PkgPath == "example.com/b" && ap.FactTime <= 0 {
+			t.Errorf("%s@%s: non-positive fact time %v", ap.Analyzer, ap.Package, ap.FactTime)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"funcs@example.com/a facts=2", "funcs@example.com/b facts=2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Actions = %v, want %v", got, want)
+	}
+	if total := prof.ByAnalyzer()[funcs]; total < prof.Actions[0].Duration {
+		t.Errorf("ByAnalyzer()[funcs] = %v, less than the duration of one action", total)
+	}
+}
+
+func TestRunPackageFilters(t *testing.T) {
+	cfg := setup(t, src)
+
+	// names reports each function name, without using facts.
+	names := &analysis.Analyzer{
+		Name: "names",
+		Doc:  "reports function names",
+		Run: func(pass *analysis.Pass) (any, error) {
+			for _, f := range pass.Files {
+				for _, decl := range f.Decls {
+					if decl, ok := decl.(*ast.FuncDecl); ok {
+						pass.Reportf(decl.Name.Pos(), "name %s", decl.Name.Name)
+					}
+				}
+			}
+			return nil, nil
+		},
+	}
+
+	// funcs runs only on b, though it still needs facts from a.
+	onlyB := func(pkg *packages.Package) bool { return pkg.PkgPath == "example.com/b" }
+	cfg.PackageFilters = map[*analysis.Analyzer]func(*packages.Package) bool{funcs: onlyB}
+	var ran []string
+	cfg.OnActionComplete = func(e *programmaticchecker.ActionEvent) {
+		ran = append(ran, fmt.Sprintf("%s@%s root=%t", e.Analyzer, e.Package.PkgPath, e.IsRoot))
+	}
+
+	res, err := programmaticchecker.Run(context.Background(), cfg, funcs, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range res.Diagnostics() {
+		got = append(got, d.Message)
+	}
+	want := []string{"name A", "func B", "name B"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diagnostics() = %v, want %v", got, want)
+	}
+	if res.Packages["example.com/a"].Analyzers[funcs] != nil {
+		t.Errorf("funcs reported a result for filtered-out package a")
+	}
+
+	sort.Strings(ran)
+	wantRan := []string{
+		"funcs@example.com/a root=false",
+		"funcs@example.com/b root=true",
+		"names@example.com/a root=true",
+		"names@example.com/b root=true",
+	}
+	if !reflect.DeepEqual(ran, wantRan) {
+		t.Errorf("actions = %v, want %v", ran, wantRan)
+	}
+}
@@ -14,10 +14,19 @@
 //	-flags          describe flags                    (to the build tool)
 //	foo.cfg         description of compilation unit (from the build tool)
 //
+// Under "go vet -sarif", each compilation unit yields a separate SARIF
+// log. To combine them into a single log, pipe the output of go vet
+// into the tool's -mergesarif mode:
+//
+//	$ go vet -vettool=$(which vet) -sarif ./... 2>&1 | vet -mergesarif
+//
 // This package does not depend on go/packages.
 // If you need a standalone tool, use multichecker,
 // which supports this mode but can also load packages
 // from source using go/packages.
+//
+// Since each compilation unit is analyzed separately, whole-program
+// analyzers (those with a Finish function) are not supported.
 package unitchecker
 
 // TODO(adonovan):
@@ -60,8 +69,10 @@ import (
 type Config struct {
 	ID                        string // e.g. "fmt [fmt.test]"
 	Compiler                  string // gc or gccgo, provided to MakeImporter
-	Dir                       string // (unused)
+	Dir                       string // package directory; see analysisinternal.FindConfig
 	ImportPath                string // package path
+	ModulePath                string // module path, if any
+	ModuleVersion             string // module version ("" for a main module)
 	GoVersion                 string // minimum required Go version, such as "go1.21.0"
 	GoFiles                   []string
 	NonGoFiles                []string
@@ -137,7 +148,14 @@ func Run(configFile string, analyzers []*analysis.Analyzer) {
 
 	// In VetxOnly mode, the analysis is run only for facts.
 	if !cfg.VetxOnly {
-		if analysisflags.JSON {
+		if analysisflags.SARIF {
+			// SARIF output: one log per package; see -mergesarif.
+			sarif := analysisflags.NewSARIFLog(filepath.Base(os.Args[0]))
+			for _, res := range results {
+				sarif.Add(fset, cfg.ID, res.a, res.diagnostics, res.err)
+			}
+			sarif.Print()
+		} else if analysisflags.JSON {
 			// JSON output
 			tree := make(analysisflags.JSONTree)
 			for _, res := range results {
@@ -235,6 +253,14 @@ var (
 )
 
 func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]result, error) {
+	// Whole-program analyzers need all packages at once,
+	// but the build system gives us one at a time.
+	for _, a := range analyzers {
+		if a.Finish != nil {
+			return nil, fmt.Errorf("analyzer %s is a whole-program analyzer (it has a Finish function), which unitchecker does not support", a)
+		}
+	}
+
 	// Load, parse, typecheck.
 	var files []*ast.File
 	for _, name := range cfg.GoFiles {
@@ -320,6 +346,21 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 	}
 	analyzers = filtered
 
+	// Apply the analysis configuration file, if any.
+	// (This process analyzes a single package,
+	// so its flags need not be restored.)
+	settings, err := configSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for a := range actions {
+		if _, err := analysisflags.SetFlags(a, settings.Flags[a.Name]); err != nil {
+			return nil, err
+		}
+	}
+
+	module := readModule(cfg)
+
 	// Read facts from imported packages.
 	facts, err := facts.NewDecoder(pkg).Decode(makeFactImporter(cfg))
 	if err != nil {
@@ -360,23 +401,26 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 			}
 
 			pass := &analysis.Pass{
-				Analyzer:          a,
-				Fset:              fset,
-				Files:             files,
-				OtherFiles:        cfg.NonGoFiles,
-				IgnoredFiles:      cfg.IgnoredFiles,
-				Pkg:               pkg,
-				TypesInfo:         info,
-				TypesSizes:        tc.Sizes,
-				TypeErrors:        nil, // unitchecker doesn't RunDespiteErrors
-				ResultOf:          inputs,
-				Report:            func(d analysis.Diagnostic) { act.diagnostics = append(act.diagnostics, d) },
-				ImportObjectFact:  facts.ImportObjectFact,
-				ExportObjectFact:  facts.ExportObjectFact,
-				AllObjectFacts:    func() []analysis.ObjectFact { return facts.AllObjectFacts(factFilter) },
-				ImportPackageFact: facts.ImportPackageFact,
-				ExportPackageFact: facts.ExportPackageFact,
-				AllPackageFacts:   func() []analysis.PackageFact { return facts.AllPackageFacts(factFilter) },
+				Analyzer:             a,
+				Fset:                 fset,
+				Files:                files,
+				OtherFiles:           cfg.NonGoFiles,
+				IgnoredFiles:         cfg.IgnoredFiles,
+				Pkg:                  pkg,
+				TypesInfo:            info,
+				TypesSizes:           tc.Sizes,
+				TypeErrors:           nil, // unitchecker doesn't RunDespiteErrors
+				Module:               module,
+				ResultOf:             inputs,
+				Report:               func(d analysis.Diagnostic) { act.diagnostics = append(act.diagnostics, d) },
+				ImportObjectFact:     facts.ImportObjectFact,
+				ExportObjectFact:     facts.ExportObjectFact,
+				AllObjectFacts:       func() []analysis.ObjectFact { return facts.AllObjectFacts(factFilter) },
+				ImportPackageFact:    facts.ImportPackageFact,
+				ExportPackageFact:    facts.ExportPackageFact,
+				AllPackageFacts:      func() []analysis.PackageFact { return facts.AllPackageFacts(factFilter) },
+				ContributeObjectFact: facts.ContributeObjectFact,
+				AllContributedFacts:  func() []analysis.ContributedFact { return facts.AllContributedFacts(factFilter) },
 			}
 			pass.ReadFile = analysisinternal.MakeReadFile(pass)
 
@@ -412,13 +456,18 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 
 	execAll(analyzers)
 
-	// Return diagnostics and errors from root analyzers.
-	results := make([]result, len(analyzers))
-	for i, a := range analyzers {
+	// Return diagnostics and errors from root analyzers,
+	// except those disabled by the configuration.
+	var results []result
+	for _, a := range analyzers {
+		if !settings.Enabled(a.Name) {
+			continue
+		}
 		act := actions[a]
-		results[i].a = a
-		results[i].err = act.err
-		results[i].diagnostics = act.diagnostics
+		results = append(results, result{a: a, err: act.err, diagnostics: act.diagnostics})
+	}
+	if !cfg.VetxOnly {
+		results = applyIgnoreDirectives(fset, files, results)
 	}
 
 	data := facts.Encode()
@@ -432,6 +481,75 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 	return results, nil
 }
 
+// readModule returns the analysis.Module of the package's module, or
+// nil if it does not belong to one.
+func readModule(cfg *Config) *analysis.Module {
+	if cfg.ModulePath == "" {
+		return nil
+	}
+	module := &analysis.Module{
+		Path:      cfg.ModulePath,
+		Version:   cfg.ModuleVersion,
+		GoVersion: analysisinternal.GoVersion(cfg.GoVersion),
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Dir(cfg.GoFiles[0])
+	}
+	if gomod := analysisinternal.FindGoMod(dir, cfg.ModulePath); gomod != "" {
+		var gowork string
+		if cfg.ModuleVersion == "" {
+			gowork = analysisinternal.GoWorkFile(filepath.Dir(gomod), os.Getenv("GOWORK"), os.ReadFile)
+		}
+		// An unreadable go.mod file leaves Requires and Replaces nil.
+		_ = analysisinternal.ReadModFiles(module, gomod, gowork, os.ReadFile)
+	}
+	return module
+}
+
+// configSettings returns the settings of the analysis configuration
+// file that applies to the package, if any.
+func configSettings(cfg *Config) (*analysisinternal.ConfigSettings, error) {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Dir(cfg.GoFiles[0])
+	}
+	config, err := analysisinternal.FindConfig(dir, os.ReadFile)
+	if err != nil || config == nil {
+		return new(analysisinternal.ConfigSettings), err
+	}
+	return config.Resolve(dir), nil
+}
+
+// applyIgnoreDirectives removes the diagnostics suppressed by
+// //analysis:ignore directives in files, and appends a result for
+// analysisinternal.IgnoreAnalyzer describing any problems with them.
+func applyIgnoreDirectives(fset *token.FileSet, files []*ast.File, results []result) []result {
+	dirs := analysisinternal.ParseIgnoreDirectives(fset, files)
+	ran := make(map[string]bool)
+	for i, res := range results {
+		if res.err == nil {
+			ran[res.a.Name] = true
+			results[i].diagnostics = dirs.Filter(res.a.Name, res.diagnostics)
+		}
+	}
+	known := func(name string) bool {
+		if analysisflags.KnownAnalyzers[name] {
+			return true
+		}
+		for _, res := range results {
+			if res.a.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	if problems := dirs.Problems(known, func(name string) bool { return ran[name] }); len(problems) > 0 {
+		results = append(results, result{a: analysisinternal.IgnoreAnalyzer, diagnostics: problems})
+	}
+	return results
+}
+
 type result struct {
 	a           *analysis.Analyzer
 	diagnostics []analysis.Diagnostic
@@ -5,19 +5,29 @@
 package unitchecker_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/go/analysis/internal/analysisflags"
 	"github.com/TBD54566975/golang-tools/go/analysis/passes/assign"
 	"github.com/TBD54566975/golang-tools/go/analysis/passes/findcall"
 	"github.com/TBD54566975/golang-tools/go/analysis/passes/printf"
 	"github.com/TBD54566975/golang-tools/go/analysis/unitchecker"
 	"github.com/TBD54566975/golang-tools/go/packages/packagestest"
+	"github.com/TBD54566975/golang-tools/internal/testfiles"
+	"github.com/TBD54566975/golang-tools/txtar"
 )
 
 func TestMain(m *testing.M) {
@@ -29,6 +39,15 @@ func TestMain(m *testing.M) {
 	case "minivet":
 		minivet()
 		panic("unreachable")
+	case "unitvet":
+		unitvet()
+		panic("unreachable")
+	case "modulevet":
+		modulevet()
+		panic("unreachable")
+	case "finishvet":
+		finishvet()
+		panic("unreachable")
 	case "worker":
 		worker() // see ExampleSeparateAnalysis
 		panic("unreachable")
@@ -196,3 +215,239 @@ func _() {
 		}
 	}
 }
+
+// unitvet is a vet-like tool run directly on a single unit by runUnit.
+func unitvet() {
+	unitchecker.Main(findcall.Analyzer)
+}
+
+// runUnit runs the vet-like tool of the child process named by
+// entrypoint on package p of module example.com, whose files are
+// extracted from the txtar archive src, much as "go vet" would run it.
+// It returns the tool's standard output, its standard error with the
+// name of the extraction directory removed, and its exit code.
+func runUnit(t *testing.T, entrypoint, src string, args ...string) (stdout, stderr string, exitcode int) {
+	t.Helper()
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skipf("skipping fork/exec test on this platform")
+	}
+
+	dir := t.TempDir()
+	if err := testfiles.ExtractTxtar(dir, txtar.Parse([]byte(src))); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "p", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(&unitchecker.Config{
+		ID:         "example.com/p",
+		Compiler:   "gc",
+		Dir:        filepath.Join(dir, "p"),
+		ImportPath: "example.com/p",
+		ModulePath: "example.com",
+		GoVersion:  "go1.21",
+		GoFiles:    files,
+		VetxOutput: filepath.Join(dir, "p.vetx"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgFile := filepath.Join(dir, "p.cfg")
+	if err := os.WriteFile(cfgFile, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], append(args, cfgFile)...)
+	cmd.Env = append(os.Environ(), "ENTRYPOINT="+entrypoint)
+	var outbuf, errbuf bytes.Buffer
+	cmd.Stdout = &outbuf
+	cmd.Stderr = &errbuf
+	if err := cmd.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatal(err)
+		}
+		exitcode = exitErr.ExitCode()
+	}
+	stderr = strings.ReplaceAll(errbuf.String(), dir+string(filepath.Separator), "")
+	return outbuf.String(), stderr, exitcode
+}
+
+// TestSARIF tests the -sarif output of a unit.
+func TestSARIF(t *testing.T) {
+	const src = `
+-- p/p.go --
+package p
+
+func _() {
+	MyFunc123()
+}
+
+func MyFunc123() {}
+`
+	stdout, stderr, exitcode := runUnit(t, "unitvet", src, "-sarif", "-findcall.name=MyFunc123")
+	if exitcode != 0 {
+		t.Fatalf("got exit code %d, want 0; stderr:\n%s", exitcode, stderr)
+	}
+	var sarif analysisflags.SARIFLog
+	if err := json.Unmarshal([]byte(stdout), &sarif); err != nil {
+		t.Fatalf("cannot decode SARIF output: %v\n%s", err, stdout)
+	}
+	if len(sarif.Runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(sarif.Runs))
+	}
+	run := sarif.Runs[0]
+	if inv := run.Invocations; len(inv) != 1 || !inv[0].ExecutionSuccessful {
+		t.Errorf("got invocations %+v, want one successful one", inv)
+	}
+	var got []string
+	for _, res := range run.Results {
+		loc := res.Locations[0].PhysicalLocation
+		got = append(got, fmt.Sprintf("%s: %s:%d: %s",
+			res.RuleID, path.Base(loc.ArtifactLocation.URI), loc.Region.StartLine, res.Message.Text))
+	}
+	want := []string{"findcall: p.go:4: call of MyFunc123(...)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got results %q, want %q", got, want)
+	}
+}
+
+// stderrLines returns the lines of the standard error of runUnit.
+func stderrLines(stderr string) []string {
+	return strings.Split(strings.TrimSuffix(stderr, "\n"), "\n")
+}
+
+// TestIgnoreDirectives tests that //analysis:ignore directives
+// suppress diagnostics, and that problems with them are reported.
+func TestIgnoreDirectives(t *testing.T) {
+	const src = `
+-- p/p.go --
+package p
+
+func _() {
+	MyFunc123()
+	MyFunc123() //analysis:ignore findcall this call is fine
+}
+
+//analysis:ignore findcall,nosuch no reason to report
+func _() {
+	MyFunc123()
+}
+
+func MyFunc123() {}
+`
+	_, stderr, exitcode := runUnit(t, "unitvet", src, "-findcall.name=MyFunc123")
+	if exitcode != 1 {
+		t.Errorf("got exit code %d, want 1", exitcode)
+	}
+	want := []string{
+		"p/p.go:4:11: call of MyFunc123(...)",
+		`p/p.go:8:1: unknown analyzer "nosuch" in //analysis:ignore directive`,
+	}
+	if got := stderrLines(stderr); !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics %q, want %q", got, want)
+	}
+}
+
+// TestConfigFile tests that analyzer flags are set by the analysis.json
+// file of the unit, unless they are set on the command line.
+func TestConfigFile(t *testing.T) {
+	const src = `
+-- analysis.json --
+{"settings": {"findcall": {"name": "MyFunc123"}}}
+
+-- p/p.go --
+package p
+
+func _() {
+	MyFunc123()
+	F()
+}
+
+func MyFunc123() {}
+
+func F() {}
+`
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "p/p.go:4:11: call of MyFunc123(...)"},
+		{[]string{"-findcall.name=F"}, "p/p.go:5:3: call of F(...)"},
+	} {
+		_, stderr, _ := runUnit(t, "unitvet", src, test.args...)
+		if got := stderrLines(stderr); !reflect.DeepEqual(got, []string{test.want}) {
+			t.Errorf("%s: got diagnostics %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+// modulevet is a vet-like tool that reports the module of each package.
+func modulevet() {
+	unitchecker.Main(&analysis.Analyzer{
+		Name: "module",
+		Doc:  "reports the module of each package",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			m := pass.Module
+			msg := fmt.Sprintf("module %s, %s", m.Path, m.GoVersion)
+			for _, r := range m.Requires {
+				msg += fmt.Sprintf(", requires %s@%s", r.Path, r.Version)
+			}
+			for _, r := range m.Replaces {
+				msg += fmt.Sprintf(", replaces %s with %s", r.OldPath, r.NewPath)
+			}
+			pass.Reportf(pass.Files[0].Name.Pos(), "%s", msg)
+			return nil, nil
+		},
+	})
+}
+
+// TestPassModule tests that Pass.Module describes the module of the
+// unit, including the requirements and replacements of its go.mod file.
+func TestPassModule(t *testing.T) {
+	const src = `
+-- go.mod --
+module example.com
+
+go 1.21
+
+require example.net/a v1.0.0
+
+replace example.net/a => ./a
+
+-- p/p.go --
+package p
+`
+	_, stderr, _ := runUnit(t, "modulevet", src)
+	want := []string{"p/p.go:1:9: module example.com, go1.21, requires example.net/a@v1.0.0, replaces example.net/a with ./a"}
+	if got := stderrLines(stderr); !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics %q, want %q", got, want)
+	}
+}
+
+// finishvet is a vet-like tool with a whole-program analyzer.
+func finishvet() {
+	unitchecker.Main(&analysis.Analyzer{
+		Name:   "finish",
+		Doc:    "does nothing, with the whole program",
+		Run:    func(pass *analysis.Pass) (interface{}, error) { return nil, nil },
+		Finish: func(pass *analysis.FinishPass) error { return nil },
+	})
+}
+
+// TestFinish tests that unitchecker, which sees one package at a time,
+// rejects whole-program analyzers.
+func TestFinish(t *testing.T) {
+	const src = `
+-- p/p.go --
+package p
+`
+	_, stderr, exitcode := runUnit(t, "finishvet", src)
+	if exitcode != 1 {
+		t.Errorf("got exit code %d, want 1", exitcode)
+	}
+	if want := "analyzer finish is a whole-program analyzer"; !strings.Contains(stderr, want) {
+		t.Errorf("got error %q, want one containing %q", stderr, want)
+	}
+}
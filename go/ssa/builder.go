@@ -1231,30 +1231,38 @@ func (b *builder) compLit(fn *Function, addr Value, e *ast.CompositeLit, isZero
 	typ := typeparams.Deref(fn.typeOf(e)) // retain the named/alias/param type, if any
 	switch t := typeparams.CoreType(typ).(type) {
 	case *types.Struct:
-		if !isZero && len(e.Elts) != t.NumFields() {
+		// Keys may name promoted fields (go1.27), which are
+		// selected through the embedded fields on their path.
+		paths := make([][]int, len(e.Elts))
+		promoted := false
+		for i, e := range e.Elts {
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				field := fn.objectOf(kv.Key.(*ast.Ident))
+				_, index, _ := types.LookupFieldOrMethod(t, false, field.Pkg(), field.Name())
+				paths[i] = index
+				promoted = promoted || len(index) > 1
+			} else {
+				paths[i] = []int{i}
+			}
+		}
+		if !isZero && (len(e.Elts) != t.NumFields() || promoted) {
 			// memclear
 			zt := typeparams.MustDeref(addr.Type())
 			sb.store(&address{addr, e.Lbrace, nil}, zeroConst(zt))
 			isZero = true
 		}
 		for i, e := range e.Elts {
-			fieldIndex := i
 			pos := e.Pos()
 			if kv, ok := e.(*ast.KeyValueExpr); ok {
-				fname := kv.Key.(*ast.Ident).Name
-				for i, n := 0, t.NumFields(); i < n; i++ {
-					sf := t.Field(i)
-					if sf.Name() == fname {
-						fieldIndex = i
-						pos = kv.Colon
-						e = kv.Value
-						break
-					}
-				}
+				pos = kv.Colon
+				e = kv.Value
 			}
-			sf := t.Field(fieldIndex)
+			path := paths[i]
+			x := emitImplicitSelections(fn, addr, path[:len(path)-1], pos)
+			fieldIndex := path[len(path)-1]
+			sf := fieldOf(typeparams.MustDeref(x.Type()), fieldIndex)
 			faddr := &FieldAddr{
-				X:     addr,
+				X:     x,
 				Field: fieldIndex,
 			}
 			faddr.setPos(pos)
@@ -1191,6 +1191,7 @@ func TestFixedBugs(t *testing.T) {
 	for _, name := range []string{
 		"issue66783a",
 		"issue66783b",
+		"promotedkeys",
 	} {
 
 		t.Run(name, func(t *testing.T) {
@@ -0,0 +1,38 @@
+//go:build ignore
+// +build ignore
+
+package promotedkeys
+
+// Composite literal keys may name promoted fields.
+
+type Point struct{ X, Y int }
+
+type Named struct {
+	Point
+	Name string
+}
+
+type Deep struct {
+	Named
+}
+
+func F() Named {
+	return Named{X: 1, Y: 2, Name: "p"}
+}
+
+func G() *Deep {
+	return &Deep{X: 1, Name: "q"}
+}
+
+type Pair[T any] struct {
+	Box[T]
+	Second T
+}
+
+type Box[T any] struct{ First T }
+
+func H[T any](x, y T) Pair[T] {
+	return Pair[T]{First: x, Second: y}
+}
+
+var _ = H[int]
@@ -191,12 +191,40 @@ func (s *Snapshot) Analyze(ctx context.Context, pkgs map[PackageID]*metadata.Pac
 	ctx, done := event.Start(ctx, "snapshot.Analyze", label.Package.Of(tagStr))
 	defer done()
 
-	// Filter and sort enabled root analyzers.
-	// A disabled analyzer may still be run if required by another.
+	// Analysis configuration files (see analysisinternal.Config) may
+	// enable or disable analyzers for each root package, but the
+	// "analyses" setting takes precedence. (The flag settings of the
+	// files are not applied, as analyzer flags are shared by all the
+	// concurrent actions of gopls; see analysisinternal.Config.)
+	configs, configFiles := analysisConfigs(ctx, s, pkgs)
+	analyses := s.Options().Analyses
+	enabledFor := func(id PackageID, a *settings.Analyzer) bool {
+		name := a.Analyzer().Name
+		if enabled, ok := analyses[name]; ok {
+			return enabled
+		}
+		if config := configs[id]; config != nil {
+			if enabled, ok := config.Analyzers[name]; ok {
+				return enabled
+			}
+		}
+		return a.EnabledByDefault()
+	}
+
+	// Filter and sort enabled root analyzers: those enabled for
+	// any root package. A disabled analyzer may still be run if
+	// required by another.
 	toSrc := make(map[*analysis.Analyzer]*settings.Analyzer)
 	var enabledAnalyzers []*analysis.Analyzer // enabled subset + transitive requirements
 	for _, a := range analyzers {
-		if enabled, ok := s.Options().Analyses[a.Analyzer().Name]; enabled || !ok && a.EnabledByDefault() {
+		enabled := false
+		for id := range pkgs {
+			if enabledFor(id, a) {
+				enabled = true
+				break
+			}
+		}
+		if enabled {
 			toSrc[a.Analyzer()] = a
 			enabledAnalyzers = append(enabledAnalyzers, a.Analyzer())
 		}
@@ -204,6 +232,17 @@ func (s *Snapshot) Analyze(ctx context.Context, pkgs map[PackageID]*metadata.Pac
 	sort.Slice(enabledAnalyzers, func(i, j int) bool {
 		return enabledAnalyzers[i].Name < enabledAnalyzers[j].Name
 	})
+	// Directives may name any analyzer known to gopls,
+	// whether or not it is enabled.
+	knownAnalyzers := make(map[string]bool)
+	for _, m := range []map[string]*settings.Analyzer{settings.DefaultAnalyzers, settings.StaticcheckAnalyzers} {
+		for name := range m {
+			knownAnalyzers[name] = true
+		}
+	}
+	for _, a := range analyzers {
+		knownAnalyzers[a.Analyzer().Name] = true
+	}
 	analyzers = nil // prevent accidental use
 
 	enabledAnalyzers = requiredAnalyzers(enabledAnalyzers)
@@ -263,6 +302,7 @@ func (s *Snapshot) Analyze(ctx context.Context, pkgs map[PackageID]*metadata.Pac
 				allDeps:     make(map[PackagePath]*analysisNode),
 				exportDeps:  make(map[PackagePath]*analysisNode),
 				stableNames: stableNames,
+				known:       knownAnalyzers,
 			}
 			nodes[id] = an
 
@@ -304,6 +344,23 @@ func (s *Snapshot) Analyze(ctx context.Context, pkgs map[PackageID]*metadata.Pac
 				}
 				an.files[i] = fh
 			}
+
+			// Load the go.mod file of the package's module, and
+			// the go.work file of a workspace module, for Pass.Module.
+			if mod := mp.Module; mod != nil && mod.GoMod != "" {
+				fh, err := s.ReadFile(ctx, protocol.URIFromPath(mod.GoMod))
+				if err != nil {
+					return nil, err
+				}
+				an.modFiles = append(an.modFiles, fh)
+				if gowork := s.View().GoWork(); mod.Main && s.View().Type() == GoWorkView && gowork != "" {
+					fh, err := s.ReadFile(ctx, gowork)
+					if err != nil {
+						return nil, err
+					}
+					an.modFiles = append(an.modFiles, fh)
+				}
+			}
 		}
 		// Add edge from predecessor.
 		if from != nil {
@@ -314,6 +371,15 @@ func (s *Snapshot) Analyze(ctx context.Context, pkgs map[PackageID]*metadata.Pac
 		return an, nil
 	}
 
+	// Whole-program analyzers are those enabled
+	// analyzers that have a Finish function.
+	var finishers []*analysis.Analyzer
+	for _, a := range enabledAnalyzers {
+		if a.Finish != nil && toSrc[a] != nil {
+			finishers = append(finishers, a)
+		}
+	}
+
 	// For root packages, we run the enabled set of analyzers.
 	var roots []*analysisNode
 	for id := range pkgs {
@@ -322,6 +388,8 @@ func (s *Snapshot) Analyze(ctx context.Context, pkgs map[PackageID]*metadata.Pac
 			return nil, err
 		}
 		root.analyzers = enabledAnalyzers
+		root.finishers = finishers
+		root.configFile = configFiles[id]
 		roots = append(roots, root)
 	}
 
@@ -454,6 +522,43 @@ func (s *Snapshot) Analyze(ctx context.Context, pkgs map[PackageID]*metadata.Pac
 	// Even if current callers choose to discard the
 	// results, we should propagate the per-action errors.
 	var results []*Diagnostic
+
+	// Call the Finish function of each whole-program analyzer.
+	// As with action errors, errors from Finish are ignored,
+	// but they are logged.
+	if len(finishers) > 0 {
+		sort.Slice(roots, func(i, j int) bool { return roots[i].mp.ID < roots[j].mp.ID })
+		for _, a := range finishers {
+			srcAnalyzer := toSrc[a]
+			var finishing []*finishingPackage
+			for _, root := range roots {
+				if root.finishing != nil && enabledFor(root.mp.ID, srcAnalyzer) {
+					finishing = append(finishing, root.finishing)
+				}
+			}
+			diagnostics, err := finish(ctx, a, finishing)
+			if err != nil {
+				event.Error(ctx, fmt.Sprintf("Finish function of analyzer %q failed", a.Name), err)
+				continue
+			}
+			for _, gobDiag := range diagnostics {
+				results = append(results, toSourceDiagnostic(srcAnalyzer, &gobDiag))
+			}
+		}
+
+		// Now that Finish functions may have used the
+		// //analysis:ignore directives, report their problems.
+		for _, root := range roots {
+			if root.finishing != nil && root.summary != nil {
+				problems, err := root.finishing.pkg.ignoreProblems(root.finishing.actions, knownAnalyzers)
+				if err != nil {
+					return nil, err
+				}
+				root.summary.IgnoreProblems = problems
+			}
+		}
+	}
+
 	for _, root := range roots {
 		for _, a := range enabledAnalyzers {
 			// Skip analyzers that were added only to
@@ -472,6 +577,9 @@ func (s *Snapshot) Analyze(ctx context.Context, pkgs map[PackageID]*metadata.Pac
 				}
 				continue
 			}
+			if !enabledFor(root.mp.ID, srcAnalyzer) {
+				continue // disabled for this package by a configuration file
+			}
 
 			// Inv: root.summary is the successful result of run (via runCached).
 			// TODO(adonovan): fix: root.summary is sometimes nil! (#66732).
@@ -487,10 +595,56 @@ func (s *Snapshot) Analyze(ctx context.Context, pkgs map[PackageID]*metadata.Pac
 				results = append(results, toSourceDiagnostic(srcAnalyzer, &gobDiag))
 			}
 		}
+		for _, gobDiag := range root.summary.IgnoreProblems {
+			results = append(results, toSourceDiagnostic(settings.IgnoreDirectives, &gobDiag))
+		}
 	}
 	return results, nil
 }
 
+// analysisConfigs returns the settings of the analysis configuration
+// file, if any, that applies to each package, and the file itself.
+// Files are read through the snapshot, so that unsaved edits apply.
+// Files that cannot be read or decoded are logged and ignored.
+func analysisConfigs(ctx context.Context, s *Snapshot, pkgs map[PackageID]*metadata.Package) (map[PackageID]*analysisinternal.ConfigSettings, map[PackageID]file.Handle) {
+	handles := make(map[string]file.Handle) // by file name
+	readFile := func(filename string) ([]byte, error) {
+		fh, err := s.ReadFile(ctx, protocol.URIFromPath(filename))
+		if err != nil {
+			return nil, err
+		}
+		content, err := fh.Content()
+		if err != nil {
+			return nil, err
+		}
+		handles[filename] = fh
+		return content, nil
+	}
+	configs := make(map[protocol.DocumentURI]*analysisinternal.Config) // by directory
+	result := make(map[PackageID]*analysisinternal.ConfigSettings)
+	files := make(map[PackageID]file.Handle)
+	for id, mp := range pkgs {
+		if len(mp.CompiledGoFiles) == 0 {
+			continue
+		}
+		dir := mp.CompiledGoFiles[0].Dir()
+		config, ok := configs[dir]
+		if !ok {
+			var err error
+			config, err = analysisinternal.FindConfig(dir.Path(), readFile)
+			if err != nil {
+				event.Error(ctx, "reading analysis configuration", err)
+			}
+			configs[dir] = config
+		}
+		if config != nil {
+			result[id] = config.Resolve(dir.Path())
+			files[id] = handles[config.Filename()]
+		}
+	}
+	return result, files
+}
+
 func (an *analysisNode) decrefPreds() {
 	if an.unfinishedPreds.Add(-1) == 0 {
 		an.summary.Actions = nil
@@ -526,6 +680,8 @@ type analysisNode struct {
 	viewType        ViewType                    // type of view
 	mp              *metadata.Package           // metadata for this package
 	files           []file.Handle               // contents of CompiledGoFiles
+	modFiles        []file.Handle               // contents of go.mod and (optional) go.work files
+	configFile      file.Handle                 // analysis configuration file of a root node, or nil
 	analyzers       []*analysis.Analyzer        // set of analyzers to run
 	preds           []*analysisNode             // graph edges:
 	succs           map[PackageID]*analysisNode //   (preds -> self -> succs)
@@ -535,6 +691,9 @@ type analysisNode struct {
 	exportDeps      map[PackagePath]*analysisNode // subset of allDeps ref'd by export data (+self)
 	summary         *analyzeSummary               // serializable result of analyzing this package
 	stableNames     map[*analysis.Analyzer]string // cross-process stable names for Analyzers
+	known           map[string]bool               // names of analyzers that directives may name
+	finishers       []*analysis.Analyzer          // whole-program analyzers of a root node
+	finishing       *finishingPackage             // set by run if finishers is non-empty
 
 	typesOnce sync.Once      // guards lazy population of types and typesErr fields
 	types     *types.Package // type information lazily imported from summary
@@ -600,10 +759,11 @@ func (an *analysisNode) _import() (*types.Package, error) {
 // analyzeSummary is a gob-serializable summary of successfully
 // applying a list of analyzers to a package.
 type analyzeSummary struct {
-	Export         []byte    // encoded types of package
-	DeepExportHash file.Hash // hash of reflexive transitive closure of export data
-	Compiles       bool      // transitively free of list/parse/type errors
-	Actions        actionMap // maps analyzer stablename to analysis results (*actionSummary)
+	Export         []byte          // encoded types of package
+	DeepExportHash file.Hash       // hash of reflexive transitive closure of export data
+	Compiles       bool            // transitively free of list/parse/type errors
+	Actions        actionMap       // maps analyzer stablename to analysis results (*actionSummary)
+	IgnoreProblems []gobDiagnostic // problems with //analysis:ignore directives
 }
 
 // actionMap defines a stable Gob encoding for a map.
@@ -664,6 +824,13 @@ type actionSummary struct {
 // Postcondition: runCached must not continue to use the snapshot
 // (in background goroutines) after it has returned; see memoize.RefCounted.
 func (an *analysisNode) runCached(ctx context.Context) (*analyzeSummary, error) {
+	// The Finish functions of whole-program analyzers need the
+	// syntax, types, and results of the root packages, which the
+	// cache does not hold, so these roots are always analyzed.
+	if len(an.finishers) > 0 {
+		return an.run(ctx)
+	}
+
 	// At this point we have the action results (serialized
 	// packages and facts) of our immediate dependencies,
 	// and the metadata and content of this package.
@@ -762,6 +929,25 @@ func (an *analysisNode) cacheKey() [sha256.Size]byte {
 		fmt.Fprintf(hasher, "go %s\n", mp.Module.GoVersion)
 	}
 
+	// analyzers that //analysis:ignore directives may name
+	known := make([]string, 0, len(an.known))
+	for name := range an.known {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+	fmt.Fprintf(hasher, "known: %s\n", strings.Join(known, " "))
+
+	// module files
+	fmt.Fprintf(hasher, "modfiles: %d\n", len(an.modFiles))
+	for _, fh := range an.modFiles {
+		fmt.Fprintln(hasher, fh.Identity())
+	}
+
+	// analysis configuration file
+	if an.configFile != nil {
+		fmt.Fprintf(hasher, "config: %s\n", an.configFile.Identity())
+	}
+
 	// file names and contents
 	fmt.Fprintf(hasher, "files: %d\n", len(an.files))
 	for _, fh := range an.files {
@@ -833,6 +1019,7 @@ func (an *analysisNode) run(ctx context.Context) (*analyzeSummary, error) {
 
 	// Type-check the package syntax.
 	pkg := an.typeCheck(parsed)
+	pkg.ignores = analysisinternal.ParseIgnoreDirectives(pkg.fset, pkg.files)
 
 	// Publish the completed package.
 	an.typesOnce.Do(func() { an.types = pkg.types })
@@ -879,6 +1066,8 @@ func (an *analysisNode) run(ctx context.Context) (*analyzeSummary, error) {
 
 	// -- analysis --
 
+	module := an.module()
+
 	// Build action graph for this package.
 	// Each graph node (action) is one unit of analysis.
 	actions := make(map[*analysis.Analyzer]*action)
@@ -895,6 +1084,7 @@ func (an *analysisNode) run(ctx context.Context) (*analyzeSummary, error) {
 				fsource:    an.fsource,
 				stableName: an.stableNames[a],
 				pkg:        pkg,
+				module:     module,
 				vdeps:      an.succs,
 				hdeps:      hdeps,
 			}
@@ -918,6 +1108,16 @@ func (an *analysisNode) run(ctx context.Context) (*analyzeSummary, error) {
 		return nil, err // cancelled
 	}
 
+	ignoreProblems, err := pkg.ignoreProblems(actions, an.known)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retain the package and actions for the Finish functions.
+	if len(an.finishers) > 0 {
+		an.finishing = &finishingPackage{pkg: pkg, actions: actions}
+	}
+
 	// Return summaries only for the requested actions.
 	summaries := make(map[string]*actionSummary)
 	for _, root := range roots {
@@ -932,9 +1132,236 @@ func (an *analysisNode) run(ctx context.Context) (*analyzeSummary, error) {
 		DeepExportHash: pkg.deepExportHash,
 		Compiles:       pkg.compiles,
 		Actions:        summaries,
+		IgnoreProblems: ignoreProblems,
 	}, nil
 }
 
+// ignoreProblems returns the problems with the package's
+// //analysis:ignore directives, such as directives that name unknown
+// analyzers or that suppressed no diagnostic of an analyzer that
+// succeeded. It must be called after all actions have completed.
+func (pkg *analysisPackage) ignoreProblems(actions map[*analysis.Analyzer]*action, known map[string]bool) ([]gobDiagnostic, error) {
+	ran := make(map[string]bool)
+	for a, act := range actions {
+		if act.summary != nil && act.summary.Err == "" {
+			ran[a.Name] = true
+		}
+	}
+	posToLocation := func(start, end token.Pos) (protocol.Location, error) {
+		for _, pgf := range pkg.parsed {
+			if pgf.Tok == pkg.fset.File(start) {
+				return pgf.PosLocation(start, end)
+			}
+		}
+		return protocol.Location{}, bug.Errorf("directive is not among files of package")
+	}
+	var problems []gobDiagnostic
+	for _, d := range pkg.ignores.Problems(
+		func(name string) bool { return known[name] },
+		func(name string) bool { return ran[name] },
+	) {
+		gobDiag, err := toGobDiagnostic(posToLocation, analysisinternal.IgnoreAnalyzer, d)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, gobDiag)
+	}
+	return problems, nil
+}
+
+// A finishingPackage holds what the Finish functions of whole-program
+// analyzers need of a root package: its syntax and types, and the
+// actions that produced its results and facts.
+type finishingPackage struct {
+	pkg     *analysisPackage
+	actions map[*analysis.Analyzer]*action
+}
+
+// finish calls the Finish function of the whole-program analyzer a
+// with the successful actions of a on the given root packages, and
+// returns the diagnostics it reports, less those suppressed by
+// //analysis:ignore directives.
+func finish(ctx context.Context, a *analysis.Analyzer, finishing []*finishingPackage) ([]gobDiagnostic, error) {
+	type objectFactKey struct {
+		obj types.Object
+		typ reflect.Type
+	}
+	type packageFactKey struct {
+		pkg *types.Package
+		typ reflect.Type
+	}
+	type contributedFactKey struct {
+		from string
+		obj  types.Object
+		typ  reflect.Type
+	}
+	var (
+		fset         *token.FileSet
+		fsource      file.Source
+		pkgs         []*analysis.PackageResult
+		byFile       = make(map[string]*analysisPackage) // root package by file name
+		objectFacts  []analysis.ObjectFact
+		packageFacts []analysis.PackageFact
+		contributed  []analysis.ContributedFact
+		seenObjects  = make(map[objectFactKey]bool)
+		seenPackages = make(map[packageFactKey]bool)
+		seenContribs = make(map[contributedFactKey]bool)
+	)
+	for _, fp := range finishing {
+		act := fp.actions[a]
+		if act == nil || act.err != nil {
+			continue // action failed
+		}
+		fset, fsource = fp.pkg.fset, act.fsource
+		for _, pgf := range fp.pkg.parsed {
+			byFile[pgf.Tok.Name()] = fp.pkg
+		}
+		pkgs = append(pkgs, &analysis.PackageResult{
+			Pkg:       fp.pkg.types,
+			Files:     fp.pkg.files,
+			TypesInfo: fp.pkg.typesInfo,
+			Result:    act.result,
+		})
+
+		// The actions inherit the facts of their dependencies,
+		// so the root packages have many facts in common.
+		for _, f := range act.objectFacts {
+			if k := (objectFactKey{f.Object, reflect.TypeOf(f.Fact)}); !seenObjects[k] {
+				seenObjects[k] = true
+				objectFacts = append(objectFacts, f)
+			}
+		}
+		for _, f := range act.packageFacts {
+			if k := (packageFactKey{f.Package, reflect.TypeOf(f.Fact)}); !seenPackages[k] {
+				seenPackages[k] = true
+				packageFacts = append(packageFacts, f)
+			}
+		}
+		for _, f := range act.contributedFacts {
+			if k := (contributedFactKey{f.From, f.Object, reflect.TypeOf(f.Fact)}); !seenContribs[k] {
+				seenContribs[k] = true
+				contributed = append(contributed, f)
+			}
+		}
+	}
+	if len(pkgs) == 0 {
+		return nil, nil
+	}
+
+	// posToLocation converts from token.Pos to protocol form.
+	// Unlike Pass.Report, FinishPass.Report may concern any
+	// of the root packages.
+	posToLocation := func(start, end token.Pos) (protocol.Location, error) {
+		if tokFile := fset.File(start); tokFile != nil {
+			if pkg := byFile[tokFile.Name()]; pkg != nil {
+				for _, pgf := range pkg.parsed {
+					if pgf.Tok.Name() == tokFile.Name() {
+						if end == token.NoPos {
+							end = start
+						}
+						return pgf.PosLocation(start, end)
+					}
+				}
+			}
+		}
+		return protocol.Location{}, fmt.Errorf("diagnostic location is not among files of the root packages")
+	}
+
+	var diagnostics []gobDiagnostic
+	pass := &analysis.FinishPass{
+		Analyzer: a,
+		Fset:     fset,
+		Packages: pkgs,
+		Report: func(d analysis.Diagnostic) {
+			// Drop diagnostics suppressed by //analysis:ignore directives.
+			if tokFile := fset.File(d.Pos); tokFile != nil {
+				if pkg := byFile[tokFile.Name()]; pkg != nil {
+					pkg.ignoresMu.Lock()
+					kept := pkg.ignores.Filter(a.Name, []analysis.Diagnostic{d})
+					pkg.ignoresMu.Unlock()
+					if len(kept) == 0 {
+						return
+					}
+				}
+			}
+
+			diagnostic, err := toGobDiagnostic(posToLocation, a, d)
+			if err != nil {
+				event.Error(ctx, fmt.Sprintf("internal error converting diagnostic from analyzer %q", a.Name), err)
+				return
+			}
+			diagnostics = append(diagnostics, diagnostic)
+		},
+		ReadFile: func(filename string) ([]byte, error) {
+			// As with Pass.ReadFile, read the file from the snapshot,
+			// and only the (compiled Go) files of the root packages.
+			if byFile[filename] == nil {
+				return nil, fmt.Errorf("Finish function of %s attempted to read %s, which is not a file of the root packages", a, filename)
+			}
+			h, err := fsource.ReadFile(ctx, protocol.URIFromPath(filename))
+			if err != nil {
+				return nil, err
+			}
+			content, err := h.Content()
+			if err != nil {
+				return nil, err // file doesn't exist
+			}
+			return slices.Clone(content), nil // follow ownership of os.ReadFile
+		},
+		AllObjectFacts:      func() []analysis.ObjectFact { return objectFacts },
+		AllPackageFacts:     func() []analysis.PackageFact { return packageFacts },
+		AllContributedFacts: func() []analysis.ContributedFact { return contributed },
+	}
+
+	start := time.Now()
+	err := a.Finish(pass)
+
+	// Accumulate running time for each checker.
+	analyzerRunTimesMu.Lock()
+	analyzerRunTimes[a] += time.Since(start)
+	analyzerRunTimesMu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return diagnostics, nil
+}
+
+// module returns the analysis.Module for the package's module,
+// or nil if it is not in a module. If the go.mod or go.work file
+// cannot be read or parsed, the module has no requirements or
+// replacements, as the errors are reported elsewhere.
+func (an *analysisNode) module() *analysis.Module {
+	mod := an.mp.Module
+	if mod == nil {
+		return nil
+	}
+	m := &analysis.Module{
+		Path:      mod.Path,
+		Version:   mod.Version,
+		GoVersion: analysisinternal.GoVersion(mod.GoVersion),
+	}
+	var (
+		names    []string
+		contents [][]byte
+	)
+	for _, fh := range an.modFiles {
+		content, err := fh.Content()
+		if err != nil {
+			return m
+		}
+		names = append(names, fh.URI().Path())
+		contents = append(contents, content)
+	}
+	switch len(names) {
+	case 1:
+		analysisinternal.ParseModFiles(m, names[0], contents[0], "", nil)
+	case 2:
+		analysisinternal.ParseModFiles(m, names[0], contents[0], names[1], contents[1])
+	}
+	return m
+}
+
 // Postcondition: analysisPackage.types and an.exportDeps are populated.
 func (an *analysisNode) typeCheck(parsed []*parsego.File) *analysisPackage {
 	mp := an.mp
@@ -1134,6 +1561,9 @@ type analysisPackage struct {
 	typesInfo      *types.Info
 	typeErrors     []types.Error
 	typesSizes     types.Sizes
+
+	ignoresMu sync.Mutex // guards ignores, which Filter updates
+	ignores   *analysisinternal.IgnoreDirectives
 }
 
 // An action represents one unit of analysis work: the application of
@@ -1146,13 +1576,17 @@ type action struct {
 	fsource    file.Source // Snapshot.ReadFile, for Pass.ReadFile
 	stableName string      // cross-process stable name of analyzer
 	pkg        *analysisPackage
+	module     *analysis.Module            // for Pass.Module, or nil
 	hdeps      []*action                   // horizontal dependencies
 	vdeps      map[PackageID]*analysisNode // vertical dependencies
 
 	// results of action.exec():
-	result  interface{} // result of Run function, of type a.ResultType
-	summary *actionSummary
-	err     error
+	result           interface{} // result of Run function, of type a.ResultType
+	summary          *actionSummary
+	err              error
+	objectFacts      []analysis.ObjectFact      // facts of a whole-program analyzer, for Finish
+	packageFacts     []analysis.PackageFact     // (ditto)
+	contributedFacts []analysis.ContributedFact // (ditto)
 }
 
 func (act *action) String() string {
@@ -1393,8 +1827,17 @@ func (act *action) exec(ctx context.Context) (any, *actionSummary, error) {
 		TypesInfo:    pkg.typesInfo,
 		TypesSizes:   pkg.typesSizes,
 		TypeErrors:   pkg.typeErrors,
+		Module:       act.module,
 		ResultOf:     inputs,
 		Report: func(d analysis.Diagnostic) {
+			// Drop diagnostics suppressed by //analysis:ignore directives.
+			pkg.ignoresMu.Lock()
+			kept := pkg.ignores.Filter(analyzer.Name, []analysis.Diagnostic{d})
+			pkg.ignoresMu.Unlock()
+			if len(kept) == 0 {
+				return
+			}
+
 			diagnostic, err := toGobDiagnostic(posToLocation, analyzer, d)
 			if err != nil {
 				// Don't bug.Report here: these errors all originate in
@@ -1405,12 +1848,14 @@ func (act *action) exec(ctx context.Context) (any, *actionSummary, error) {
 			}
 			diagnostics = append(diagnostics, diagnostic)
 		},
-		ImportObjectFact:  factset.ImportObjectFact,
-		ExportObjectFact:  factset.ExportObjectFact,
-		ImportPackageFact: factset.ImportPackageFact,
-		ExportPackageFact: factset.ExportPackageFact,
-		AllObjectFacts:    func() []analysis.ObjectFact { return factset.AllObjectFacts(factFilter) },
-		AllPackageFacts:   func() []analysis.PackageFact { return factset.AllPackageFacts(factFilter) },
+		ImportObjectFact:     factset.ImportObjectFact,
+		ExportObjectFact:     factset.ExportObjectFact,
+		ImportPackageFact:    factset.ImportPackageFact,
+		ExportPackageFact:    factset.ExportPackageFact,
+		AllObjectFacts:       func() []analysis.ObjectFact { return factset.AllObjectFacts(factFilter) },
+		AllPackageFacts:      func() []analysis.PackageFact { return factset.AllPackageFacts(factFilter) },
+		ContributeObjectFact: factset.ContributeObjectFact,
+		AllContributedFacts:  func() []analysis.ContributedFact { return factset.AllContributedFacts(factFilter) },
 	}
 
 	pass.ReadFile = func(filename string) ([]byte, error) {
@@ -1490,6 +1935,16 @@ func (act *action) exec(ctx context.Context) (any, *actionSummary, error) {
 	pass.ExportPackageFact = func(fact analysis.Fact) {
 		panic(fmt.Sprintf("%v: Pass.ExportPackageFact(%T) called after Run", act, fact))
 	}
+	pass.ContributeObjectFact = func(obj types.Object, fact analysis.Fact) {
+		panic(fmt.Sprintf("%v: Pass.ContributeObjectFact(%s, %T) called after Run", act, obj, fact))
+	}
+
+	// Retain the facts of a whole-program analyzer for its Finish function.
+	if analyzer.Finish != nil {
+		act.objectFacts = factset.AllObjectFacts(factFilter)
+		act.packageFacts = factset.AllPackageFacts(factFilter)
+		act.contributedFacts = factset.AllContributedFacts(factFilter)
+	}
 
 	factsdata := factset.Encode()
 	return result, &actionSummary{
@@ -62,6 +62,7 @@ import (
 	"github.com/TBD54566975/golang-tools/gopls/internal/analysis/unusedvariable"
 	"github.com/TBD54566975/golang-tools/gopls/internal/analysis/useany"
 	"github.com/TBD54566975/golang-tools/gopls/internal/protocol"
+	"github.com/TBD54566975/golang-tools/internal/analysisinternal"
 	"honnef.co/go/tools/staticcheck"
 )
 
@@ -218,6 +219,11 @@ func init() {
 	}
 }
 
+// IgnoreDirectives describes the pseudo-analyzer to which gopls
+// attributes problems with //analysis:ignore directives. It is never
+// run, so it does not appear among the DefaultAnalyzers.
+var IgnoreDirectives = &Analyzer{analyzer: analysisinternal.IgnoreAnalyzer, enabled: true}
+
 // StaticcheckAnalzyers describes available Staticcheck analyzers, keyed by
 // analyzer name.
 var StaticcheckAnalyzers = make(map[string]*Analyzer) // written by analysis_<ver>.go
@@ -0,0 +1,199 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisinternal
+
+// This file defines analysis configuration files, which set the
+// analyzers and analyzer flags of a project without the need for
+// command-line flags, and may vary them by directory.
+//
+// A configuration file is named analysis.json. The file that applies
+// to a package is the one in the package's directory or the nearest
+// of its parent directories. For example:
+//
+//	{
+//		"analyzers": {"shadow": false},
+//		"settings": {"printf": {"funcs": "Logf,Warnf"}},
+//		"overrides": [
+//			{
+//				"paths": ["internal/legacy/...", "cmd/*"],
+//				"analyzers": {"printf": false}
+//			}
+//		]
+//	}
+//
+// The "analyzers" map disables (false) or re-enables (true) analyzers
+// by name, and the "settings" map sets the flags of analyzers, by
+// analyzer name and flag name. Each override applies its own maps,
+// in order, to the packages in the directories that match one of its
+// paths. A path is slash-separated and relative to the directory of
+// the configuration file; it may end in "/..." to match a directory
+// and all its subdirectories, and otherwise is matched as by
+// path.Match. The path "..." matches every directory.
+//
+// Analyzer names that the driver does not know are ignored, so that
+// the same file may serve several tools. Flags set on the command line
+// (or, in gopls, the "analyses" setting) take precedence over the file.
+//
+// gopls applies only the "analyzers" maps of the file, not its
+// "settings": the flags of an analyzer are global variables shared by
+// all the concurrent analyses of a gopls process, and so cannot vary
+// by package.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFileName is the name of an analysis configuration file.
+const ConfigFileName = "analysis.json"
+
+// A Config is the decoded form of an analysis configuration file.
+type Config struct {
+	Analyzers map[string]bool              `json:"analyzers,omitempty"`
+	Settings  map[string]map[string]string `json:"settings,omitempty"`
+	Overrides []ConfigOverride             `json:"overrides,omitempty"`
+
+	filename string // name of the file
+}
+
+// A ConfigOverride modifies a Config within some directories.
+type ConfigOverride struct {
+	Paths     []string                     `json:"paths"`
+	Analyzers map[string]bool              `json:"analyzers,omitempty"`
+	Settings  map[string]map[string]string `json:"settings,omitempty"`
+}
+
+// ConfigSettings holds the configuration that applies to one package.
+type ConfigSettings struct {
+	Analyzers map[string]bool              // analyzers explicitly enabled (true) or disabled (false)
+	Flags     map[string]map[string]string // flag values, by analyzer and flag name
+}
+
+// Enabled reports whether the named analyzer is enabled.
+// Analyzers are enabled unless disabled by the configuration.
+func (s *ConfigSettings) Enabled(name string) bool {
+	enabled, ok := s.Analyzers[name]
+	return !ok || enabled
+}
+
+// ReadConfig reads the named configuration file using readFile,
+// such as os.ReadFile.
+func ReadConfig(filename string, readFile func(string) ([]byte, error)) (*Config, error) {
+	data, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	cfg := &Config{filename: filename}
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("cannot decode analysis config file %s: %v", filename, err)
+	}
+	for _, o := range cfg.Overrides {
+		if len(o.Paths) == 0 {
+			return nil, fmt.Errorf("%s: override has no paths", filename)
+		}
+		for _, p := range o.Paths {
+			if _, err := path.Match(strings.TrimSuffix(p, "/..."), ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid path %q: %v", filename, p, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// Filename returns the name of the configuration file.
+func (cfg *Config) Filename() string { return cfg.filename }
+
+// FindConfig returns the configuration file that applies to the
+// packages in directory dir, namely the file named ConfigFileName in
+// dir or in the nearest of its parent directories. It returns nil if
+// there is no such file. Files are read using readFile, such as
+// os.ReadFile.
+func FindConfig(dir string, readFile func(string) ([]byte, error)) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		filename := filepath.Join(dir, ConfigFileName)
+		if _, err := readFile(filename); err == nil {
+			return ReadConfig(filename, readFile)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// Resolve returns the settings that apply to the packages in
+// directory dir: those of the configuration, modified by each
+// matching override in turn.
+func (cfg *Config) Resolve(dir string) *ConfigSettings {
+	s := &ConfigSettings{
+		Analyzers: make(map[string]bool),
+		Flags:     make(map[string]map[string]string),
+	}
+	apply := func(analyzers map[string]bool, settings map[string]map[string]string) {
+		for name, enabled := range analyzers {
+			s.Analyzers[name] = enabled
+		}
+		for name, flags := range settings {
+			if s.Flags[name] == nil {
+				s.Flags[name] = make(map[string]string)
+			}
+			for flag, value := range flags {
+				s.Flags[name][flag] = value
+			}
+		}
+	}
+	apply(cfg.Analyzers, cfg.Settings)
+
+	// Overrides apply only beneath the directory of the file.
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return s
+	}
+	rel, err := filepath.Rel(filepath.Dir(cfg.filename), abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return s
+	}
+	rel = filepath.ToSlash(rel)
+	for _, o := range cfg.Overrides {
+		for _, p := range o.Paths {
+			if matchConfigPath(p, rel) {
+				apply(o.Analyzers, o.Settings)
+				break
+			}
+		}
+	}
+	return s
+}
+
+// matchConfigPath reports whether the slash-separated directory
+// name rel, relative to a configuration file, matches pattern.
+func matchConfigPath(pattern, rel string) bool {
+	if pattern == "..." {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		for d := rel; ; d = path.Dir(d) {
+			if ok, _ := path.Match(prefix, d); ok {
+				return true
+			}
+			if d == "." || d == "/" {
+				return false
+			}
+		}
+	}
+	ok, _ := path.Match(pattern, rel)
+	return ok
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisinternal_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TBD54566975/golang-tools/internal/analysisinternal"
+)
+
+func TestConfig(t *testing.T) {
+	root := t.TempDir()
+	const config = `{
+	"analyzers": {"a": false},
+	"settings": {"b": {"f": "1"}},
+	"overrides": [
+		{"paths": ["x/..."], "analyzers": {"a": true}},
+		{"paths": ["x/y", "z*"], "settings": {"b": {"f": "2", "g": "3"}}},
+		{"paths": ["..."], "settings": {"c": {"h": "4"}}}
+	]
+}`
+	if err := os.WriteFile(filepath.Join(root, analysisinternal.ConfigFileName), []byte(config), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "x/y/w"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := analysisinternal.FindConfig(filepath.Join(root, "x/y/w"), os.ReadFile)
+	if err != nil || cfg == nil {
+		t.Fatalf("FindConfig: %v, %v", cfg, err)
+	}
+
+	for _, test := range []struct {
+		dir     string
+		enabled bool
+		flags   map[string]map[string]string
+	}{
+		{".", false, map[string]map[string]string{"b": {"f": "1"}, "c": {"h": "4"}}},
+		{"x", true, map[string]map[string]string{"b": {"f": "1"}, "c": {"h": "4"}}},
+		{"x/y", true, map[string]map[string]string{"b": {"f": "2", "g": "3"}, "c": {"h": "4"}}},
+		{"x/y/w", true, map[string]map[string]string{"b": {"f": "1"}, "c": {"h": "4"}}},
+		{"zz", false, map[string]map[string]string{"b": {"f": "2", "g": "3"}, "c": {"h": "4"}}},
+		{"../elsewhere", false, map[string]map[string]string{"b": {"f": "1"}}},
+	} {
+		s := cfg.Resolve(filepath.Join(root, test.dir))
+		if got := s.Enabled("a"); got != test.enabled {
+			t.Errorf("%s: Enabled(a) = %t, want %t", test.dir, got, test.enabled)
+		}
+		if !s.Enabled("nosuch") {
+			t.Errorf("%s: Enabled(nosuch) = false", test.dir)
+		}
+		if !reflect.DeepEqual(s.Flags, test.flags) {
+			t.Errorf("%s: Flags = %v, want %v", test.dir, s.Flags, test.flags)
+		}
+	}
+
+	if cfg, err := analysisinternal.FindConfig(filepath.Dir(root), os.ReadFile); cfg != nil || err != nil {
+		t.Errorf("FindConfig above the file = %v, %v, want nil", cfg, err)
+	}
+}
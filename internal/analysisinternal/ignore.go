@@ -0,0 +1,250 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisinternal
+
+// This file defines the //analysis:ignore directive, which suppresses
+// the diagnostics of particular analyzers within a line, a
+// declaration or statement, or a whole file:
+//
+//	//analysis:ignore printf,nilness reason for ignoring
+//
+// A directive that follows code on the same line applies to that line.
+// A directive on a line of its own applies to the outermost
+// declaration or statement that begins on the line after its comment
+// group, such as the declaration of which it is part of the doc
+// comment. A directive before the package clause applies to the file.
+//
+// The reason is mandatory. Directives are recognized by all of the
+// go/analysis drivers, which report malformed directives, directives
+// that name unknown analyzers, and directives that suppress nothing.
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+)
+
+const ignorePrefix = "//analysis:ignore"
+
+// IgnoreAnalyzer is the pseudo-analyzer to which drivers attribute the
+// diagnostics that describe problems with //analysis:ignore directives.
+// It has no Run function and must not be executed.
+var IgnoreAnalyzer = &analysis.Analyzer{
+	Name: "ignore",
+	Doc:  "report problems with //analysis:ignore directives",
+}
+
+// IgnoreDirectives holds the //analysis:ignore directives of a package.
+type IgnoreDirectives struct {
+	directives []*ignoreDirective
+}
+
+type ignoreDirective struct {
+	comment    *ast.Comment
+	start, end token.Pos       // suppressed range, half-open
+	names      []string        // analyzer names
+	used       map[string]bool // names that suppressed some diagnostic
+	problem    string          // non-empty if malformed
+}
+
+// ParseIgnoreDirectives returns the //analysis:ignore directives
+// of the specified files, which must have been parsed with comments.
+func ParseIgnoreDirectives(fset *token.FileSet, files []*ast.File) *IgnoreDirectives {
+	dirs := new(IgnoreDirectives)
+	for _, f := range files {
+		tf := fset.File(f.Package)
+		if tf == nil {
+			continue
+		}
+		var lines *fileLines // computed lazily
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				if !strings.HasPrefix(c.Text, ignorePrefix) {
+					continue
+				}
+				d := parseIgnore(c)
+				if d == nil {
+					continue // e.g. //analysis:ignorefoo
+				}
+				switch {
+				case group.Pos() < f.Package:
+					// In file header: applies to the whole file.
+					d.start, d.end = token.Pos(tf.Base()), token.Pos(tf.Base()+tf.Size())
+				default:
+					if lines == nil {
+						lines = newFileLines(tf, f)
+					}
+					d.start, d.end = lines.scope(c, tf.Line(group.End()))
+				}
+				dirs.directives = append(dirs.directives, d)
+			}
+		}
+	}
+	return dirs
+}
+
+// parseIgnore parses an //analysis:ignore comment, returning nil if the
+// comment is not such a directive.
+func parseIgnore(c *ast.Comment) *ignoreDirective {
+	rest := c.Text[len(ignorePrefix):]
+	if rest != "" && !unicode.IsSpace(rune(rest[0])) {
+		return nil
+	}
+	d := &ignoreDirective{comment: c, used: make(map[string]bool)}
+	fields := strings.Fields(rest)
+	switch {
+	case len(fields) == 0:
+		d.problem = "missing analyzer names"
+	case len(fields) == 1:
+		d.problem = "missing reason"
+	}
+	if len(fields) > 0 {
+		for _, name := range strings.Split(fields[0], ",") {
+			if name == "" {
+				d.problem = "empty analyzer name"
+				break
+			}
+			d.names = append(d.names, name)
+		}
+	}
+	return d
+}
+
+// fileLines records, for each line of a file, the syntax that begins
+// and ends on it.
+type fileLines struct {
+	tf       *token.File
+	first    map[int]ast.Node  // outermost node beginning on each line
+	minStart map[int]token.Pos // least start of a node on each line
+	minEnd   map[int]token.Pos // least end of a node on each line
+}
+
+func newFileLines(tf *token.File, f *ast.File) *fileLines {
+	lines := &fileLines{
+		tf:       tf,
+		first:    make(map[int]ast.Node),
+		minStart: make(map[int]token.Pos),
+		minEnd:   make(map[int]token.Pos),
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil, *ast.File:
+			return n != nil
+		case *ast.CommentGroup, *ast.Comment:
+			return false
+		}
+		line := tf.Line(n.Pos())
+		if _, ok := lines.first[line]; !ok {
+			lines.first[line] = n // preorder visits enclosing nodes first
+		}
+		if pos, ok := lines.minStart[line]; !ok || n.Pos() < pos {
+			lines.minStart[line] = n.Pos()
+		}
+		line = tf.Line(n.End())
+		if pos, ok := lines.minEnd[line]; !ok || n.End() < pos {
+			lines.minEnd[line] = n.End()
+		}
+		return true
+	})
+	return lines
+}
+
+// scope returns the range suppressed by directive comment c,
+// whose comment group ends on line groupEnd.
+func (lines *fileLines) scope(c *ast.Comment, groupEnd int) (start, end token.Pos) {
+	line := lines.tf.Line(c.Pos())
+	if pos, ok := lines.minStart[line]; ok && pos < c.Pos() {
+		return lines.line(line) // follows code on the same line
+	}
+	if pos, ok := lines.minEnd[line]; ok && pos <= c.Pos() {
+		return lines.line(line) // follows the end of a node, such as "}"
+	}
+	if n, ok := lines.first[groupEnd+1]; ok {
+		return n.Pos(), n.End()
+	}
+	return lines.line(groupEnd + 1)
+}
+
+// line returns the range of the specified line.
+func (lines *fileLines) line(line int) (start, end token.Pos) {
+	tf := lines.tf
+	if line > tf.LineCount() {
+		eof := token.Pos(tf.Base() + tf.Size())
+		return eof, eof
+	}
+	start = tf.LineStart(line)
+	if line < tf.LineCount() {
+		end = tf.LineStart(line + 1)
+	} else {
+		end = token.Pos(tf.Base() + tf.Size())
+	}
+	return start, end
+}
+
+// Filter returns the diagnostics of the named analyzer that are not
+// suppressed by any directive, and records which directives were used.
+// It does not modify diags.
+func (dirs *IgnoreDirectives) Filter(analyzer string, diags []analysis.Diagnostic) []analysis.Diagnostic {
+	if len(dirs.directives) == 0 {
+		return diags
+	}
+	var kept []analysis.Diagnostic
+	for _, diag := range diags {
+		suppressed := false
+		for _, d := range dirs.directives {
+			if d.problem == "" && d.start <= diag.Pos && diag.Pos < d.end && d.suppresses(analyzer) {
+				d.used[analyzer] = true
+				suppressed = true
+			}
+		}
+		if !suppressed {
+			kept = append(kept, diag)
+		}
+	}
+	return kept
+}
+
+func (d *ignoreDirective) suppresses(analyzer string) bool {
+	for _, name := range d.names {
+		if name == analyzer {
+			return true
+		}
+	}
+	return false
+}
+
+// Problems returns a diagnostic for each malformed directive, each
+// analyzer name for which known is false, and each analyzer name that
+// suppressed no diagnostic although ran reports that the analyzer was
+// successfully applied to the package. It should be called after
+// Filter has been applied to the diagnostics of every analyzer.
+func (dirs *IgnoreDirectives) Problems(known, ran func(analyzer string) bool) []analysis.Diagnostic {
+	var diags []analysis.Diagnostic
+	report := func(d *ignoreDirective, format string, args ...interface{}) {
+		diags = append(diags, analysis.Diagnostic{
+			Pos:     d.comment.Pos(),
+			End:     d.comment.End(),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	for _, d := range dirs.directives {
+		if d.problem != "" {
+			report(d, "malformed %s directive: %s", ignorePrefix, d.problem)
+			continue
+		}
+		for _, name := range d.names {
+			if !known(name) {
+				report(d, "unknown analyzer %q in %s directive", name, ignorePrefix)
+			} else if ran(name) && !d.used[name] {
+				report(d, "%s directive for %q suppresses no diagnostic", ignorePrefix, name)
+			}
+		}
+	}
+	return diags
+}
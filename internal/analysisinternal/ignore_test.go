@@ -0,0 +1,94 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisinternal_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+	"github.com/TBD54566975/golang-tools/internal/analysisinternal"
+)
+
+func TestIgnoreDirectives(t *testing.T) {
+	const src = `//analysis:ignore c applies to the whole file
+
+package p
+
+func F() {
+	bad() //analysis:ignore a applies to this line
+	bad()
+}
+
+// G is ignored.
+//
+//analysis:ignore a,b applies to the declaration
+func G() {
+	bad()
+	bad()
+}
+
+func H() {
+	//analysis:ignore a applies to the statement
+	if true {
+		bad()
+	}
+	bad()
+	//analysis:ignore a
+	bad()
+	//analysis:ignore nosuch,b reason
+	bad()
+}
+
+func bad() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Analyzer a reports every call, and c the first one.
+	var a, c []analysis.Diagnostic
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			a = append(a, analysis.Diagnostic{Pos: call.Pos(), Message: "call"})
+		}
+		return true
+	})
+	c = a[:1]
+
+	dirs := analysisinternal.ParseIgnoreDirectives(fset, []*ast.File{f})
+	lines := func(diags []analysis.Diagnostic) []string {
+		var res []string
+		for _, d := range diags {
+			res = append(res, fmt.Sprintf("%d: %s", fset.Position(d.Pos).Line, d.Message))
+		}
+		return res
+	}
+
+	if got, want := lines(dirs.Filter("a", a)), []string{"7: call", "23: call", "25: call", "27: call"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter(a) = %q, want %q", got, want)
+	}
+	if got := dirs.Filter("c", c); len(got) > 0 {
+		t.Errorf("Filter(c) = %q, want none", lines(got))
+	}
+
+	known := func(name string) bool { return name != "nosuch" }
+	ran := func(name string) bool { return true }
+	want := []string{
+		`12: //analysis:ignore directive for "b" suppresses no diagnostic`,
+		`24: malformed //analysis:ignore directive: missing reason`,
+		`26: unknown analyzer "nosuch" in //analysis:ignore directive`,
+		`26: //analysis:ignore directive for "b" suppresses no diagnostic`,
+	}
+	if got := lines(dirs.Problems(known, ran)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Problems = %q, want %q", got, want)
+	}
+}
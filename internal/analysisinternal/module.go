@@ -0,0 +1,130 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisinternal
+
+// This file helps drivers to populate analysis.Pass.Module.
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+)
+
+// ReadModFiles populates the Requires and Replaces fields of m from
+// the named go.mod file and, if gowork is non-empty, go.work file,
+// which are read using readFile, such as os.ReadFile.
+func ReadModFiles(m *analysis.Module, gomod, gowork string, readFile func(string) ([]byte, error)) error {
+	modData, err := readFile(gomod)
+	if err != nil {
+		return err
+	}
+	var workData []byte
+	if gowork != "" {
+		workData, err = readFile(gowork)
+		if err != nil {
+			return err
+		}
+	}
+	return ParseModFiles(m, gomod, modData, gowork, workData)
+}
+
+// ParseModFiles is like ReadModFiles, but it parses the given
+// contents of the go.mod and go.work files instead of reading them.
+func ParseModFiles(m *analysis.Module, gomod string, modData []byte, gowork string, workData []byte) error {
+	f, err := modfile.Parse(gomod, modData, nil)
+	if err != nil {
+		return err
+	}
+	requires := []analysis.ModuleRequirement{}
+	for _, r := range f.Require {
+		requires = append(requires, analysis.ModuleRequirement{
+			Path:     r.Mod.Path,
+			Version:  r.Mod.Version,
+			Indirect: r.Indirect,
+		})
+	}
+	replaces := replacements(f.Replace)
+
+	if gowork != "" {
+		wf, err := modfile.ParseWork(gowork, workData, nil)
+		if err != nil {
+			return err
+		}
+		replaces = append(replaces, replacements(wf.Replace)...)
+	}
+
+	m.Requires, m.Replaces = requires, replaces
+	return nil
+}
+
+func replacements(directives []*modfile.Replace) []analysis.ModuleReplacement {
+	replaces := []analysis.ModuleReplacement{}
+	for _, r := range directives {
+		replaces = append(replaces, analysis.ModuleReplacement{
+			OldPath:    r.Old.Path,
+			OldVersion: r.Old.Version,
+			NewPath:    r.New.Path,
+			NewVersion: r.New.Version,
+		})
+	}
+	return replaces
+}
+
+// FindGoMod returns the name of the go.mod file of the module with
+// path modpath that contains directory dir, or "" if there is none.
+func FindGoMod(dir, modpath string) string {
+	for {
+		filename := filepath.Join(dir, "go.mod")
+		if data, err := os.ReadFile(filename); err == nil {
+			if modfile.ModulePath(data) != modpath {
+				return "" // e.g. dir is in a vendor tree
+			}
+			return filename
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// GoWorkFile returns the name of the go.work file that applies to a
+// main module in directory dir, given the value of the GOWORK
+// environment variable, or "" if there is none. As in the go command,
+// an unset GOWORK means the nearest go.work file in dir or a parent,
+// as seen by readFile, such as os.ReadFile.
+func GoWorkFile(dir, gowork string, readFile func(string) ([]byte, error)) string {
+	switch gowork {
+	case "off":
+		return ""
+	case "":
+		for {
+			filename := filepath.Join(dir, "go.work")
+			if _, err := readFile(filename); err == nil {
+				return filename
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return ""
+			}
+			dir = parent
+		}
+	}
+	return gowork
+}
+
+// GoVersion returns the go version v of a module in the form "go1.N",
+// adding the "go" prefix if necessary.
+func GoVersion(v string) string {
+	if v != "" && !strings.HasPrefix(v, "go") {
+		v = "go" + v
+	}
+	return v
+}
@@ -94,6 +94,43 @@ func SortEdits(edits []Edit) {
 	sort.Stable(editsSort(edits))
 }
 
+// Merge performs a three-way merge of two lists of edits to the same
+// text, each of which must be sorted and free of overlaps. It returns
+// the combined list, or false if the lists conflict.
+//
+// Identical edits in x and y are coalesced, as in a merge of two
+// branches that made the same change. Two edits conflict if they
+// differ and their regions overlap, or if they are different
+// insertions at the same point, since their relative order is
+// ambiguous. An insertion at the start or end of a replaced region
+// does not conflict with it.
+func Merge(x, y []Edit) ([]Edit, bool) {
+	// before reports whether a precedes b without conflict. (If a
+	// ends where b starts, they conflict only if both are insertions.)
+	before := func(a, b Edit) bool {
+		return a.End <= b.Start && a.Start < b.End
+	}
+	merged := make([]Edit, 0, len(x)+len(y))
+	for len(x) > 0 && len(y) > 0 {
+		switch ex, ey := x[0], y[0]; {
+		case ex == ey:
+			merged = append(merged, ex)
+			x, y = x[1:], y[1:]
+		case before(ex, ey):
+			merged = append(merged, ex)
+			x = x[1:]
+		case before(ey, ex):
+			merged = append(merged, ey)
+			y = y[1:]
+		default:
+			return nil, false
+		}
+	}
+	merged = append(merged, x...)
+	merged = append(merged, y...)
+	return merged, true
+}
+
 type editsSort []Edit
 
 func (a editsSort) Len() int { return len(a) }
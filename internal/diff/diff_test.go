@@ -161,6 +161,48 @@ func TestToUnified(t *testing.T) {
 	}
 }
 
+func TestMerge(t *testing.T) {
+	// The edits apply to "abcdef".
+	type E = diff.Edit
+	for _, tc := range []struct {
+		name string
+		x, y []E
+		want string // result of applying merged edits, or "conflict"
+	}{
+		{"disjoint", []E{{0, 1, "A"}}, []E{{3, 4, "D"}}, "AbcDef"},
+		{"identical", []E{{1, 3, "X"}}, []E{{1, 3, "X"}, {4, 5, "E"}}, "aXdEf"},
+		{"adjacent", []E{{0, 2, "AB"}}, []E{{2, 4, "CD"}}, "ABCDef"},
+		{"insert before replace", []E{{2, 2, "+"}}, []E{{2, 4, "CD"}}, "ab+CDef"},
+		{"insert after replace", []E{{2, 4, "CD"}}, []E{{4, 4, "+"}}, "abCD+ef"},
+		{"insert same", []E{{2, 2, "+"}}, []E{{2, 2, "+"}}, "ab+cdef"},
+		{"insert different", []E{{2, 2, "+"}}, []E{{2, 2, "-"}}, "conflict"},
+		{"insert within", []E{{1, 4, ""}}, []E{{2, 2, "+"}}, "conflict"},
+		{"overlap", []E{{0, 3, "X"}}, []E{{2, 5, "Y"}}, "conflict"},
+		{"same region", []E{{1, 2, "X"}}, []E{{1, 2, "Y"}}, "conflict"},
+		{"empty", nil, []E{{1, 2, "B"}}, "aBcdef"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, swap := range []bool{false, true} {
+				x, y := tc.x, tc.y
+				if swap {
+					x, y = y, x
+				}
+				got := "conflict"
+				if merged, ok := diff.Merge(x, y); ok {
+					var err error
+					got, err = diff.Apply("abcdef", merged)
+					if err != nil {
+						t.Fatalf("Merge(%v, %v) = %v: %v", x, y, merged, err)
+					}
+				}
+				if got != tc.want {
+					t.Errorf("Merge(%v, %v): got %q, want %q", x, y, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
 func TestRegressionOld001(t *testing.T) {
 	a := "// Copyright 2019 The Go Authors. All rights reserved.\n// Use of this source code is governed by a BSD-style\n// license that can be found in the LICENSE file.\n\npackage diff_test\n\nimport (\n\t\"fmt\"\n\t\"math/rand\"\n\t\"strings\"\n\t\"testing\"\n\n\t\"github.com/TBD54566975/golang-tools/gopls/internal/lsp/diff\"\n\t\"github.com/TBD54566975/golang-tools/internal/diff/difftest\"\n\t\"github.com/TBD54566975/golang-tools/gopls/internal/span\"\n)\n"
 
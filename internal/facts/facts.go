@@ -66,9 +66,10 @@ type Set struct {
 }
 
 type key struct {
-	pkg *types.Package
-	obj types.Object // (object facts only)
-	t   reflect.Type
+	pkg  *types.Package
+	obj  types.Object // (object facts only)
+	t    reflect.Type
+	from string // path of contributing package (contributed facts only)
 }
 
 // ImportObjectFact implements analysis.Pass.ImportObjectFact.
@@ -102,7 +103,7 @@ func (s *Set) AllObjectFacts(filter map[reflect.Type]bool) []analysis.ObjectFact
 	var facts []analysis.ObjectFact
 	s.mu.Lock()
 	for k, v := range s.m {
-		if k.obj != nil && filter[k.t] {
+		if k.obj != nil && k.from == "" && filter[k.t] {
 			facts = append(facts, analysis.ObjectFact{Object: k.obj, Fact: v})
 		}
 	}
@@ -110,6 +111,31 @@ func (s *Set) AllObjectFacts(filter map[reflect.Type]bool) []analysis.ObjectFact
 	return facts
 }
 
+// ContributeObjectFact implements analysis.Pass.ContributeObjectFact.
+func (s *Set) ContributeObjectFact(obj types.Object, fact analysis.Fact) {
+	if obj.Pkg() == nil {
+		log.Panicf("in package %s: ContributeObjectFact(%s, %T): can't contribute fact about object belonging to no package",
+			s.pkg, obj, fact)
+	}
+	key := key{pkg: obj.Pkg(), obj: obj, t: reflect.TypeOf(fact), from: s.pkg.Path()}
+	s.mu.Lock()
+	s.m[key] = fact // clobber any existing entry
+	s.mu.Unlock()
+}
+
+// AllContributedFacts implements analysis.Pass.AllContributedFacts.
+func (s *Set) AllContributedFacts(filter map[reflect.Type]bool) []analysis.ContributedFact {
+	var facts []analysis.ContributedFact
+	s.mu.Lock()
+	for k, v := range s.m {
+		if k.from != "" && filter[k.t] {
+			facts = append(facts, analysis.ContributedFact{Object: k.obj, From: k.from, Fact: v})
+		}
+	}
+	s.mu.Unlock()
+	return facts
+}
+
 // ImportPackageFact implements analysis.Pass.ImportPackageFact.
 func (s *Set) ImportPackageFact(pkg *types.Package, ptr analysis.Fact) bool {
 	if pkg == nil {
@@ -149,6 +175,7 @@ func (s *Set) AllPackageFacts(filter map[reflect.Type]bool) []analysis.PackageFa
 type gobFact struct {
 	PkgPath string          // path of package
 	Object  objectpath.Path // optional path of object relative to package itself
+	From    string          // path of contributing package, for contributed facts
 	Fact    analysis.Fact   // type and value of user-defined Fact
 }
 
@@ -241,7 +268,7 @@ func (d *Decoder) Decode(read func(pkgPath string) ([]byte, error)) (*Set, error
 				logf("no package %q; discarding %v", f.PkgPath, f.Fact)
 				continue
 			}
-			key := key{pkg: factPkg, t: reflect.TypeOf(f.Fact)}
+			key := key{pkg: factPkg, t: reflect.TypeOf(f.Fact), from: f.From}
 			if f.Object != "" {
 				// object fact
 				obj, err := objectpath.Object(factPkg, f.Object)
@@ -299,7 +326,10 @@ func (s *Set) Encode() []byte {
 		// intersect with the set of objects computed by
 		// importMap(s.pkg.Imports()).
 		// TODO(adonovan): opt: implement "shallow" facts.
-		if k.pkg != s.pkg {
+		//
+		// Contributed facts are always reexported, so that they
+		// reach all the packages that depend on the contributor.
+		if k.pkg != s.pkg && k.from == "" {
 			if k.obj == nil {
 				continue // imported package fact
 			}
@@ -323,12 +353,13 @@ func (s *Set) Encode() []byte {
 		gobFacts = append(gobFacts, gobFact{
 			PkgPath: k.pkg.Path(),
 			Object:  object,
+			From:    k.from,
 			Fact:    fact,
 		})
 	}
 	s.mu.Unlock()
 
-	// Sort facts by (package, object, type) for determinism.
+	// Sort facts by (package, object, contributor, type) for determinism.
 	sort.Slice(gobFacts, func(i, j int) bool {
 		x, y := gobFacts[i], gobFacts[j]
 		if x.PkgPath != y.PkgPath {
@@ -337,6 +368,9 @@ func (s *Set) Encode() []byte {
 		if x.Object != y.Object {
 			return x.Object < y.Object
 		}
+		if x.From != y.From {
+			return x.From < y.From
+		}
 		tx := reflect.TypeOf(x.Fact)
 		ty := reflect.TypeOf(y.Fact)
 		if tx != ty {
@@ -382,6 +416,9 @@ func (s *Set) String() string {
 		} else {
 			buf.WriteString(k.pkg.Path())
 		}
+		if k.from != "" {
+			fmt.Fprintf(&buf, " (from %s)", k.from)
+		}
 		fmt.Fprintf(&buf, ": %v", f)
 	}
 	buf.WriteString("}")
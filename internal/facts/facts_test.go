@@ -13,6 +13,7 @@ import (
 	"go/types"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -436,6 +437,73 @@ func TestFactFilter(t *testing.T) {
 	}
 }
 
+func TestContributedFacts(t *testing.T) {
+	filter := map[reflect.Type]bool{
+		reflect.TypeOf(&myFact{}): true,
+	}
+	contributed := func(s *facts.Set) string {
+		var got []string
+		for _, f := range s.AllContributedFacts(filter) {
+			got = append(got, fmt.Sprintf("%s: %s from %s", f.Object.Name(), f.Fact, f.From))
+		}
+		sort.Strings(got)
+		return strings.Join(got, "; ")
+	}
+
+	packages := make(map[string]*types.Package)
+	conf := types.Config{Importer: closure(packages)}
+	fset := token.NewFileSet()
+	factmap := make(map[string][]byte)
+	read := func(pkgPath string) ([]byte, error) { return factmap[pkgPath], nil }
+
+	// Each package contributes a fact about the
+	// package-level objects of its direct imports.
+	for i, test := range []struct {
+		content, want string
+	}{
+		{`package a; type A int`, ""},
+		{`package b; import "a"; type B []a.A`, "A: myFact(used by b) from b"},
+		{`package c; import "b"; type C b.B`, "A: myFact(used by b) from b; B: myFact(used by c) from c"},
+	} {
+		f, err := parser.ParseFile(fset, fmt.Sprintf("%d.go", i), test.content, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packages[pkg.Path()] = pkg
+
+		s, err := facts.NewDecoder(pkg).Decode(read)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		for _, imp := range pkg.Imports() {
+			for _, name := range imp.Scope().Names() {
+				s.ContributeObjectFact(imp.Scope().Lookup(name), &myFact{"used by " + pkg.Path()})
+			}
+		}
+		if got := contributed(s); got != test.want {
+			t.Errorf("in %s, AllContributedFacts = %q, want %q", pkg.Path(), got, test.want)
+		}
+
+		// Contributed facts are not facts of the object itself.
+		if got := s.AllObjectFacts(filter); len(got) > 0 {
+			t.Errorf("in %s, AllObjectFacts = %v, want none", pkg.Path(), got)
+		}
+		for _, imp := range pkg.Imports() {
+			for _, name := range imp.Scope().Names() {
+				if obj := imp.Scope().Lookup(name); s.ImportObjectFact(obj, new(myFact)) {
+					t.Errorf("in %s, ImportObjectFact(%s) found a contributed fact", pkg.Path(), obj)
+				}
+			}
+		}
+
+		factmap[pkg.Path()] = s.Encode()
+	}
+}
+
 // TestMalformed checks that facts can be encoded and decoded *despite*
 // types.Config.Check returning an error. Importing facts is expected to
 // happen when Analyzers have RunDespiteErrors set to true. So this
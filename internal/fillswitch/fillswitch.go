@@ -0,0 +1,299 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fillswitch computes the cases missing from a type switch or
+// an 'enum' switch, and the edits that fill them in. It is shared by
+// gopls' fill-switch code action and the exhaustive analyzer.
+//
+// The possible cases are: for a type switch, each accessible named
+// type T or pointer *T that is assignable to the interface type; and
+// for an 'enum' switch, each accessible named constant of the same
+// type as the switch value.
+package fillswitch
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/TBD54566975/golang-tools/go/analysis"
+)
+
+// Missing describes the cases missing from a switch statement.
+type Missing struct {
+	Type  *types.Named // type of the switch value, or interface type of the type switch
+	Cases []string     // missing case expressions, such as "C", "pkg.C" or "*pkg.T"
+	stmt  ast.Stmt     // *ast.SwitchStmt or *ast.TypeSwitchStmt
+}
+
+// Fix returns the fix that adds the missing cases, followed by a
+// default case that panics.
+func (m *Missing) Fix() *analysis.SuggestedFix {
+	var buf bytes.Buffer
+	for i, c := range m.Cases {
+		if i > 0 {
+			buf.WriteString("\t")
+		}
+		buf.WriteString("case ")
+		buf.WriteString(c)
+		buf.WriteString(":\n")
+	}
+
+	switch stmt := m.stmt.(type) {
+	case *ast.SwitchStmt:
+		addDefaultCase(&buf, m.Type, stmt.Tag)
+	case *ast.TypeSwitchStmt:
+		switch assign := stmt.Assign.(type) {
+		case *ast.AssignStmt:
+			addDefaultCase(&buf, m.Type, assign.Lhs[0])
+		case *ast.ExprStmt:
+			if assert, ok := assign.X.(*ast.TypeAssertExpr); ok {
+				addDefaultCase(&buf, m.Type, assert.X)
+			}
+		}
+	}
+
+	return &analysis.SuggestedFix{
+		Message: fmt.Sprintf("Add cases for %s", m.Type.Obj().Name()),
+		TextEdits: []analysis.TextEdit{{
+			Pos:     m.stmt.End() - token.Pos(len("}")),
+			End:     m.stmt.End() - token.Pos(len("}")),
+			NewText: buf.Bytes(),
+		}},
+	}
+}
+
+// TypeSwitchCases returns the cases missing from a type switch over a
+// value of named interface type, or nil if the switch has a default
+// case or is missing no cases.
+func TypeSwitchCases(stmt *ast.TypeSwitchStmt, pkg *types.Package, info *types.Info) *Missing {
+	if hasDefaultCase(stmt.Body) {
+		return nil
+	}
+
+	namedType := namedTypeFromTypeSwitch(stmt, info)
+	if namedType == nil {
+		return nil
+	}
+
+	existingCases := caseTypes(stmt.Body, info)
+	// Gather accessible package-level concrete types
+	// that implement the switch interface type.
+	scope := namedType.Obj().Pkg().Scope()
+	var cases []string
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if tname, ok := obj.(*types.TypeName); !ok || tname.IsAlias() {
+			continue // not a defined type
+		}
+
+		if types.IsInterface(obj.Type()) {
+			continue
+		}
+
+		samePkg := obj.Pkg() == pkg
+		if !samePkg && !obj.Exported() {
+			continue // inaccessible
+		}
+
+		var key caseType
+		if types.AssignableTo(obj.Type(), namedType.Obj().Type()) {
+			key.named = obj.Type().(*types.Named)
+		} else if ptr := types.NewPointer(obj.Type()); types.AssignableTo(ptr, namedType.Obj().Type()) {
+			key.named = obj.Type().(*types.Named)
+			key.ptr = true
+		}
+
+		if key.named != nil {
+			if existingCases[key] {
+				continue
+			}
+
+			var buf bytes.Buffer
+			if key.ptr {
+				buf.WriteByte('*')
+			}
+
+			if p := key.named.Obj().Pkg(); p != pkg {
+				// TODO: use the correct package name when the import is renamed
+				buf.WriteString(p.Name())
+				buf.WriteByte('.')
+			}
+			buf.WriteString(key.named.Obj().Name())
+			cases = append(cases, buf.String())
+		}
+	}
+
+	if len(cases) == 0 {
+		return nil
+	}
+	return &Missing{Type: namedType, Cases: cases, stmt: stmt}
+}
+
+// SwitchCases returns the cases missing from a switch on a value of
+// named type, or nil if the switch has a default case or is missing no
+// cases. A constant whose value is that of another case is not
+// missing, as Go does not permit duplicate constant cases.
+func SwitchCases(stmt *ast.SwitchStmt, pkg *types.Package, info *types.Info) *Missing {
+	if hasDefaultCase(stmt.Body) {
+		return nil
+	}
+
+	namedType, ok := info.TypeOf(stmt.Tag).(*types.Named)
+	if !ok {
+		return nil
+	}
+
+	existingCases, existingValues := caseConsts(stmt.Body, info)
+	// Gather accessible named constants of the same type as the switch value.
+	scope := namedType.Obj().Pkg().Scope()
+	var cases []string
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if c, ok := obj.(*types.Const); ok &&
+			(obj.Pkg() == pkg || obj.Exported()) && // accessible
+			types.Identical(obj.Type(), namedType.Obj().Type()) &&
+			!existingCases[c] {
+
+			if c.Val().Kind() != constant.Unknown {
+				if existingValues[c.Val().ExactString()] {
+					continue // covered by a constant of the same value
+				}
+				existingValues[c.Val().ExactString()] = true
+			}
+			if c.Pkg() != pkg {
+				cases = append(cases, c.Pkg().Name()+"."+c.Name())
+			} else {
+				cases = append(cases, c.Name())
+			}
+		}
+	}
+
+	if len(cases) == 0 {
+		return nil
+	}
+	return &Missing{Type: namedType, Cases: cases, stmt: stmt}
+}
+
+func addDefaultCase(buf *bytes.Buffer, named *types.Named, expr ast.Expr) {
+	var dottedBuf bytes.Buffer
+	// writeDotted emits a dotted path a.b.c.
+	var writeDotted func(e ast.Expr) bool
+	writeDotted = func(e ast.Expr) bool {
+		switch e := e.(type) {
+		case *ast.SelectorExpr:
+			if !writeDotted(e.X) {
+				return false
+			}
+			dottedBuf.WriteByte('.')
+			dottedBuf.WriteString(e.Sel.Name)
+			return true
+		case *ast.Ident:
+			dottedBuf.WriteString(e.Name)
+			return true
+		}
+		return false
+	}
+
+	buf.WriteString("\tdefault:\n")
+	typeName := fmt.Sprintf("%s.%s", named.Obj().Pkg().Name(), named.Obj().Name())
+	if writeDotted(expr) {
+		// Switch tag expression is a dotted path.
+		// It is safe to re-evaluate it in the default case.
+		format := fmt.Sprintf("unexpected %s: %%#v", typeName)
+		fmt.Fprintf(buf, "\t\tpanic(fmt.Sprintf(%q, %s))\n\t", format, dottedBuf.String())
+	} else {
+		// Emit simpler message, without re-evaluating tag expression.
+		fmt.Fprintf(buf, "\t\tpanic(%q)\n\t", "unexpected "+typeName)
+	}
+}
+
+func namedTypeFromTypeSwitch(stmt *ast.TypeSwitchStmt, info *types.Info) *types.Named {
+	switch assign := stmt.Assign.(type) {
+	case *ast.ExprStmt:
+		if typ, ok := assign.X.(*ast.TypeAssertExpr); ok {
+			if named, ok := info.TypeOf(typ.X).(*types.Named); ok {
+				return named
+			}
+		}
+
+	case *ast.AssignStmt:
+		if typ, ok := assign.Rhs[0].(*ast.TypeAssertExpr); ok {
+			if named, ok := info.TypeOf(typ.X).(*types.Named); ok {
+				return named
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasDefaultCase(body *ast.BlockStmt) bool {
+	for _, clause := range body.List {
+		if len(clause.(*ast.CaseClause).List) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// caseConsts returns the named constants of the cases of a switch,
+// and the exact values of all its constant cases.
+func caseConsts(body *ast.BlockStmt, info *types.Info) (map[*types.Const]bool, map[string]bool) {
+	out := map[*types.Const]bool{}
+	values := map[string]bool{}
+	for _, stmt := range body.List {
+		for _, e := range stmt.(*ast.CaseClause).List {
+			if info.Types[e].Value == nil {
+				continue // not a constant
+			}
+			if v := info.Types[e].Value; v.Kind() != constant.Unknown {
+				values[v.ExactString()] = true
+			}
+
+			if sel, ok := e.(*ast.SelectorExpr); ok {
+				e = sel.Sel // replace pkg.C with C
+			}
+
+			if e, ok := e.(*ast.Ident); ok {
+				if c, ok := info.Uses[e].(*types.Const); ok {
+					out[c] = true
+				}
+			}
+		}
+	}
+
+	return out, values
+}
+
+type caseType struct {
+	named *types.Named
+	ptr   bool
+}
+
+func caseTypes(body *ast.BlockStmt, info *types.Info) map[caseType]bool {
+	out := map[caseType]bool{}
+	for _, stmt := range body.List {
+		for _, e := range stmt.(*ast.CaseClause).List {
+			if tv, ok := info.Types[e]; ok && tv.IsType() {
+				t := tv.Type
+				ptr := false
+				if p, ok := t.(*types.Pointer); ok {
+					t = p.Elem()
+					ptr = true
+				}
+
+				if named, ok := t.(*types.Named); ok {
+					out[caseType{named, ptr}] = true
+				}
+			}
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,20 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.25
+// +build go1.25
+
+package tokeninternal
+
+import "go/token"
+
+// AddExistingFiles adds the specified files to the FileSet if they
+// are not already present. It panics if any pair of files in the
+// resulting FileSet would overlap.
+//
+// From go1.25, FileSet holds its files in a tree, and it provides
+// this operation as a method.
+func AddExistingFiles(fset *token.FileSet, files []*token.File) {
+	fset.AddExistingFiles(files...)
+}